@@ -1,120 +1,217 @@
-package ast
-
-import (
-	"bytes"
-	"fmt"
-
-	"github.com/JunNishimura/go-lisp/token"
-)
-
-type SExpression interface {
-	String() string
-}
-
-type Atom interface {
-	SExpression
-	TokenLiteral() string
-}
-
-type IntegerLiteral struct {
-	Token token.Token
-	Value int64
-}
-
-func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
-func (il *IntegerLiteral) String() string       { return il.Token.Literal }
-
-type PrefixAtom struct {
-	Token    token.Token
-	Operator string
-	Right    SExpression
-}
-
-func (pa *PrefixAtom) TokenLiteral() string { return pa.Token.Literal }
-func (pa *PrefixAtom) String() string       { return fmt.Sprintf("%s%s", pa.Operator, pa.Right.String()) }
-
-type Symbol struct {
-	Token token.Token
-	Value string
-}
-
-func (s *Symbol) TokenLiteral() string { return s.Token.Literal }
-func (s *Symbol) String() string       { return s.Value }
-
-type True struct {
-	Token token.Token
-}
-
-func (t *True) TokenLiteral() string { return t.Token.Literal }
-func (t *True) String() string       { return "T" }
-
-type SpecialForm struct {
-	Token token.Token
-	Value string
-}
-
-func (s *SpecialForm) TokenLiteral() string { return s.Token.Literal }
-func (s *SpecialForm) String() string       { return s.Value }
-
-type Nil struct {
-	Token token.Token
-}
-
-func (n *Nil) TokenLiteral() string { return n.Token.Literal }
-func (n *Nil) String() string       { return "NIL" }
-func (n *Nil) Car() SExpression     { return n }
-func (n *Nil) Cdr() SExpression     { return n }
-
-type List interface {
-	SExpression
-	Car() SExpression
-	Cdr() SExpression
-}
-
-type ConsCell struct {
-	CarField SExpression
-	CdrField SExpression
-}
-
-func (cc *ConsCell) String() string {
-	var out bytes.Buffer
-
-	out.WriteString("(")
-
-	consCell := cc
-	for {
-		out.WriteString(consCell.Car().String())
-
-		if _, ok := consCell.Cdr().(*Nil); ok {
-			break
-		}
-
-		if cdr, ok := consCell.Cdr().(*ConsCell); ok {
-			out.WriteString(" ")
-			consCell = cdr
-		} else {
-			out.WriteString(" . ")
-			out.WriteString(consCell.Cdr().String())
-			break
-		}
-	}
-	out.WriteString(")")
-
-	return out.String()
-}
-func (cc *ConsCell) Car() SExpression { return cc.CarField }
-func (cc *ConsCell) Cdr() SExpression { return cc.CdrField }
-
-type Program struct {
-	Expressions []SExpression
-}
-
-func (p *Program) String() string {
-	var out bytes.Buffer
-
-	for _, exp := range p.Expressions {
-		out.WriteString(exp.String())
-	}
-
-	return out.String()
-}
+package ast
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+type SExpression interface {
+	String() string
+	Span() Span
+}
+
+// Span is the range of the source which the node is read from.
+// End is the position just after the last character of the node.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// IsValid reports whether the span is set
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+func tokenSpan(t token.Token) Span {
+	return Span{Start: t.Pos, End: t.End}
+}
+
+type Atom interface {
+	SExpression
+	TokenLiteral() string
+}
+
+type IntegerLiteral struct {
+	Token token.Token
+	Value int64
+}
+
+func (il *IntegerLiteral) Span() Span           { return tokenSpan(il.Token) }
+func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+
+// BignumLiteral is an integer literal which is too large for int64
+type BignumLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BignumLiteral) Span() Span           { return tokenSpan(bl.Token) }
+func (bl *BignumLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BignumLiteral) String() string       { return bl.Token.Literal }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) Span() Span           { return tokenSpan(fl.Token) }
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+type RatioLiteral struct {
+	Token token.Token
+	Value *big.Rat
+}
+
+func (rl *RatioLiteral) Span() Span           { return tokenSpan(rl.Token) }
+func (rl *RatioLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RatioLiteral) String() string       { return rl.Token.Literal }
+
+// stringEscaper escapes the characters which cannot appear as-is inside a string literal
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) Span() Span           { return tokenSpan(sl.Token) }
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return `"` + stringEscaper.Replace(sl.Value) + `"` }
+
+type Symbol struct {
+	Token token.Token
+	Value string
+}
+
+func (s *Symbol) Span() Span           { return tokenSpan(s.Token) }
+func (s *Symbol) TokenLiteral() string { return s.Token.Literal }
+func (s *Symbol) String() string       { return s.Value }
+
+// IsKeyword reports whether the symbol is a keyword such as :test, which evaluates to itself
+func (s *Symbol) IsKeyword() bool { return s.Token.Type == token.KEYWORD }
+
+type True struct {
+	Token token.Token
+}
+
+func (t *True) Span() Span           { return tokenSpan(t.Token) }
+func (t *True) TokenLiteral() string { return t.Token.Literal }
+func (t *True) String() string       { return "T" }
+
+type SpecialForm struct {
+	Token token.Token
+	Value string
+}
+
+func (s *SpecialForm) Span() Span           { return tokenSpan(s.Token) }
+func (s *SpecialForm) TokenLiteral() string { return s.Token.Literal }
+func (s *SpecialForm) String() string       { return s.Value }
+
+// AsSymbol returns sexp as the symbol if it is one.
+// the names of the special forms are also symbols, which name the variables outside the function position.
+func AsSymbol(sexp SExpression) (*Symbol, bool) {
+	switch sexp := sexp.(type) {
+	case *Symbol:
+		return sexp, true
+	case *SpecialForm:
+		t := sexp.Token
+		t.Type = token.SYMBOL
+		return &Symbol{Token: t, Value: sexp.Value}, true
+	default:
+		return nil, false
+	}
+}
+
+type Nil struct {
+	Token token.Token
+}
+
+func (n *Nil) Span() Span           { return tokenSpan(n.Token) }
+func (n *Nil) TokenLiteral() string { return n.Token.Literal }
+func (n *Nil) String() string       { return "NIL" }
+func (n *Nil) Car() SExpression     { return n }
+func (n *Nil) Cdr() SExpression     { return n }
+
+// BadSExpression is the placeholder for the token which could not be parsed.
+// it only appears in the partial AST returned along with the parse errors.
+type BadSExpression struct {
+	Token token.Token
+}
+
+func (bs *BadSExpression) Span() Span           { return tokenSpan(bs.Token) }
+func (bs *BadSExpression) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadSExpression) String() string       { return bs.Token.Literal }
+
+type List interface {
+	SExpression
+	Car() SExpression
+	Cdr() SExpression
+}
+
+// ConsCell is a cons cell of the list.
+// SpanField of every cell in the list runs from its car to the closing paren of the list,
+// and that of the cells synthesized by the parser, such as (quote x) for 'x, covers the shorthand.
+type ConsCell struct {
+	CarField  SExpression
+	CdrField  SExpression
+	SpanField Span
+}
+
+func (cc *ConsCell) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+
+	consCell := cc
+	for {
+		out.WriteString(consCell.Car().String())
+
+		if _, ok := consCell.Cdr().(*Nil); ok {
+			break
+		}
+
+		if cdr, ok := consCell.Cdr().(*ConsCell); ok {
+			out.WriteString(" ")
+			consCell = cdr
+		} else {
+			out.WriteString(" . ")
+			out.WriteString(consCell.Cdr().String())
+			break
+		}
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+func (cc *ConsCell) Car() SExpression { return cc.CarField }
+func (cc *ConsCell) Cdr() SExpression { return cc.CdrField }
+func (cc *ConsCell) Span() Span       { return cc.SpanField }
+
+type Program struct {
+	Expressions []SExpression
+}
+
+func (p *Program) Span() Span {
+	if len(p.Expressions) == 0 {
+		return Span{}
+	}
+	return Span{
+		Start: p.Expressions[0].Span().Start,
+		End:   p.Expressions[len(p.Expressions)-1].Span().End,
+	}
+}
+
+func (p *Program) String() string {
+	var out bytes.Buffer
+
+	for _, exp := range p.Expressions {
+		out.WriteString(exp.String())
+	}
+
+	return out.String()
+}
@@ -1,44 +1,42 @@
-package ast
-
-import (
-	"slices"
-)
-
-type ModifierFun func(SExpression) SExpression
-
-func ModifyByUnquote(sexp SExpression, modifier ModifierFun) SExpression {
-	return modify(sexp, modifier, func(sexp SExpression) bool {
-		if spForm, ok := sexp.(*SpecialForm); ok {
-			return spForm.Value == "unquote"
-		}
-		return false
-	})
-}
-
-func ModifyByMacro(sexp SExpression, modifier ModifierFun, macroNames []string) SExpression {
-	return modify(sexp, modifier, func(sexp SExpression) bool {
-		if symbol, ok := sexp.(*Symbol); ok {
-			return slices.Contains(macroNames, symbol.Value)
-		}
-		return false
-	})
-}
-
-func modify(sexp SExpression, modifier ModifierFun, targetCond func(sexp SExpression) bool) SExpression {
-	switch st := sexp.(type) {
-	case *Program:
-		for i, sexp := range st.Expressions {
-			st.Expressions[i] = modify(sexp, modifier, targetCond)
-		}
-	case *ConsCell:
-		if targetCond(st.Car()) {
-			// return not only the car field but also the cdr field
-			// since args(cdr field) are needed to modify the AST
-			return modifier(sexp)
-		}
-		st.CarField = modify(st.CarField, modifier, targetCond)
-		st.CdrField = modify(st.CdrField, modifier, targetCond)
-	}
-
-	return sexp
-}
+package ast
+
+import (
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+// FillSpan sets span on the nodes in sexp which have no span of their own, such as the nodes made by macros,
+// so that the errors in them point to the form they are expanded from
+func FillSpan(sexp SExpression, span Span) {
+	switch st := sexp.(type) {
+	case *ConsCell:
+		if !st.SpanField.IsValid() {
+			st.SpanField = span
+		}
+		FillSpan(st.CarField, span)
+		FillSpan(st.CdrField, span)
+	case *IntegerLiteral:
+		fillTokenSpan(&st.Token, span)
+	case *BignumLiteral:
+		fillTokenSpan(&st.Token, span)
+	case *FloatLiteral:
+		fillTokenSpan(&st.Token, span)
+	case *RatioLiteral:
+		fillTokenSpan(&st.Token, span)
+	case *StringLiteral:
+		fillTokenSpan(&st.Token, span)
+	case *Symbol:
+		fillTokenSpan(&st.Token, span)
+	case *True:
+		fillTokenSpan(&st.Token, span)
+	case *SpecialForm:
+		fillTokenSpan(&st.Token, span)
+	case *Nil:
+		fillTokenSpan(&st.Token, span)
+	}
+}
+
+func fillTokenSpan(t *token.Token, span Span) {
+	if !t.Pos.IsValid() {
+		t.Pos, t.End = span.Start, span.End
+	}
+}
@@ -0,0 +1,872 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/evaluator"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+// errUnsupported is returned for the forms which the compiler leaves to the evaluator
+var errUnsupported = errors.New("unsupported form")
+
+// Bytecode is the result of compiling a program.
+// the compiled functions refer to it for their constants.
+type Bytecode struct {
+	Main      *CompiledFunction
+	Constants []object.Object
+	// Forms are the top-level forms which are evaluated by the evaluator
+	Forms []ast.SExpression
+}
+
+type CompiledFunction struct {
+	Instructions Instructions
+	NumLocals    int
+	// NumParameters is the number of the required parameters
+	NumParameters int
+	// Rest is true if the arguments after the required ones are collected into the list in the next slot
+	Rest     bool
+	Bytecode *Bytecode
+	// Source is printed as the function like the closure of the evaluator
+	Source string
+	// Positions map the instructions to the forms they are compiled from, in the order of their offsets
+	Positions []SourcePosition
+}
+
+// SourcePosition says that the instructions from Offset up to the next SourcePosition are compiled from the form at Pos
+type SourcePosition struct {
+	Offset int
+	Pos    token.Position
+}
+
+// PositionAt returns the position of the innermost form which the instruction at offset is compiled from.
+// the position is not valid if the form has none, such as the form made by a macro.
+func (cf *CompiledFunction) PositionAt(offset int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool { return cf.Positions[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return cf.Positions[i-1].Pos
+}
+
+func (cf *CompiledFunction) Type() object.ObjectType { return object.COMPILED_OBJ }
+func (cf *CompiledFunction) Inspect() string         { return cf.Source }
+
+// Name is the constant which names the global variable or function.
+// the vm caches the symbol and the builtin function resolved in the environment.
+type Name struct {
+	Value   string
+	Env     *object.Environment
+	Symbol  *object.Symbol
+	Builtin *object.Builtin
+}
+
+func (n *Name) Type() object.ObjectType { return object.SYMBOL_OBJ }
+func (n *Name) Inspect() string         { return n.Value }
+
+// Datum is the constant of the quoted s-expression which contains symbols.
+// the vm caches the data whose symbols are interned in the environment.
+type Datum struct {
+	Value  ast.SExpression
+	Env    *object.Environment
+	Object object.Object
+}
+
+func (d *Datum) Type() object.ObjectType { return object.SYMBOL_OBJ }
+func (d *Datum) Inspect() string         { return d.Value.String() }
+
+type compilationScope struct {
+	instructions Instructions
+	symbolTable  *SymbolTable
+	// captured is the set of the names referred to by the nested functions,
+	// whose local variables are stored in cells
+	captured  map[string]bool
+	positions []SourcePosition
+}
+
+type Compiler struct {
+	bytecode *Bytecode
+	scopes   []*compilationScope
+	// overflow is set when an operand, such as a constant index or a jump address, does not fit in its width.
+	// the top-level form being compiled is then left to the evaluator.
+	overflow bool
+	// position is that of the innermost form being compiled, to which the emitted instructions are mapped
+	position token.Position
+}
+
+func New() *Compiler {
+	return &Compiler{
+		bytecode: &Bytecode{},
+		scopes: []*compilationScope{
+			{symbolTable: NewSymbolTable(), captured: map[string]bool{}},
+		},
+	}
+}
+
+// Compile compiles the macro-expanded program.
+// the top-level forms which contain the forms the compiler does not support are evaluated by the evaluator,
+// which also reports the malformed forms.
+func (c *Compiler) Compile(program *ast.Program) {
+	if len(program.Expressions) == 0 {
+		c.emit(OpNil)
+	}
+
+	for i, form := range program.Expressions {
+		if i > 0 {
+			c.emit(OpPop)
+		}
+
+		start := len(c.scope().instructions)
+		numConstants := len(c.bytecode.Constants)
+		numSymbolScopes := len(c.scope().symbolTable.scopes)
+		c.scope().captured = capturedNames(form)
+		c.overflow = false
+
+		err := c.compile(form, false)
+		if err == nil && c.overflow {
+			err = errUnsupported
+		}
+		if err != nil {
+			c.scopes = c.scopes[:1]
+			c.scope().instructions = c.scope().instructions[:start]
+			c.scope().symbolTable.scopes = c.scope().symbolTable.scopes[:numSymbolScopes]
+			c.bytecode.Constants = c.bytecode.Constants[:numConstants]
+
+			// the forms from the one which takes the last index are evaluated together
+			if len(c.bytecode.Forms) == maxOperand(2) {
+				c.bytecode.Forms = append(c.bytecode.Forms, &ast.Program{Expressions: program.Expressions[i:]})
+				c.emit(OpEval, len(c.bytecode.Forms)-1)
+				break
+			}
+			c.bytecode.Forms = append(c.bytecode.Forms, form)
+			c.emit(OpEval, len(c.bytecode.Forms)-1)
+		}
+	}
+
+	c.emit(OpReturn)
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	c.bytecode.Main = &CompiledFunction{
+		Instructions: c.scope().instructions,
+		NumLocals:    c.scope().symbolTable.NumLocals(),
+		Bytecode:     c.bytecode,
+		Positions:    c.scope().positions,
+	}
+	return c.bytecode
+}
+
+func (c *Compiler) scope() *compilationScope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	for i, width := range definitions[op].OperandWidths {
+		if i < len(operands) && operands[i] > maxOperand(width) {
+			c.overflow = true
+		}
+	}
+
+	pos := len(c.scope().instructions)
+	c.markPosition(pos)
+	c.scope().instructions = append(c.scope().instructions, Make(op, operands...)...)
+	return pos
+}
+
+// markPosition maps the instruction at offset to the form being compiled
+func (c *Compiler) markPosition(offset int) {
+	positions := c.scope().positions
+	// the positions at and after offset are left by the instructions of the form which was discarded
+	for len(positions) > 0 && positions[len(positions)-1].Offset >= offset {
+		positions = positions[:len(positions)-1]
+	}
+	if len(positions) == 0 || positions[len(positions)-1].Pos != c.position {
+		positions = append(positions, SourcePosition{Offset: offset, Pos: c.position})
+	}
+	c.scope().positions = positions
+}
+
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := Opcode(c.scope().instructions[pos])
+	if operand > maxOperand(definitions[op].OperandWidths[0]) {
+		c.overflow = true
+	}
+	copy(c.scope().instructions[pos:], Make(op, operand))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.bytecode.Constants = append(c.bytecode.Constants, obj)
+	return len(c.bytecode.Constants) - 1
+}
+
+func (c *Compiler) addName(name string) int {
+	return c.addConstant(&Name{Value: name})
+}
+
+// emitQuote emits the instruction which pushes the quoted data.
+// the data is made at compile time unless the symbols in it need to be interned at run time.
+func (c *Compiler) emitQuote(sexp ast.SExpression) {
+	if hasSymbol(sexp) {
+		c.emit(OpQuote, c.addConstant(&Datum{Value: sexp}))
+		return
+	}
+	c.emit(OpConstant, c.addConstant(evaluator.Quote(sexp, nil)))
+}
+
+func hasSymbol(sexp ast.SExpression) bool {
+	switch sexp := sexp.(type) {
+	case *ast.Symbol, *ast.SpecialForm:
+		return true
+	case *ast.ConsCell:
+		return hasSymbol(sexp.Car()) || hasSymbol(sexp.Cdr())
+	default:
+		return false
+	}
+}
+
+// compile emits the instructions which push the value of sexp.
+// tail is true if the value is returned from the function, where the calls are compiled into tail calls.
+func (c *Compiler) compile(sexp ast.SExpression, tail bool) error {
+	// the form made by a macro without the position is mapped to the enclosing form
+	if pos := sexp.Span().Start; pos.IsValid() {
+		outer := c.position
+		c.position = pos
+		defer func() { c.position = outer }()
+	}
+
+	switch sexp := sexp.(type) {
+	case *ast.ConsCell:
+		return c.compileList(sexp, tail)
+	case *ast.Symbol:
+		return c.compileSymbol(sexp)
+	case *ast.SpecialForm:
+		// the name of the special form is the variable outside the function position
+		symbol, _ := ast.AsSymbol(sexp)
+		return c.compileSymbol(symbol)
+	case *ast.Nil:
+		c.emit(OpNil)
+	case *ast.True:
+		c.emit(OpTrue)
+	case *ast.IntegerLiteral, *ast.BignumLiteral, *ast.FloatLiteral, *ast.RatioLiteral, *ast.StringLiteral:
+		obj := evaluator.Quote(sexp, nil)
+		if obj.Type() == object.SYMBOL_OBJ || obj.Type() == object.ERROR_OBJ {
+			return errUnsupported
+		}
+		c.emit(OpConstant, c.addConstant(obj))
+	default:
+		return errUnsupported
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileSymbol(sexp *ast.Symbol) error {
+	// keywords evaluate to themselves
+	if sexp.IsKeyword() {
+		c.emitQuote(sexp)
+		return nil
+	}
+
+	symbol := c.scope().symbolTable.Resolve(sexp.Value)
+	switch symbol.Scope {
+	case LocalScope:
+		if symbol.Boxed {
+			c.emit(OpGetCell, symbol.Index)
+		} else {
+			c.emit(OpGetLocal, symbol.Index)
+		}
+	case FreeScope:
+		c.emit(OpGetFree, symbol.Index)
+	default:
+		c.emit(OpGetGlobal, c.addName(sexp.Value))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileList(sexp *ast.ConsCell, tail bool) error {
+	args, ok := listToSlice(sexp.Cdr())
+	if !ok {
+		return errUnsupported
+	}
+
+	switch car := sexp.Car().(type) {
+	case *ast.SpecialForm:
+		return c.compileSpecialForm(car.Value, args, tail)
+	case *ast.Symbol:
+		c.emit(OpGetFunction, c.addName(car.Value))
+	case *ast.ConsCell:
+		if !isLambda(car) {
+			return errUnsupported
+		}
+		if err := c.compile(car, false); err != nil {
+			return err
+		}
+	default:
+		return errUnsupported
+	}
+
+	if len(args) > 255 {
+		return errUnsupported
+	}
+	for _, arg := range args {
+		if err := c.compile(arg, false); err != nil {
+			return err
+		}
+	}
+
+	if tail {
+		c.emit(OpTailCall, len(args))
+	} else {
+		c.emit(OpCall, len(args))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileSpecialForm(name string, args []ast.SExpression, tail bool) error {
+	switch name {
+	case "quote":
+		if len(args) != 1 {
+			return errUnsupported
+		}
+		c.emitQuote(args[0])
+		return nil
+	case "if":
+		return c.compileIf(args, tail)
+	case "when":
+		return c.compileWhen(args, false, tail)
+	case "unless":
+		return c.compileWhen(args, true, tail)
+	case "and":
+		return c.compileAnd(args, false, tail)
+	case "or":
+		return c.compileAnd(args, true, tail)
+	case "cond":
+		return c.compileCond(args, tail)
+	case "progn":
+		return c.compileBody(args, tail)
+	case "setq":
+		return c.compileSetq(args)
+	case "let":
+		return c.compileLet(args, false, tail)
+	case "let*":
+		return c.compileLet(args, true, tail)
+	case "lambda":
+		if len(args) == 0 {
+			return errUnsupported
+		}
+		return c.compileFunction(args[0], args[1:])
+	case "defun":
+		return c.compileDefun(args)
+	case "function":
+		return c.compileFunctionName(args)
+	default:
+		return errUnsupported
+	}
+}
+
+func (c *Compiler) compileIf(args []ast.SExpression, tail bool) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errUnsupported
+	}
+
+	if err := c.compile(args[0], false); err != nil {
+		return err
+	}
+	// the address is patched after the consequent is compiled
+	jumpIfNilPos := c.emit(OpJumpIfNil, 9999)
+
+	if err := c.compile(args[1], tail); err != nil {
+		return err
+	}
+	jumpPos := c.emit(OpJump, 9999)
+
+	c.changeOperand(jumpIfNilPos, len(c.scope().instructions))
+	if len(args) == 3 {
+		if err := c.compile(args[2], tail); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNil)
+	}
+
+	c.changeOperand(jumpPos, len(c.scope().instructions))
+
+	return nil
+}
+
+// compileWhen compiles when, or unless if negate is true, as if with the body in progn
+func (c *Compiler) compileWhen(args []ast.SExpression, negate bool, tail bool) error {
+	if len(args) == 0 {
+		return errUnsupported
+	}
+
+	if err := c.compile(args[0], false); err != nil {
+		return err
+	}
+	jumpIfNilPos := c.emit(OpJumpIfNil, 9999)
+
+	if negate {
+		c.emit(OpNil)
+	} else if err := c.compileBody(args[1:], tail); err != nil {
+		return err
+	}
+	jumpPos := c.emit(OpJump, 9999)
+
+	c.changeOperand(jumpIfNilPos, len(c.scope().instructions))
+	if negate {
+		if err := c.compileBody(args[1:], tail); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNil)
+	}
+
+	c.changeOperand(jumpPos, len(c.scope().instructions))
+
+	return nil
+}
+
+// compileAnd compiles and, or or if isOr is true.
+// or keeps the value of the form which is true, so the value is duplicated before the test.
+func (c *Compiler) compileAnd(args []ast.SExpression, isOr bool, tail bool) error {
+	if len(args) == 0 {
+		if isOr {
+			c.emit(OpNil)
+		} else {
+			c.emit(OpTrue)
+		}
+		return nil
+	}
+
+	// the addresses are patched after the last form is compiled
+	exitPositions := []int{}
+	for _, form := range args[:len(args)-1] {
+		if err := c.compile(form, false); err != nil {
+			return err
+		}
+		if isOr {
+			c.emit(OpDup)
+			jumpIfNilPos := c.emit(OpJumpIfNil, 9999)
+			exitPositions = append(exitPositions, c.emit(OpJump, 9999))
+			c.changeOperand(jumpIfNilPos, len(c.scope().instructions))
+			c.emit(OpPop)
+		} else {
+			exitPositions = append(exitPositions, c.emit(OpJumpIfNil, 9999))
+		}
+	}
+
+	if err := c.compile(args[len(args)-1], tail); err != nil {
+		return err
+	}
+
+	// and jumps to push nil, while or jumps over the rest with the value on the stack
+	if !isOr && len(exitPositions) > 0 {
+		jumpPos := c.emit(OpJump, 9999)
+		for _, pos := range exitPositions {
+			c.changeOperand(pos, len(c.scope().instructions))
+		}
+		c.emit(OpNil)
+		exitPositions = []int{jumpPos}
+	}
+	for _, pos := range exitPositions {
+		c.changeOperand(pos, len(c.scope().instructions))
+	}
+
+	return nil
+}
+
+// compileCond compiles the clauses (test . body) like nested ifs.
+// the clause without body returns the value of the test like or.
+func (c *Compiler) compileCond(clauses []ast.SExpression, tail bool) error {
+	endPositions := []int{}
+
+	for _, clause := range clauses {
+		forms, ok := listToSlice(clause)
+		if !ok || len(forms) == 0 {
+			return errUnsupported
+		}
+
+		if err := c.compile(forms[0], false); err != nil {
+			return err
+		}
+		// the value of the test is left on the stack if the clause has no body
+		if len(forms) == 1 {
+			c.emit(OpDup)
+		}
+		jumpIfNilPos := c.emit(OpJumpIfNil, 9999)
+
+		if len(forms) > 1 {
+			if err := c.compileBody(forms[1:], tail); err != nil {
+				return err
+			}
+		}
+		endPositions = append(endPositions, c.emit(OpJump, 9999))
+
+		c.changeOperand(jumpIfNilPos, len(c.scope().instructions))
+		if len(forms) == 1 {
+			c.emit(OpPop)
+		}
+	}
+	c.emit(OpNil)
+
+	for _, pos := range endPositions {
+		c.changeOperand(pos, len(c.scope().instructions))
+	}
+
+	return nil
+}
+
+// compileBody compiles the forms which are evaluated in order like progn
+func (c *Compiler) compileBody(body []ast.SExpression, tail bool) error {
+	if len(body) == 0 {
+		c.emit(OpNil)
+		return nil
+	}
+
+	for i, form := range body {
+		if i > 0 {
+			c.emit(OpPop)
+		}
+		if err := c.compile(form, tail && i == len(body)-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileSetq(args []ast.SExpression) error {
+	if len(args) != 2 {
+		return errUnsupported
+	}
+	// the evaluator reports the error for the keyword, which cannot be a variable
+	name, ok := ast.AsSymbol(args[0])
+	if !ok || name.IsKeyword() {
+		return errUnsupported
+	}
+
+	if err := c.compile(args[1], false); err != nil {
+		return err
+	}
+
+	symbol := c.scope().symbolTable.Resolve(name.Value)
+	switch symbol.Scope {
+	case LocalScope:
+		if symbol.Boxed {
+			c.emit(OpSetCell, symbol.Index)
+			c.emit(OpGetCell, symbol.Index)
+		} else {
+			c.emit(OpSetLocal, symbol.Index)
+			c.emit(OpGetLocal, symbol.Index)
+		}
+	case FreeScope:
+		c.emit(OpSetFree, symbol.Index)
+		c.emit(OpGetFree, symbol.Index)
+	default:
+		nameIndex := c.addName(name.Value)
+		c.emit(OpSetGlobal, nameIndex)
+		c.emit(OpGetGlobal, nameIndex)
+	}
+
+	return nil
+}
+
+// compileLet binds the variables in the new scope which shares the slots of the function.
+// the init forms of let are compiled before any variable is bound.
+func (c *Compiler) compileLet(args []ast.SExpression, sequential bool, tail bool) error {
+	if len(args) == 0 {
+		return errUnsupported
+	}
+	bindings, ok := listToSlice(args[0])
+	if !ok {
+		return errUnsupported
+	}
+
+	symbolTable := c.scope().symbolTable
+	names := make([]string, len(bindings))
+
+	if sequential {
+		symbolTable.PushScope()
+	}
+	for i, binding := range bindings {
+		name, init, err := parseLetBinding(binding)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+
+		if init == nil {
+			c.emit(OpNil)
+		} else if err := c.compile(init, false); err != nil {
+			return err
+		}
+
+		if sequential {
+			c.bind(symbolTable.Define(name, c.scope().captured[toKey(name)]))
+		}
+	}
+
+	if !sequential {
+		symbolTable.PushScope()
+		symbols := make([]Symbol, len(names))
+		for i, name := range names {
+			symbols[i] = symbolTable.Define(name, c.scope().captured[toKey(name)])
+		}
+		// the values are popped from the last one
+		for i := len(symbols) - 1; i >= 0; i-- {
+			c.bind(symbols[i])
+		}
+	}
+
+	if err := c.compileBody(args[1:], tail); err != nil {
+		return err
+	}
+	symbolTable.PopScope()
+
+	return nil
+}
+
+// bind pops the value into the slot of the local variable
+func (c *Compiler) bind(symbol Symbol) {
+	if symbol.Boxed {
+		c.emit(OpMakeCell, symbol.Index)
+	} else {
+		c.emit(OpSetLocal, symbol.Index)
+	}
+}
+
+func parseLetBinding(binding ast.SExpression) (string, ast.SExpression, error) {
+	if symbol, ok := ast.AsSymbol(binding); ok && !symbol.IsKeyword() {
+		return symbol.Value, nil, nil
+	}
+
+	elements, ok := listToSlice(binding)
+	if !ok || len(elements) == 0 || len(elements) > 2 {
+		return "", nil, errUnsupported
+	}
+	symbol, ok := ast.AsSymbol(elements[0])
+	if !ok || symbol.IsKeyword() {
+		return "", nil, errUnsupported
+	}
+
+	if len(elements) == 1 {
+		return symbol.Value, nil, nil
+	}
+	return symbol.Value, elements[1], nil
+}
+
+func (c *Compiler) compileDefun(args []ast.SExpression) error {
+	if len(args) < 2 {
+		return errUnsupported
+	}
+	// the evaluator reports the keyword which is not a function name
+	name, ok := args[0].(*ast.Symbol)
+	if !ok || name.IsKeyword() {
+		return errUnsupported
+	}
+
+	// the body is not wrapped in the block because return-from is not compiled
+	if err := c.compileFunction(args[1], args[2:]); err != nil {
+		return err
+	}
+	c.emit(OpDefun, c.addName(name.Value))
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionName(args []ast.SExpression) error {
+	if len(args) != 1 {
+		return errUnsupported
+	}
+
+	switch name := args[0].(type) {
+	case *ast.Symbol:
+		c.emit(OpGetFunction, c.addName(name.Value))
+		return nil
+	case *ast.ConsCell:
+		if isLambda(name) {
+			return c.compile(name, false)
+		}
+	}
+
+	return errUnsupported
+}
+
+// compileFunction compiles the function and emits the instructions which make its closure.
+// the lambda list may have the required parameters and &rest.
+func (c *Compiler) compileFunction(lambdaList ast.SExpression, body []ast.SExpression) error {
+	params, rest, err := parseLambdaList(lambdaList)
+	if err != nil {
+		return err
+	}
+
+	captured := map[string]bool{}
+	for _, form := range body {
+		for name := range capturedNames(form) {
+			captured[name] = true
+		}
+	}
+	c.scopes = append(c.scopes, &compilationScope{
+		symbolTable: NewEnclosedSymbolTable(c.scope().symbolTable),
+		captured:    captured,
+	})
+
+	if rest != "" {
+		params = append(params, rest)
+	}
+	for _, param := range params {
+		symbol := c.scope().symbolTable.Define(param, captured[toKey(param)])
+		if symbol.Boxed {
+			c.emit(OpGetLocal, symbol.Index)
+			c.emit(OpMakeCell, symbol.Index)
+		}
+	}
+
+	if err := c.compileBody(body, true); err != nil {
+		return err
+	}
+	c.emit(OpReturn)
+
+	scope := c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+
+	fn := &CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     scope.symbolTable.NumLocals(),
+		NumParameters: len(params),
+		Rest:          rest != "",
+		Bytecode:      c.bytecode,
+		Source:        functionSource(lambdaList, body),
+		Positions:     scope.positions,
+	}
+	if fn.Rest {
+		fn.NumParameters--
+	}
+
+	freeSymbols := scope.symbolTable.FreeSymbols
+	if len(freeSymbols) > 255 {
+		return errUnsupported
+	}
+	// the cells of the captured variables are pushed for the closure
+	for _, symbol := range freeSymbols {
+		switch {
+		case symbol.Scope == FreeScope:
+			c.emit(OpGetFreeCell, symbol.Index)
+		case symbol.Boxed:
+			c.emit(OpGetLocal, symbol.Index)
+		default:
+			return fmt.Errorf("variable %s is captured but not stored in a cell", symbol.Name)
+		}
+	}
+	c.emit(OpClosure, c.addConstant(fn), len(freeSymbols))
+
+	return nil
+}
+
+// parseLambdaList returns the names of the required parameters and the &rest parameter.
+// the other lambda list keywords are left to the evaluator.
+func parseLambdaList(lambdaList ast.SExpression) ([]string, string, error) {
+	elements, ok := listToSlice(lambdaList)
+	if !ok {
+		return nil, "", errUnsupported
+	}
+
+	params := []string{}
+	for i := 0; i < len(elements); i++ {
+		symbol, ok := ast.AsSymbol(elements[i])
+		if !ok || symbol.IsKeyword() {
+			return nil, "", errUnsupported
+		}
+
+		if strings.EqualFold(symbol.Value, "&rest") {
+			if i != len(elements)-2 {
+				return nil, "", errUnsupported
+			}
+			rest, ok := ast.AsSymbol(elements[i+1])
+			if !ok || rest.IsKeyword() || strings.HasPrefix(rest.Value, "&") {
+				return nil, "", errUnsupported
+			}
+			return params, rest.Value, nil
+		}
+		if strings.HasPrefix(symbol.Value, "&") {
+			return nil, "", errUnsupported
+		}
+
+		params = append(params, symbol.Value)
+	}
+
+	return params, "", nil
+}
+
+// functionSource prints the function as the evaluator prints its closure
+func functionSource(lambdaList ast.SExpression, body []ast.SExpression) string {
+	var out strings.Builder
+
+	out.WriteString("(lambda ")
+	if _, ok := lambdaList.(*ast.Nil); ok {
+		out.WriteString("()")
+	} else {
+		out.WriteString(lambdaList.String())
+	}
+	for _, form := range body {
+		out.WriteString(" ")
+		out.WriteString(form.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// capturedNames collects the names of the symbols in the lambda expressions and the defuns in sexp.
+// the local variables of these names are stored in cells so that the closures share them.
+func capturedNames(sexp ast.SExpression) map[string]bool {
+	names := map[string]bool{}
+
+	var walk func(sexp ast.SExpression, inFunction bool)
+	walk = func(sexp ast.SExpression, inFunction bool) {
+		switch sexp := sexp.(type) {
+		case *ast.Symbol, *ast.SpecialForm:
+			if inFunction {
+				names[toKey(sexp.String())] = true
+			}
+		case *ast.ConsCell:
+			if spForm, ok := sexp.Car().(*ast.SpecialForm); ok && (spForm.Value == "lambda" || spForm.Value == "defun") {
+				inFunction = true
+			}
+			walk(sexp.Car(), inFunction)
+			walk(sexp.Cdr(), inFunction)
+		}
+	}
+	walk(sexp, false)
+
+	return names
+}
+
+func isLambda(consCell *ast.ConsCell) bool {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	return ok && spForm.Value == "lambda"
+}
+
+// listToSlice returns the elements of the proper list
+func listToSlice(sexp ast.SExpression) ([]ast.SExpression, bool) {
+	elements := []ast.SExpression{}
+
+	for {
+		switch s := sexp.(type) {
+		case *ast.Nil:
+			return elements, true
+		case *ast.ConsCell:
+			elements = append(elements, s.Car())
+			sexp = s.Cdr()
+		default:
+			return nil, false
+		}
+	}
+}
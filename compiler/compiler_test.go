@@ -0,0 +1,270 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpGetFree, []int{255}, []byte{byte(OpGetFree), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpReturn, []int{}, []byte{byte(OpReturn)}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		if string(instruction) != string(tt.expected) {
+			t.Errorf("wrong instruction. got=%v, want=%v", instruction, tt.expected)
+		}
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %s", err)
+		}
+		operands, read := ReadOperands(def, instruction[1:])
+		if read != len(tt.expected)-1 {
+			t.Errorf("wrong number of bytes read. got=%d, want=%d", read, len(tt.expected)-1)
+		}
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("wrong operand. got=%d, want=%d", operands[i], want)
+			}
+		}
+	}
+}
+
+func testCompile(input string) *Bytecode {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	c := New()
+	c.Compile(program)
+	return c.Bytecode()
+}
+
+func concatInstructions(instructions ...[]byte) Instructions {
+	out := Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Instructions
+	}{
+		{
+			"1 2",
+			concatInstructions(
+				Make(OpConstant, 0),
+				Make(OpPop),
+				Make(OpConstant, 1),
+				Make(OpReturn),
+			),
+		},
+		{
+			"(if x 1)",
+			concatInstructions(
+				Make(OpGetGlobal, 0),
+				Make(OpJumpIfNil, 12),
+				Make(OpConstant, 1),
+				Make(OpJump, 13),
+				Make(OpNil),
+				Make(OpReturn),
+			),
+		},
+		{
+			"(let ((x 1)) (+ x 2))",
+			concatInstructions(
+				Make(OpConstant, 0),
+				Make(OpSetLocal, 0),
+				Make(OpGetFunction, 1),
+				Make(OpGetLocal, 0),
+				Make(OpConstant, 2),
+				Make(OpCall, 2),
+				Make(OpReturn),
+			),
+		},
+		{
+			"(let ((x 1)) (lambda () x))",
+			concatInstructions(
+				Make(OpConstant, 0),
+				Make(OpMakeCell, 0),
+				Make(OpGetLocal, 0),
+				Make(OpClosure, 1, 1),
+				Make(OpReturn),
+			),
+		},
+		{
+			"'(1 2) '(a 2) :k",
+			concatInstructions(
+				Make(OpConstant, 0),
+				Make(OpPop),
+				Make(OpQuote, 1),
+				Make(OpPop),
+				Make(OpQuote, 2),
+				Make(OpReturn),
+			),
+		},
+		{
+			"(or x 1)",
+			concatInstructions(
+				Make(OpGetGlobal, 0),
+				Make(OpDup),
+				Make(OpJumpIfNil, 10),
+				Make(OpJump, 14),
+				Make(OpPop),
+				Make(OpConstant, 1),
+				Make(OpReturn),
+			),
+		},
+		{
+			"(block b 1)",
+			concatInstructions(
+				Make(OpEval, 0),
+				Make(OpReturn),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := testCompile(tt.input)
+		if bytecode.Main.Instructions.String() != tt.expected.String() {
+			t.Errorf("wrong instructions for %q.\ngot:\n%swant:\n%s", tt.input, bytecode.Main.Instructions, tt.expected)
+		}
+	}
+}
+
+func TestCompileFunction(t *testing.T) {
+	bytecode := testCompile("(defun f (x &rest y) (g x y))")
+
+	fn, ok := bytecode.Constants[1].(*CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not CompiledFunction. got=%T", bytecode.Constants[1])
+	}
+	if fn.NumParameters != 1 || !fn.Rest || fn.NumLocals != 2 {
+		t.Errorf("wrong parameters. got NumParameters=%d, Rest=%t, NumLocals=%d", fn.NumParameters, fn.Rest, fn.NumLocals)
+	}
+	if fn.Inspect() != "(lambda (x &rest y) (g x y))" {
+		t.Errorf("wrong source. got=%s", fn.Inspect())
+	}
+
+	// the call in the tail position reuses the frame
+	expected := concatInstructions(
+		Make(OpGetFunction, 0),
+		Make(OpGetLocal, 0),
+		Make(OpGetLocal, 1),
+		Make(OpTailCall, 2),
+		Make(OpReturn),
+	)
+	if fn.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\ngot:\n%swant:\n%s", fn.Instructions, expected)
+	}
+}
+
+func TestCompileFreeVariables(t *testing.T) {
+	bytecode := testCompile("(lambda (x) (lambda () (lambda () x)))")
+
+	functions := []*CompiledFunction{}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*CompiledFunction); ok {
+			functions = append(functions, fn)
+		}
+	}
+	if len(functions) != 3 {
+		t.Fatalf("wrong number of functions. got=%d", len(functions))
+	}
+
+	// the innermost function is compiled first
+	tests := []Instructions{
+		concatInstructions(
+			Make(OpGetFree, 0),
+			Make(OpReturn),
+		),
+		concatInstructions(
+			Make(OpGetFreeCell, 0),
+			Make(OpClosure, 0, 1),
+			Make(OpReturn),
+		),
+		concatInstructions(
+			Make(OpGetLocal, 0),
+			Make(OpMakeCell, 0),
+			Make(OpGetLocal, 0),
+			Make(OpClosure, 1, 1),
+			Make(OpReturn),
+		),
+	}
+
+	for i, expected := range tests {
+		if functions[i].Instructions.String() != expected.String() {
+			t.Errorf("wrong instructions of function %d.\ngot:\n%swant:\n%s", i, functions[i].Instructions, expected)
+		}
+	}
+}
+
+func TestCompileFallback(t *testing.T) {
+	bytecode := testCompile("(defun f (x) x) (defun g (x) (return-from g x)) (f 1)")
+
+	if len(bytecode.Forms) != 1 {
+		t.Fatalf("wrong number of forms left to the evaluator. got=%d", len(bytecode.Forms))
+	}
+	if bytecode.Forms[0].String() != "(defun g (x) (return-from g x))" {
+		t.Errorf("wrong form. got=%s", bytecode.Forms[0].String())
+	}
+
+	// the constants of the abandoned form are discarded
+	for _, constant := range bytecode.Constants {
+		if name, ok := constant.(*Name); ok && name.Value == "g" {
+			t.Errorf("constant of the form left to the evaluator remains: %s", constant.Inspect())
+		}
+	}
+}
+
+func TestCompileOperandLimits(t *testing.T) {
+	// the constants after the 65536th do not fit in the operand, so the form using them is left to the evaluator
+	var literals strings.Builder
+	for i := 0; i < 70000; i++ {
+		fmt.Fprintf(&literals, "\"s%d\" ", i)
+	}
+	bytecode := testCompile(literals.String() + "(car x)")
+	if len(bytecode.Constants) > 65536 {
+		t.Errorf("too many constants. got=%d", len(bytecode.Constants))
+	}
+	if last := bytecode.Forms[len(bytecode.Forms)-1]; last.String() != "(car x)" {
+		t.Errorf("the last form is not left to the evaluator. got=%s", last.String())
+	}
+
+	// the jump over the body longer than 64 KB does not fit in the operand
+	var body strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&body, "%d ", i)
+	}
+	bytecode = testCompile("(defun big (x) (if x (progn " + body.String() + ") 7))")
+	if len(bytecode.Forms) != 1 {
+		t.Errorf("the function with the long body is not left to the evaluator")
+	}
+
+	// the forms after the last index of the forms are evaluated together
+	bytecode = testCompile(strings.Repeat("(block b) ", 65537) + "1")
+	if len(bytecode.Forms) != 65536 {
+		t.Fatalf("wrong number of forms left to the evaluator. got=%d", len(bytecode.Forms))
+	}
+	last, ok := bytecode.Forms[65535].(*ast.Program)
+	if !ok || len(last.Expressions) != 3 {
+		t.Errorf("the rest of the program is not left to the evaluator. got=%T", bytecode.Forms[65535])
+	}
+}
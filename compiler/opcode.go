@@ -0,0 +1,200 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
+
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant
+	OpConstant Opcode = iota
+	// OpQuote pushes the quoted data of the datum constant, whose symbols are interned in the environment
+	OpQuote
+	OpNil
+	OpTrue
+	OpPop
+	// OpDup pushes the value on the top of the stack again
+	OpDup
+
+	// OpJump and OpJumpIfNil jump to the absolute address. OpJumpIfNil pops the condition.
+	OpJump
+	OpJumpIfNil
+
+	// the operand of the global variables and functions is the constant index of the symbol naming them
+	OpGetGlobal
+	OpSetGlobal
+	OpGetFunction
+
+	// OpGetLocal pushes the content of the slot, which is a cell if the variable is captured by a closure
+	OpGetLocal
+	OpSetLocal
+	// OpMakeCell pops the value and stores it in a new cell in the slot
+	OpMakeCell
+	// OpGetCell and OpSetCell access the value in the cell of the slot
+	OpGetCell
+	OpSetCell
+
+	// OpGetFree and OpSetFree access the value in the cell captured by the closure.
+	// OpGetFreeCell pushes the cell itself to capture it again.
+	OpGetFree
+	OpSetFree
+	OpGetFreeCell
+
+	// OpClosure makes the closure of the compiled function constant with the cells on the stack
+	OpClosure
+	OpCall
+	// OpTailCall reuses the frame of the caller when the callee is a closure
+	OpTailCall
+	OpReturn
+
+	// OpDefun pops the closure and stores it in the function cell of the symbol
+	OpDefun
+	// OpEval evaluates the form by the tree-walking evaluator
+	OpEval
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:    {"OpConstant", []int{2}},
+	OpQuote:       {"OpQuote", []int{2}},
+	OpNil:         {"OpNil", []int{}},
+	OpTrue:        {"OpTrue", []int{}},
+	OpPop:         {"OpPop", []int{}},
+	OpDup:         {"OpDup", []int{}},
+	OpJump:        {"OpJump", []int{2}},
+	OpJumpIfNil:   {"OpJumpIfNil", []int{2}},
+	OpGetGlobal:   {"OpGetGlobal", []int{2}},
+	OpSetGlobal:   {"OpSetGlobal", []int{2}},
+	OpGetFunction: {"OpGetFunction", []int{2}},
+	OpGetLocal:    {"OpGetLocal", []int{2}},
+	OpSetLocal:    {"OpSetLocal", []int{2}},
+	OpMakeCell:    {"OpMakeCell", []int{2}},
+	OpGetCell:     {"OpGetCell", []int{2}},
+	OpSetCell:     {"OpSetCell", []int{2}},
+	OpGetFree:     {"OpGetFree", []int{1}},
+	OpSetFree:     {"OpSetFree", []int{1}},
+	OpGetFreeCell: {"OpGetFreeCell", []int{1}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpDefun:       {"OpDefun", []int{2}},
+	OpEval:        {"OpEval", []int{2}},
+}
+
+// maxOperand returns the largest operand which the width can encode
+func maxOperand(width int) int {
+	return 1<<(8*width) - 1
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes the instruction. the operands are big-endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of the instruction and returns the number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
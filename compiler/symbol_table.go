@@ -0,0 +1,105 @@
+package compiler
+
+import "strings"
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Boxed is true if the local variable is stored in a cell because a closure captures it
+	Boxed bool
+}
+
+// SymbolTable resolves the variables of a function.
+// let and let* push nested scopes which share the slots of the function.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	scopes    []map[string]Symbol
+	numLocals int
+	free      map[string]Symbol
+
+	// FreeSymbols are the symbols of the outer function which the closure captures, in the order of the free indexes
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		scopes: []map[string]Symbol{{}},
+		free:   map[string]Symbol{},
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// NumLocals is the number of the slots which the function needs
+func (s *SymbolTable) NumLocals() int {
+	return s.numLocals
+}
+
+func (s *SymbolTable) PushScope() {
+	s.scopes = append(s.scopes, map[string]Symbol{})
+}
+
+func (s *SymbolTable) PopScope() {
+	s.scopes = s.scopes[:len(s.scopes)-1]
+}
+
+// Define allocates a new slot for the local variable in the innermost scope
+func (s *SymbolTable) Define(name string, boxed bool) Symbol {
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: s.numLocals, Boxed: boxed}
+	s.scopes[len(s.scopes)-1][toKey(name)] = symbol
+	s.numLocals++
+	return symbol
+}
+
+// Resolve returns the local variable, the variable captured from the outer functions or the global variable
+func (s *SymbolTable) Resolve(name string) Symbol {
+	key := toKey(name)
+
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if symbol, ok := s.scopes[i][key]; ok {
+			return symbol
+		}
+	}
+
+	if symbol, ok := s.free[key]; ok {
+		return symbol
+	}
+
+	if s.Outer == nil {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+
+	outer := s.Outer.Resolve(name)
+	if outer.Scope == GlobalScope {
+		return outer
+	}
+
+	return s.defineFree(outer)
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1, Boxed: true}
+	s.free[toKey(original.Name)] = symbol
+	return symbol
+}
+
+// variables are case-insensitive like the environment of the evaluator
+func toKey(name string) string {
+	return strings.ToUpper(name)
+}
@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// the functions below are used by the bytecode vm,
+// which shares the objects, the builtin functions and the condition system with the evaluator
+
+// Apply calls the function with the arguments.
+// the error returned by a builtin function has not been signaled yet.
+func Apply(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, env)
+}
+
+// LookupFunction returns the global function named name or the builtin function
+func LookupFunction(name string, env *object.Environment) object.Object {
+	return lookupFunction(name, env)
+}
+
+// SignalError gives the handlers a chance to handle obj if it is an error which has not been signaled
+func SignalError(obj object.Object, env *object.Environment) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Signaled {
+		return signalError(err, env)
+	}
+	return obj
+}
+
+// IsUnwinding reports whether obj is an error or a non-local exit, which aborts the evaluation
+func IsUnwinding(obj object.Object) bool {
+	return isUnwinding(obj)
+}
+
+// Quote returns the data which the s-expression represents, as the quote special form does.
+// the symbols are interned in env, which is nil if sexp has no symbols.
+func Quote(sexp ast.SExpression, env *object.Environment) object.Object {
+	return convertSExpressionToObject(sexp, env)
+}
+
+// ProgramResult converts the non-local exit which reached the top level into the error, as a program does
+func ProgramResult(obj object.Object) object.Object {
+	if exit, ok := obj.(*object.NonLocalExit); ok {
+		return newExitError(exit)
+	}
+	return obj
+}
@@ -1,252 +1,329 @@
-package evaluator
-
-import (
-	"github.com/JunNishimura/go-lisp/object"
-)
-
-func getBuiltinFunctions(funcName string) (*object.Builtin, bool) {
-	switch funcName {
-	case "+":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				var sum int64
-				for _, arg := range args {
-					if arg.Type() != object.INTEGER_OBJ {
-						return newError("argument to `+` must be INTEGER, got %s", arg.Type())
-					}
-					sum += arg.(*object.Integer).Value
-				}
-				return &object.Integer{Value: sum}
-			},
-		}, true
-	case "-":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if len(args) == 1 {
-					if args[0].Type() != object.INTEGER_OBJ {
-						return newError("argument to `-` must be INTEGER, got %s", args[0].Type())
-					}
-					return &object.Integer{Value: -args[0].(*object.Integer).Value}
-				}
-
-				var diff int64
-				for i, arg := range args {
-					if arg.Type() != object.INTEGER_OBJ {
-						return newError("argument to `-` must be INTEGER, got %s", arg.Type())
-					}
-					if i == 0 {
-						diff = arg.(*object.Integer).Value
-					} else {
-						diff -= arg.(*object.Integer).Value
-					}
-				}
-				return &object.Integer{Value: diff}
-			},
-		}, true
-	case "*":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				var product int64 = 1
-				for _, arg := range args {
-					if arg.Type() != object.INTEGER_OBJ {
-						return newError("argument to `*` must be INTEGER, got %s", arg.Type())
-					}
-					product *= arg.(*object.Integer).Value
-				}
-				return &object.Integer{Value: product}
-			},
-		}, true
-	case "/":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-				if len(args) == 1 {
-					if args[0].Type() != object.INTEGER_OBJ {
-						return newError("argument to `/` must be INTEGER, got %s", args[0].Type())
-					}
-					return &object.Integer{Value: 1 / args[0].(*object.Integer).Value}
-				}
-
-				var quotient int64
-				for i, arg := range args {
-					if arg.Type() != object.INTEGER_OBJ {
-						return newError("argument to `/` must be INTEGER, got %s", arg.Type())
-					}
-					if i == 0 {
-						quotient = arg.(*object.Integer).Value
-					} else {
-						quotient /= arg.(*object.Integer).Value
-					}
-				}
-				return &object.Integer{Value: quotient}
-			},
-		}, true
-	case "=":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `=` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `=` must be INTEGER, got %s", arg.Type())
-					}
-					if compFrom.Value != compTo.Value {
-						return Nil
-					}
-				}
-				return True
-			},
-		}, true
-	case "/=":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `/=` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `/=` must be INTEGER, got %s", arg.Type())
-					}
-					if compFrom.Value == compTo.Value {
-						return Nil
-					}
-				}
-				return True
-			},
-		}, true
-	case "<":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `<` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `<` must be INTEGER, got %s", arg.Type())
-					}
-					if compTo.Value >= compFrom.Value {
-						return Nil
-					}
-					compTo = compFrom
-				}
-				return True
-			},
-		}, true
-	case "<=":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `<=` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `<=` must be INTEGER, got %s", arg.Type())
-					}
-					if compTo.Value > compFrom.Value {
-						return Nil
-					}
-					compTo = compFrom
-				}
-				return True
-			},
-		}, true
-	case ">":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `>` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `>` must be INTEGER, got %s", arg.Type())
-					}
-					if compTo.Value <= compFrom.Value {
-						return Nil
-					}
-					compTo = compFrom
-				}
-				return True
-			},
-		}, true
-	case ">=":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) == 0 {
-					return newError("wrong number of arguments. got=%d, want=1", len(args))
-				}
-
-				compTo, ok := args[0].(*object.Integer)
-				if !ok {
-					return newError("argument to `>=` must be INTEGER, got %s", args[0].Type())
-				}
-				for _, arg := range args[1:] {
-					compFrom, ok := arg.(*object.Integer)
-					if !ok {
-						return newError("argument to `>=` must be INTEGER, got %s", arg.Type())
-					}
-					if compTo.Value < compFrom.Value {
-						return Nil
-					}
-					compTo = compFrom
-				}
-				return True
-			},
-		}, true
-	case "apply":
-		return &object.Builtin{
-			Fn: func(env *object.Environment, args ...object.Object) object.Object {
-				if len(args) != 2 {
-					return newError("wrong number of arguments. got=%d, want=2", len(args))
-				}
-
-				var evaluatedArgs []object.Object
-				quote, ok := args[1].(*object.Quote)
-				if ok {
-					evaluatedArgs = evalArgs(quote.SExpression, env)
-				} else if _, isNil := args[1].(*object.Nil); !isNil {
-					return newError("second argument to `apply` must be QUOTE, got %s", args[1].Type())
-				}
-
-				return applyFunction(args[0], evaluatedArgs, env)
-			},
-		}, true
-	default:
-		return nil, false
-	}
-}
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/JunNishimura/go-lisp/ast"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "+":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				var sum object.Object = &object.Integer{Value: 0}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newConditionError("type-error", "argument to `+` must be NUMBER, got %s", arg.Type())
+					}
+					sum = addNumbers(sum, arg)
+				}
+				return sum
+			},
+		}, true
+	case "-":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newConditionError("type-error", "argument to `-` must be NUMBER, got %s", arg.Type())
+					}
+				}
+				if len(args) == 1 {
+					return negateNumber(args[0])
+				}
+
+				diff := args[0]
+				for _, arg := range args[1:] {
+					diff = subtractNumbers(diff, arg)
+				}
+				return diff
+			},
+		}, true
+	case "*":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				var product object.Object = &object.Integer{Value: 1}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newConditionError("type-error", "argument to `*` must be NUMBER, got %s", arg.Type())
+					}
+					product = multiplyNumbers(product, arg)
+				}
+				return product
+			},
+		}, true
+	case "/":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newConditionError("type-error", "argument to `/` must be NUMBER, got %s", arg.Type())
+					}
+				}
+				if len(args) == 1 {
+					return divideNumbers(&object.Integer{Value: 1}, args[0])
+				}
+
+				quotient := args[0]
+				for _, arg := range args[1:] {
+					quotient = divideNumbers(quotient, arg)
+					if isError(quotient) {
+						return quotient
+					}
+				}
+				return quotient
+			},
+		}, true
+	case "=":
+		return compareBuiltin("=", func(cmp int) bool { return cmp == 0 })
+	case "/=":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if err := checkNumberArgs("/=", args); err != nil {
+					return err
+				}
+
+				// every pair of the arguments must be different
+				for i, compTo := range args {
+					for _, compFrom := range args[i+1:] {
+						if compareNumbers(compTo, compFrom) == 0 {
+							return Nil
+						}
+					}
+				}
+				return True
+			},
+		}, true
+	case "<":
+		return compareBuiltin("<", func(cmp int) bool { return cmp < 0 })
+	case "<=":
+		return compareBuiltin("<=", func(cmp int) bool { return cmp <= 0 })
+	case ">":
+		return compareBuiltin(">", func(cmp int) bool { return cmp > 0 })
+	case ">=":
+		return compareBuiltin(">=", func(cmp int) bool { return cmp >= 0 })
+	case "float":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if !isNumber(args[0]) {
+					return newConditionError("type-error", "argument to `float` must be NUMBER, got %s", args[0].Type())
+				}
+				return &object.Float{Value: toFloat(args[0])}
+			},
+		}, true
+	case "numerator", "denominator":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				kind, ok := numberKindOf(args[0])
+				if !ok || kind == floatKind {
+					return newConditionError("type-error", "argument to `%s` must be RATIONAL, got %s", funcName, args[0].Type())
+				}
+
+				rat := toRat(args[0])
+				if funcName == "numerator" {
+					return normalizeRatio(new(big.Rat).SetInt(rat.Num()))
+				}
+				return normalizeRatio(new(big.Rat).SetInt(rat.Denom()))
+			},
+		}, true
+	case "numberp", "integerp", "rationalp", "floatp":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				kind, ok := numberKindOf(args[0])
+				switch {
+				case !ok:
+					return Nil
+				case funcName == "integerp" && kind != integerKind,
+					funcName == "rationalp" && kind == floatKind,
+					funcName == "floatp" && kind != floatKind:
+					return Nil
+				}
+				return True
+			},
+		}, true
+	case "apply":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				// the last argument is the list of the rest of the arguments
+				lastArgs, ok := listToSlice(args[len(args)-1])
+				if !ok {
+					return newConditionError("type-error", "last argument to `apply` must be LIST, got %s", args[len(args)-1].Type())
+				}
+				spreadArgs := append(args[1:len(args)-1:len(args)-1], lastArgs...)
+
+				return applyFunction(args[0], spreadArgs, env)
+			},
+		}, true
+	case "funcall":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				return applyFunction(args[0], args[1:], env)
+			},
+		}, true
+	case "fboundp":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				symbol, ok := args[0].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "argument to `fboundp` must be SYMBOL, got %s", args[0].Type())
+				}
+
+				name := designatorName(symbol)
+				if _, ok := env.MacroEnvironment().GetMacro(name); ok {
+					return True
+				}
+				if isError(lookupFunction(name, env)) {
+					return Nil
+				}
+				return True
+			},
+		}, true
+	case "fmakunbound":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				symbol, ok := args[0].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "argument to `fmakunbound` must be SYMBOL, got %s", args[0].Type())
+				}
+
+				// keywords have no function definitions
+				if symbol.IsKeyword() {
+					return symbol
+				}
+				env.Intern(symbol.Name).Function = nil
+				env.MacroEnvironment().RemoveMacro(symbol.Name)
+				return symbol
+			},
+		}, true
+	case "macro-function":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				symbol, ok := args[0].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "argument to `macro-function` must be SYMBOL, got %s", args[0].Type())
+				}
+
+				if macro, ok := env.MacroEnvironment().GetMacro(designatorName(symbol)); ok {
+					return macro
+				}
+				return Nil
+			},
+		}, true
+	case "macroexpand-1", "macroexpand":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				// macroexpand expands the form repeatedly until it is no longer a macro call
+				form := args[0]
+				for {
+					consCell, ok := convertObjectToSExpression(form).(*ast.ConsCell)
+					if !ok {
+						return form
+					}
+
+					expanded, ok, err := expandMacroCall(consCell, env.MacroEnvironment())
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return form
+					}
+
+					form = convertSExpressionToObject(expanded, env)
+					if funcName == "macroexpand-1" {
+						return form
+					}
+				}
+			},
+		}, true
+	default:
+		if builtin, ok := getStringBuiltinFunctions(funcName); ok {
+			return builtin, true
+		}
+		if builtin, ok := getConditionBuiltinFunctions(funcName); ok {
+			return builtin, true
+		}
+		if builtin, ok := getLoadBuiltinFunctions(funcName); ok {
+			return builtin, true
+		}
+		if builtin, ok := getSymbolBuiltinFunctions(funcName); ok {
+			return builtin, true
+		}
+		if builtin, ok := getTypeBuiltinFunctions(funcName); ok {
+			return builtin, true
+		}
+		return getListBuiltinFunctions(funcName)
+	}
+}
+
+// compareBuiltin returns a builtin which checks that every adjacent pair of the arguments satisfies pred
+func compareBuiltin(funcName string, pred func(cmp int) bool) (*object.Builtin, bool) {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkNumberArgs(funcName, args); err != nil {
+				return err
+			}
+
+			for i := 1; i < len(args); i++ {
+				if !pred(compareNumbers(args[i-1], args[i])) {
+					return Nil
+				}
+			}
+			return True
+		},
+	}, true
+}
+
+// checkNumberArgs checks that at least one argument is given and all of them are numbers
+func checkNumberArgs(funcName string, args []object.Object) *object.Error {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newConditionError("type-error", "argument to `%s` must be NUMBER, got %s", funcName, arg.Type())
+		}
+	}
+
+	return nil
+}
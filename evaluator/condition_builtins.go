@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getConditionBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "error":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				condition, err := coerceToCondition("error", args, "simple-error", env)
+				if err != nil {
+					return err
+				}
+
+				// the error is signaled by Eval when it is returned
+				return &object.Error{Message: conditionReport(condition, env), Condition: condition}
+			},
+		}, true
+	case "cerror":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				condition, err := coerceToCondition("cerror", args[1:], "simple-error", env)
+				if err != nil {
+					return err
+				}
+
+				// the continue restart makes cerror return nil
+				restart := &object.Restart{Name: "continue", Point: &object.ExitPoint{Name: "continue", Active: true}}
+				env.PushRestart(restart)
+				result := signalCondition(condition, env)
+				env.PopRestart()
+				restart.Point.Active = false
+
+				if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == restart.Point {
+					return Nil
+				}
+				if result != nil {
+					return result
+				}
+				return &object.Error{Message: conditionReport(condition, env), Condition: condition, Signaled: true}
+			},
+		}, true
+	case "signal":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				condition, err := coerceToCondition("signal", args, "simple-condition", env)
+				if err != nil {
+					return err
+				}
+
+				if result := signalCondition(condition, env); result != nil {
+					return result
+				}
+				return Nil
+			},
+		}, true
+	case "warn":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				condition, err := coerceToCondition("warn", args, "simple-warning", env)
+				if err != nil {
+					return err
+				}
+				if !condition.ConditionType.IsSubtypeOf("warning") {
+					return newConditionError("type-error", "argument to `warn` must be WARNING, got %s", condition.Inspect())
+				}
+
+				// the muffle-warning restart stops printing the warning
+				restart := &object.Restart{Name: "muffle-warning", Point: &object.ExitPoint{Name: "muffle-warning", Active: true}}
+				env.PushRestart(restart)
+				result := signalCondition(condition, env)
+				env.PopRestart()
+				restart.Point.Active = false
+
+				if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == restart.Point {
+					return Nil
+				}
+				if result != nil {
+					return result
+				}
+
+				fmt.Fprintf(warningOutput, "WARNING: %s\n", conditionReport(condition, env))
+				return Nil
+			},
+		}, true
+	case "make-condition":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				symbol, ok := args[0].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "first argument to `make-condition` must be SYMBOL, got %s", args[0].Type())
+				}
+				conditionType, ok := lookupConditionType(designatorName(symbol), env)
+				if !ok {
+					return newError("unknown condition type: %s", designatorName(symbol))
+				}
+
+				condition, err := makeCondition(conditionType, args[1:], env)
+				if err != nil {
+					return err
+				}
+				return condition
+			},
+		}, true
+	case "invoke-restart":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch designator := args[0].(type) {
+				case *object.Restart:
+					if !designator.Point.Active {
+						return newConditionError("control-error", "invoke-restart: restart %s is not active", designator.Name)
+					}
+					return invokeRestart(designator, args[1:])
+				case *object.Symbol:
+					restart, ok := findRestart(designatorName(designator), env)
+					if !ok {
+						return newConditionError("control-error", "invoke-restart: no restart named %s is active", designatorName(designator))
+					}
+					return invokeRestart(restart, args[1:])
+				default:
+					return newConditionError("type-error", "first argument to `invoke-restart` must be RESTART or SYMBOL, got %s", designator.Type())
+				}
+			},
+		}, true
+	case "use-value", "store-value", "continue":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				// these return nil if the restart is not active
+				restart, ok := findRestart(funcName, env)
+				if !ok {
+					return Nil
+				}
+				return invokeRestart(restart, args)
+			},
+		}, true
+	case "muffle-warning", "abort":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				restart, ok := findRestart(funcName, env)
+				if !ok {
+					return newConditionError("control-error", "%s: no restart named %s is active", funcName, funcName)
+				}
+				return invokeRestart(restart, nil)
+			},
+		}, true
+	case "find-restart":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				symbol, ok := args[0].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "argument to `find-restart` must be SYMBOL, got %s", args[0].Type())
+				}
+
+				restart, ok := findRestart(designatorName(symbol), env)
+				if !ok {
+					return Nil
+				}
+				return restart
+			},
+		}, true
+	case "compute-restarts":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				restarts := env.Restarts()
+
+				// the innermost restart comes first
+				list := []object.Object{}
+				for i := len(restarts) - 1; i >= 0; i-- {
+					list = append(list, restarts[i])
+				}
+				return sliceToList(list)
+			},
+		}, true
+	case "slot-value":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				symbol, ok := args[1].(*object.Symbol)
+				if !ok {
+					return newConditionError("type-error", "second argument to `slot-value` must be SYMBOL, got %s", args[1].Type())
+				}
+				return conditionSlotValue(args[0], designatorName(symbol))
+			},
+		}, true
+	case "princ-to-string":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				return &object.String{Value: princString(args[0], env)}
+			},
+		}, true
+	case "format":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				control, ok := args[1].(*object.String)
+				if !ok {
+					return newConditionError("type-error", "second argument to `format` must be STRING, got %s", args[1].Type())
+				}
+
+				str, err := formatString(control.Value, args[2:], env)
+				if err != nil {
+					return err
+				}
+
+				switch args[0].(type) {
+				case *object.Nil:
+					return &object.String{Value: str}
+				case *object.True:
+					fmt.Fprint(env.Output(), str)
+					return Nil
+				default:
+					return newConditionError("type-error", "first argument to `format` must be NIL or T, got %s", args[0].Inspect())
+				}
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
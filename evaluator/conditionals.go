@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// evalCond evaluates the test of each clause (test . body) in order, and the body of the first one which is true.
+// the clause without body returns the value of the test.
+func evalCond(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	clauses, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("cond clauses must be a list, got %s", consCell.Cdr().String()), nil
+	}
+
+	for _, sexp := range clauses {
+		clause, ok := sexp.(*ast.ConsCell)
+		if !ok {
+			return newError("invalid cond clause: %s", sexp.String()), nil
+		}
+		body, ok := astListToSlice(clause.Cdr())
+		if !ok {
+			return newError("cond clause body must be a list, got %s", clause.Cdr().String()), nil
+		}
+
+		test := Eval(clause.Car(), env)
+		if isUnwinding(test) {
+			return test, nil
+		}
+		if !isTruthy(test) {
+			continue
+		}
+
+		if len(body) == 0 {
+			return test, nil
+		}
+		return evalBodyTail(body, env)
+	}
+
+	return Nil, nil
+}
+
+// evalWhen evaluates the body if the test is true (when), or if it is false (unless)
+func evalWhen(consCell *ast.ConsCell, negate bool, env *object.Environment) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined %s condition", name), nil
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("%s body must be a list, got %s", name, cdr.Cdr().String()), nil
+	}
+
+	test := Eval(cdr.Car(), env)
+	if isUnwinding(test) {
+		return test, nil
+	}
+	if isTruthy(test) == negate {
+		return Nil, nil
+	}
+
+	return evalBodyTail(body, env)
+}
+
+// evalAnd evaluates the forms in order until one of them is false (and), or true (or).
+// the last form is in the tail position, as its value is the value of the whole form.
+func evalAnd(consCell *ast.ConsCell, isOr bool, env *object.Environment) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	forms, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("%s forms must be a list, got %s", name, consCell.Cdr().String()), nil
+	}
+	if len(forms) == 0 {
+		if isOr {
+			return Nil, nil
+		}
+		return True, nil
+	}
+
+	for _, form := range forms[:len(forms)-1] {
+		value := Eval(form, env)
+		if isUnwinding(value) {
+			return value, nil
+		}
+		if isTruthy(value) == isOr {
+			return value, nil
+		}
+	}
+
+	return nil, &tailForm{sexp: forms[len(forms)-1], env: env}
+}
+
+// evalCase evaluates the body of the first clause (keys . body) whose keys include the value of the key form.
+// the keys are compared by eql, and a clause of t or otherwise matches any value.
+// ecase signals a type-error if no clause matches.
+func evalCase(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	return evalCaseClauses(consCell, env, func(keys ast.SExpression, key object.Object) (bool, object.Object) {
+		if keys, ok := keys.(*ast.ConsCell); ok {
+			keyList, ok := astListToSlice(keys)
+			if !ok {
+				return false, newError("%s keys must be a list, got %s", name, keys.String())
+			}
+			for _, k := range keyList {
+				if isEql(convertSExpressionToObject(k, env), key) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		// nil is the empty list of keys, which matches nothing
+		if _, ok := keys.(*ast.Nil); ok {
+			return false, nil
+		}
+		return isEql(convertSExpressionToObject(keys, env), key), nil
+	})
+}
+
+// evalTypecase evaluates the body of the first clause (type . body) whose type the value of the key form is of.
+// etypecase signals a type-error if no clause matches.
+func evalTypecase(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	return evalCaseClauses(consCell, env, func(typeSpec ast.SExpression, key object.Object) (bool, object.Object) {
+		return isOfType(key, convertSExpressionToObject(typeSpec, env), env)
+	})
+}
+
+// evalCaseClauses evaluates the key form and the body of the first clause which matches it.
+// the last clause of otherwise matches any value, and so does that of t in case.
+func evalCaseClauses(consCell *ast.ConsCell, env *object.Environment, match func(ast.SExpression, object.Object) (bool, object.Object)) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+	exhaustive := strings.HasPrefix(name, "e")
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined %s key form", name), nil
+	}
+
+	clauses, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("%s clauses must be a list, got %s", name, cdr.Cdr().String()), nil
+	}
+
+	key := Eval(cdr.Car(), env)
+	if isUnwinding(key) {
+		return key, nil
+	}
+
+	for i, sexp := range clauses {
+		clause, ok := sexp.(*ast.ConsCell)
+		if !ok {
+			return newError("invalid %s clause: %s", name, sexp.String()), nil
+		}
+		body, ok := astListToSlice(clause.Cdr())
+		if !ok {
+			return newError("%s clause body must be a list, got %s", name, clause.Cdr().String()), nil
+		}
+
+		if isOtherwiseClause(clause, name) {
+			if exhaustive {
+				return newError("%s does not allow the otherwise clause", name), nil
+			}
+			if i != len(clauses)-1 {
+				return newError("%s: the otherwise clause must be the last one", name), nil
+			}
+			return evalBodyTail(body, env)
+		}
+
+		matched, err := match(clause.Car(), key)
+		if err != nil {
+			return err, nil
+		}
+		if matched {
+			return evalBodyTail(body, env)
+		}
+	}
+
+	if exhaustive {
+		return newConditionError("type-error", "%s: %s fell through", name, key.Inspect()), nil
+	}
+	return Nil, nil
+}
+
+func isOtherwiseClause(clause *ast.ConsCell, name string) bool {
+	switch car := clause.Car().(type) {
+	case *ast.True:
+		// t is the type which every object is of in typecase
+		return !strings.HasSuffix(name, "typecase")
+	case *ast.Symbol:
+		return strings.EqualFold(car.Value, "otherwise")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,720 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// warningOutput is where warn prints the warnings which are not handled
+var warningOutput io.Writer = os.Stderr
+
+// builtinConditionTypes are the standard condition types which every environment shares
+var builtinConditionTypes = newBuiltinConditionTypes()
+
+func newBuiltinConditionTypes() map[string]*object.ConditionType {
+	types := map[string]*object.ConditionType{}
+
+	define := func(name string, parents []string, slots ...string) {
+		conditionType := &object.ConditionType{Name: name}
+		for _, parent := range parents {
+			conditionType.Parents = append(conditionType.Parents, types[parent])
+		}
+		for _, slot := range slots {
+			conditionType.Slots = append(conditionType.Slots, &object.SlotDefinition{Name: slot, Initarg: slot})
+		}
+		types[name] = conditionType
+	}
+
+	define("condition", nil)
+	define("serious-condition", []string{"condition"})
+	define("error", []string{"serious-condition"})
+	define("warning", []string{"condition"})
+	define("simple-condition", []string{"condition"}, "format-control", "format-arguments")
+	define("simple-error", []string{"simple-condition", "error"})
+	define("simple-warning", []string{"simple-condition", "warning"})
+	define("arithmetic-error", []string{"error"}, "operation", "operands")
+	define("division-by-zero", []string{"arithmetic-error"})
+	define("type-error", []string{"error"}, "datum", "expected-type")
+	define("cell-error", []string{"error"}, "name")
+	define("unbound-variable", []string{"cell-error"})
+	define("undefined-function", []string{"cell-error"})
+	define("control-error", []string{"error"})
+	define("program-error", []string{"error"})
+
+	return types
+}
+
+// lookupConditionType returns the condition type defined by define-condition or the standard one
+func lookupConditionType(name string, env *object.Environment) (*object.ConditionType, bool) {
+	if conditionType, ok := env.GetConditionType(name); ok {
+		return conditionType, true
+	}
+
+	conditionType, ok := builtinConditionTypes[strings.ToLower(name)]
+	return conditionType, ok
+}
+
+// newConditionError creates the error whose condition is of the standard condition type
+func newConditionError(typeName string, format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Condition = &object.Condition{
+		ConditionType: builtinConditionTypes[typeName],
+		Slots:         map[string]object.Object{},
+		Message:       err.Message,
+	}
+	return err
+}
+
+// errorCondition returns the condition of the error, making a simple error from its message if it has none
+func errorCondition(err *object.Error) *object.Condition {
+	if err.Condition == nil {
+		err.Condition = &object.Condition{
+			ConditionType: builtinConditionTypes["simple-error"],
+			Slots: map[string]object.Object{
+				"format-control":   &object.String{Value: err.Message},
+				"format-arguments": Nil,
+			},
+			Message: err.Message,
+		}
+	}
+	return err.Condition
+}
+
+// signalError gives the handlers a chance to handle the error before it is propagated
+func signalError(err *object.Error, env *object.Environment) object.Object {
+	err.Signaled = true
+
+	if result := signalCondition(errorCondition(err), env); result != nil {
+		return result
+	}
+	return err
+}
+
+// signalCondition calls the applicable handlers from the innermost one.
+// it returns nil if all of them decline, that is, return normally.
+// otherwise it returns the non-local exit or the error by which a handler transferred the control.
+func signalCondition(condition *object.Condition, env *object.Environment) object.Object {
+	handlers := env.Handlers()
+
+	for i := len(handlers) - 1; i >= 0; i-- {
+		for _, handler := range handlers[i] {
+			if !isConditionOfType(condition, handler.TypeName) {
+				continue
+			}
+
+			// the handler runs with only the handlers which were established outside of it.
+			// the capacity is limited so that the handlers established by the handler do not overwrite the inner ones.
+			env.SetHandlers(handlers[:i:i])
+			result := applyFunction(handler.Function, []object.Object{condition}, env)
+			env.SetHandlers(handlers)
+
+			if isUnwinding(result) {
+				return result
+			}
+		}
+	}
+
+	return nil
+}
+
+func isConditionOfType(condition *object.Condition, typeName string) bool {
+	return strings.EqualFold(typeName, "t") || condition.ConditionType.IsSubtypeOf(typeName)
+}
+
+// conditionReport returns the message which describes the condition
+func conditionReport(condition *object.Condition, env *object.Environment) string {
+	switch report := findReport(condition.ConditionType).(type) {
+	case nil:
+		if condition.Message != "" {
+			return condition.Message
+		}
+		return fmt.Sprintf("condition %s was signaled", condition.ConditionType.Name)
+	case *object.String:
+		return report.Value
+	default:
+		// the report function may take a stream as the second argument, to which nil is passed
+		args := []object.Object{condition}
+		if fn, ok := report.(*object.Function); ok && len(fn.Parameters) == 2 {
+			args = append(args, Nil)
+		}
+
+		result := applyFunction(report, args, env)
+		if str, ok := result.(*object.String); ok {
+			return str.Value
+		}
+		return result.Inspect()
+	}
+}
+
+// findReport returns the report of the type or the nearest ancestor which has one
+func findReport(conditionType *object.ConditionType) object.Object {
+	if conditionType.Report != nil {
+		return conditionType.Report
+	}
+
+	for _, parent := range conditionType.Parents {
+		if report := findReport(parent); report != nil {
+			return report
+		}
+	}
+
+	return nil
+}
+
+// makeCondition creates the condition of the type initialized by the initargs such as :slot value
+func makeCondition(conditionType *object.ConditionType, initargs []object.Object, env *object.Environment) (*object.Condition, object.Object) {
+	if len(initargs)%2 != 0 {
+		return nil, newError("odd number of initargs: %s", sliceToList(initargs).Inspect())
+	}
+
+	condition := &object.Condition{
+		ConditionType: conditionType,
+		Slots:         map[string]object.Object{},
+	}
+
+	slots, owners := collectSlots(conditionType)
+
+	for i := 0; i < len(initargs); i += 2 {
+		keyword, ok := initargs[i].(*object.Symbol)
+		if !ok {
+			return nil, newError("initarg must be SYMBOL, got %s", initargs[i].Inspect())
+		}
+
+		name := keyword.Name
+		found := false
+		for _, slot := range slots {
+			if slot.Initarg != "" && strings.EqualFold(slot.Initarg, name) {
+				// the leftmost initarg wins like keyword arguments
+				if _, ok := condition.Slots[strings.ToLower(slot.Name)]; !ok {
+					condition.Slots[strings.ToLower(slot.Name)] = initargs[i+1]
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, newError("unknown initarg %s for condition %s", keyword.Inspect(), conditionType.Name)
+		}
+	}
+
+	for i, slot := range slots {
+		if _, ok := condition.Slots[strings.ToLower(slot.Name)]; ok || slot.Initform == nil {
+			continue
+		}
+
+		value := Eval(slot.Initform, owners[i].Env)
+		if isUnwinding(value) {
+			return nil, value
+		}
+		condition.Slots[strings.ToLower(slot.Name)] = value
+	}
+
+	if control, ok := condition.Slots["format-control"].(*object.String); ok {
+		args, _ := listToSlice(condition.Slots["format-arguments"])
+		message, err := formatString(control.Value, args, env)
+		if err != nil {
+			return nil, err
+		}
+		condition.Message = message
+	}
+
+	return condition, nil
+}
+
+// collectSlots returns the slots of the type and its ancestors, and the types which define them
+func collectSlots(conditionType *object.ConditionType) ([]*object.SlotDefinition, []*object.ConditionType) {
+	slots := []*object.SlotDefinition{}
+	owners := []*object.ConditionType{}
+
+	var collect func(ct *object.ConditionType)
+	collect = func(ct *object.ConditionType) {
+		for _, slot := range ct.Slots {
+			slots = append(slots, slot)
+			owners = append(owners, ct)
+		}
+		for _, parent := range ct.Parents {
+			collect(parent)
+		}
+	}
+	collect(conditionType)
+
+	return slots, owners
+}
+
+// coerceToCondition makes the condition from the arguments of error, signal and warn.
+// the datum is a condition, a condition type name followed by initargs,
+// or a format control followed by format arguments which makes the condition of the default type.
+func coerceToCondition(funcName string, args []object.Object, defaultType string, env *object.Environment) (*object.Condition, object.Object) {
+	if len(args) == 0 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch datum := args[0].(type) {
+	case *object.Condition:
+		if len(args) > 1 {
+			return nil, newError("`%s` takes no initargs with a condition, got %s", funcName, sliceToList(args[1:]).Inspect())
+		}
+		return datum, nil
+	case *object.Symbol:
+		conditionType, ok := lookupConditionType(designatorName(datum), env)
+		if !ok {
+			return nil, newError("unknown condition type: %s", designatorName(datum))
+		}
+		return makeCondition(conditionType, args[1:], env)
+	case *object.String:
+		return makeCondition(builtinConditionTypes[defaultType], []object.Object{
+			internKeyword("format-control", env), datum,
+			internKeyword("format-arguments", env), sliceToList(args[1:]),
+		}, env)
+	default:
+		return nil, newConditionError("type-error", "first argument to `%s` must be CONDITION, SYMBOL or STRING, got %s", funcName, datum.Type())
+	}
+}
+
+// findRestart returns the innermost active restart named name
+func findRestart(name string, env *object.Environment) (*object.Restart, bool) {
+	restarts := env.Restarts()
+	for i := len(restarts) - 1; i >= 0; i-- {
+		if strings.EqualFold(restarts[i].Name, name) {
+			return restarts[i], true
+		}
+	}
+	return nil, false
+}
+
+// invokeRestart transfers the control to the restart-case which established the restart
+func invokeRestart(restart *object.Restart, args []object.Object) object.Object {
+	return &object.NonLocalExit{
+		Point: restart.Point,
+		Label: strconv.Itoa(restart.Index),
+		Value: sliceToList(args),
+	}
+}
+
+// exitHandler returns the handler function which transfers the control to the exit point with the condition
+func exitHandler(point *object.ExitPoint, index int) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return &object.NonLocalExit{Point: point, Label: strconv.Itoa(index), Value: args[0]}
+		},
+	}
+}
+
+// evalHandlerCase evaluates the form and, if a condition is signaled, unwinds and evaluates the clause of its type
+func evalHandlerCase(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined handler-case form")
+	}
+
+	clauses, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("handler-case clauses must be a list, got %s", cdr.Cdr().String())
+	}
+
+	point := &object.ExitPoint{Name: "handler-case", Active: true}
+	defer func() { point.Active = false }()
+
+	cluster := []*object.Handler{}
+	var noErrorClause *ast.ConsCell
+	for i, sexp := range clauses {
+		clause, ok := sexp.(*ast.ConsCell)
+		if !ok {
+			return newError("invalid handler-case clause: %s", sexp.String())
+		}
+		typeName, ok := conditionTypeName(clause.Car())
+		if !ok {
+			return newError("condition type must be a symbol, got %s", clause.Car().String())
+		}
+
+		if strings.EqualFold(typeName, ":no-error") {
+			noErrorClause = clause
+			continue
+		}
+		cluster = append(cluster, &object.Handler{TypeName: typeName, Function: exitHandler(point, i)})
+	}
+
+	env.PushHandlers(cluster)
+	result := Eval(cdr.Car(), env)
+	env.PopHandlers()
+
+	if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == point {
+		index, _ := strconv.Atoi(exit.Label)
+		return evalClauseWithArgs(clauses[index].(*ast.ConsCell), []object.Object{exit.Value}, env)
+	}
+
+	if noErrorClause != nil && !isUnwinding(result) {
+		return evalClauseWithArgs(noErrorClause, []object.Object{result}, env)
+	}
+
+	return result
+}
+
+// evalClauseWithArgs evaluates the clause (type lambda-list body...) as a function called with args.
+// the variable of a handler-case clause may be omitted, so the extra arguments are ignored.
+func evalClauseWithArgs(clause *ast.ConsCell, args []object.Object, env *object.Environment) object.Object {
+	fn := newFunction(clause.Cdr(), env)
+	if isUnwinding(fn) {
+		return fn
+	}
+
+	function := fn.(*object.Function)
+	if len(function.Parameters) == 0 && len(function.Optional) == 0 && function.Rest == nil {
+		args = nil
+	}
+	return applyFunction(function, args, env)
+}
+
+func conditionTypeName(sexp ast.SExpression) (string, bool) {
+	switch sexp := sexp.(type) {
+	case *ast.Symbol:
+		return sexp.Value, true
+	case *ast.True:
+		return "t", true
+	default:
+		return "", false
+	}
+}
+
+// evalHandlerBind evaluates the body with the handlers, which run without unwinding when a condition is signaled
+func evalHandlerBind(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined handler-bind bindings")
+	}
+
+	bindings, ok := astListToSlice(cdr.Car())
+	if !ok {
+		return newError("handler-bind bindings must be a list, got %s", cdr.Car().String())
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("handler-bind body must be a list, got %s", cdr.Cdr().String())
+	}
+
+	cluster := []*object.Handler{}
+	for _, binding := range bindings {
+		elements, ok := astListToSlice(binding)
+		if !ok || len(elements) != 2 {
+			return newError("invalid handler-bind binding: %s", binding.String())
+		}
+		typeName, ok := conditionTypeName(elements[0])
+		if !ok {
+			return newError("condition type must be a symbol, got %s", elements[0].String())
+		}
+
+		handler := Eval(elements[1], env)
+		if isUnwinding(handler) {
+			return handler
+		}
+		cluster = append(cluster, &object.Handler{TypeName: typeName, Function: handler})
+	}
+
+	env.PushHandlers(cluster)
+	defer env.PopHandlers()
+
+	return evalBody(body, env)
+}
+
+// evalIgnoreErrors evaluates the body and returns nil if an error is signaled
+func evalIgnoreErrors(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	body, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("ignore-errors body must be a list, got %s", consCell.Cdr().String())
+	}
+
+	point := &object.ExitPoint{Name: "ignore-errors", Active: true}
+	defer func() { point.Active = false }()
+
+	env.PushHandlers([]*object.Handler{{TypeName: "error", Function: exitHandler(point, 0)}})
+	result := evalBody(body, env)
+	env.PopHandlers()
+
+	if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == point {
+		return Nil
+	}
+
+	return result
+}
+
+// evalRestartCase evaluates the form with the restarts,
+// each of which evaluates its clause with the arguments of invoke-restart
+func evalRestartCase(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined restart-case form")
+	}
+
+	clauses, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("restart-case clauses must be a list, got %s", cdr.Cdr().String())
+	}
+
+	point := &object.ExitPoint{Name: "restart-case", Active: true}
+	defer func() { point.Active = false }()
+
+	functions := make([]*object.Function, len(clauses))
+	for i, clause := range clauses {
+		elements, ok := astListToSlice(clause)
+		if !ok || len(elements) < 2 {
+			return newError("invalid restart-case clause: %s", clause.String())
+		}
+		name, ok := blockName(elements[0])
+		if !ok {
+			return newError("restart name must be a symbol, got %s", elements[0].String())
+		}
+
+		lambdaList, err := parseLambdaList(elements[1], false)
+		if err != nil {
+			return newError(err.Error())
+		}
+
+		// skip the options such as :report which precede the body
+		body := elements[2:]
+		for len(body) >= 2 && isRestartOption(body[0]) {
+			body = body[2:]
+		}
+
+		functions[i] = &object.Function{LambdaList: lambdaList, Body: body, Env: env}
+		env.PushRestart(&object.Restart{Name: name, Point: point, Index: i})
+	}
+
+	result := Eval(cdr.Car(), env)
+	for range clauses {
+		env.PopRestart()
+	}
+
+	if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == point {
+		index, _ := strconv.Atoi(exit.Label)
+		args, _ := listToSlice(exit.Value)
+		return applyFunction(functions[index], args, env)
+	}
+
+	return result
+}
+
+func isRestartOption(sexp ast.SExpression) bool {
+	symbol, ok := sexp.(*ast.Symbol)
+	if !ok {
+		return false
+	}
+
+	switch strings.ToLower(symbol.Value) {
+	case ":report", ":interactive", ":test":
+		return true
+	default:
+		return false
+	}
+}
+
+// evalDefineCondition defines the condition type with its slots and report
+func evalDefineCondition(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	elements, ok := astListToSlice(consCell.Cdr())
+	if !ok || len(elements) < 3 {
+		return newError("define-condition expects name, parent types and slots")
+	}
+
+	name, ok := elements[0].(*ast.Symbol)
+	if !ok {
+		return newError("condition name must be a symbol, got %s", elements[0].String())
+	}
+
+	conditionType := &object.ConditionType{Name: name.Value, Env: env}
+
+	parents, ok := astListToSlice(elements[1])
+	if !ok {
+		return newError("parent types must be a list, got %s", elements[1].String())
+	}
+	for _, parent := range parents {
+		parentName, ok := parent.(*ast.Symbol)
+		if !ok {
+			return newError("parent type must be a symbol, got %s", parent.String())
+		}
+		parentType, ok := lookupConditionType(parentName.Value, env)
+		if !ok {
+			return newError("unknown condition type: %s", parentName.Value)
+		}
+		conditionType.Parents = append(conditionType.Parents, parentType)
+	}
+	if len(conditionType.Parents) == 0 {
+		conditionType.Parents = append(conditionType.Parents, builtinConditionTypes["condition"])
+	}
+
+	slotSpecs, ok := astListToSlice(elements[2])
+	if !ok {
+		return newError("slot specifiers must be a list, got %s", elements[2].String())
+	}
+	readers := map[string]string{}
+	for _, slotSpec := range slotSpecs {
+		slot, slotReaders, err := parseSlotSpecifier(slotSpec)
+		if err != nil {
+			return newError(err.Error())
+		}
+		conditionType.Slots = append(conditionType.Slots, slot)
+		for _, reader := range slotReaders {
+			readers[reader] = slot.Name
+		}
+	}
+
+	for _, option := range elements[3:] {
+		optionElements, ok := astListToSlice(option)
+		if !ok || len(optionElements) != 2 {
+			return newError("invalid define-condition option: %s", option.String())
+		}
+		optionName, ok := optionElements[0].(*ast.Symbol)
+		if !ok {
+			return newError("invalid define-condition option: %s", option.String())
+		}
+
+		switch strings.ToLower(optionName.Value) {
+		case ":report":
+			report := Eval(optionElements[1], env)
+			if isUnwinding(report) {
+				return report
+			}
+			conditionType.Report = report
+		case ":documentation":
+		default:
+			return newError("unknown define-condition option: %s", optionName.Value)
+		}
+	}
+
+	env.SetConditionType(name.Value, conditionType)
+	for reader, slotName := range readers {
+		env.Intern(reader).Function = slotReader(slotName)
+	}
+
+	return env.Intern(name.Value)
+}
+
+// parseSlotSpecifier parses name or (name :initarg :name :initform form :reader reader)
+// and returns the slot definition and the names of its readers
+func parseSlotSpecifier(sexp ast.SExpression) (*object.SlotDefinition, []string, error) {
+	if symbol, ok := sexp.(*ast.Symbol); ok {
+		return &object.SlotDefinition{Name: symbol.Value}, nil, nil
+	}
+
+	elements, ok := astListToSlice(sexp)
+	if !ok || len(elements) == 0 || len(elements)%2 != 1 {
+		return nil, nil, fmt.Errorf("invalid slot specifier: %s", sexp.String())
+	}
+	name, ok := elements[0].(*ast.Symbol)
+	if !ok {
+		return nil, nil, fmt.Errorf("slot name must be a symbol, got %s", elements[0].String())
+	}
+
+	slot := &object.SlotDefinition{Name: name.Value}
+	readers := []string{}
+	for i := 1; i < len(elements); i += 2 {
+		option, ok := elements[i].(*ast.Symbol)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid slot option: %s", elements[i].String())
+		}
+
+		switch strings.ToLower(option.Value) {
+		case ":initarg":
+			initarg, ok := elements[i+1].(*ast.Symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("initarg must be a symbol, got %s", elements[i+1].String())
+			}
+			slot.Initarg = strings.TrimPrefix(initarg.Value, ":")
+		case ":initform":
+			slot.Initform = elements[i+1]
+		case ":reader", ":accessor":
+			reader, ok := elements[i+1].(*ast.Symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("reader must be a symbol, got %s", elements[i+1].String())
+			}
+			readers = append(readers, reader.Value)
+		case ":type", ":documentation", ":writer":
+		default:
+			return nil, nil, fmt.Errorf("unknown slot option: %s", option.Value)
+		}
+	}
+
+	return slot, readers, nil
+}
+
+// slotReader returns the function which reads the slot of the condition
+func slotReader(slotName string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return conditionSlotValue(args[0], slotName)
+		},
+	}
+}
+
+func conditionSlotValue(obj object.Object, slotName string) object.Object {
+	condition, ok := obj.(*object.Condition)
+	if !ok {
+		return newError("slot %s is read from CONDITION, got %s", slotName, obj.Type())
+	}
+
+	value, ok := condition.Slots[strings.ToLower(slotName)]
+	if !ok {
+		return newError("slot %s of %s is unbound", slotName, condition.Inspect())
+	}
+	return value
+}
+
+// formatString interprets the format directives ~a, ~s, ~d, ~% and ~~ in control
+func formatString(control string, args []object.Object, env *object.Environment) (string, *object.Error) {
+	var out strings.Builder
+
+	runes := []rune(control)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '~' {
+			out.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		if i >= len(runes) {
+			return "", newError("format directive is missing after ~ in %q", control)
+		}
+
+		switch directive := runes[i]; directive {
+		case '%':
+			out.WriteString("\n")
+		case '~':
+			out.WriteString("~")
+		case 'a', 'A', 's', 'S', 'd', 'D':
+			if len(args) == 0 {
+				return "", newError("no more arguments for ~%c in %q", directive, control)
+			}
+			if directive == 'a' || directive == 'A' {
+				out.WriteString(princString(args[0], env))
+			} else {
+				out.WriteString(args[0].Inspect())
+			}
+			args = args[1:]
+		default:
+			return "", newError("unknown format directive ~%c in %q", directive, control)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// princString returns the representation of obj for human readers, without quotes for strings
+func princString(obj object.Object, env *object.Environment) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return obj.Value
+	case *object.Character:
+		return string(obj.Value)
+	case *object.Condition:
+		return conditionReport(obj, env)
+	default:
+		return obj.Inspect()
+	}
+}
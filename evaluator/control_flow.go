@@ -0,0 +1,284 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+// evalBlock evaluates the body except for the last form, which is left in the tail position.
+// return-from the block exits to point, which is caught by the loop of eval that evaluates the block.
+func evalBlock(consCell *ast.ConsCell, point *object.ExitPoint, env *object.Environment) (object.Object, *tailForm) {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined block name"), nil
+	}
+
+	name, ok := blockName(cdr.Car())
+	if !ok {
+		return newError("block name must be a symbol, got %s", cdr.Car().String()), nil
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("block body must be a list, got %s", cdr.Cdr().String()), nil
+	}
+
+	blockEnv := object.NewEnclosedEnvironment(env)
+	blockEnv.SetBlock(name, point)
+
+	return evalBodyTail(body, blockEnv)
+}
+
+func isBlock(consCell *ast.ConsCell) bool {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	return ok && spForm.Value == "block"
+}
+
+func blockName(sexp ast.SExpression) (string, bool) {
+	switch sexp := sexp.(type) {
+	case *ast.Symbol, *ast.SpecialForm:
+		return sexp.String(), true
+	case *ast.Nil:
+		return "nil", true
+	default:
+		return "", false
+	}
+}
+
+func evalReturnFrom(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined block name")
+	}
+
+	name, ok := blockName(cdr.Car())
+	if !ok {
+		return newError("block name must be a symbol, got %s", cdr.Car().String())
+	}
+
+	return returnFromBlock(name, cdr.Cdr(), env)
+}
+
+// evalReturn returns from the block named nil
+func evalReturn(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	return returnFromBlock("nil", consCell.Cdr(), env)
+}
+
+// returnFromBlock evaluates the optional value form and transfers the control to the block
+func returnFromBlock(name string, args ast.SExpression, env *object.Environment) object.Object {
+	point, ok := env.GetBlock(name)
+	if !ok {
+		return newError("return-from: no block named %s is visible", name)
+	}
+	if !point.Active {
+		return newError("return-from: block %s has already exited", name)
+	}
+
+	forms, ok := astListToSlice(args)
+	if !ok || len(forms) > 1 {
+		return newError("return-from expects at most 1 value form, got %s", args.String())
+	}
+
+	var value object.Object = Nil
+	if len(forms) == 1 {
+		value = Eval(forms[0], env)
+		if isUnwinding(value) {
+			return value
+		}
+	}
+
+	return &object.NonLocalExit{Point: point, Value: value}
+}
+
+// evalTagbody evaluates the forms in order, jumping to the tag when go is evaluated.
+// symbols and integers in the body are tags, not forms.
+func evalTagbody(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	items, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("tagbody body must be a list, got %s", consCell.Cdr().String())
+	}
+
+	point := &object.ExitPoint{Name: "tagbody", Active: true}
+	defer func() { point.Active = false }()
+
+	tagEnv := object.NewEnclosedEnvironment(env)
+	indexes := map[string]int{}
+	for i, item := range items {
+		if label, ok := tagLabel(item); ok {
+			tagEnv.SetTag(label, point)
+			indexes[strings.ToUpper(label)] = i
+		}
+	}
+
+	for pc := 0; pc < len(items); pc++ {
+		if _, ok := tagLabel(items[pc]); ok {
+			continue
+		}
+
+		result := Eval(items[pc], tagEnv)
+		if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == point {
+			// the tag itself is skipped by the increment of the loop
+			pc = indexes[strings.ToUpper(exit.Label)]
+			continue
+		}
+		if isUnwinding(result) {
+			return result
+		}
+	}
+
+	return Nil
+}
+
+// tagLabel returns the name of the go tag if sexp is a symbol or an integer
+func tagLabel(sexp ast.SExpression) (string, bool) {
+	switch sexp := sexp.(type) {
+	case *ast.Symbol, *ast.SpecialForm:
+		return sexp.String(), true
+	case *ast.IntegerLiteral:
+		return sexp.String(), true
+	default:
+		return "", false
+	}
+}
+
+func evalGo(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined go tag")
+	}
+
+	label, ok := tagLabel(cdr.Car())
+	if !ok {
+		return newError("go tag must be a symbol or an integer, got %s", cdr.Car().String())
+	}
+
+	point, ok := env.GetTag(label)
+	if !ok {
+		return newError("go: no tag named %s is visible", label)
+	}
+	if !point.Active {
+		return newError("go: tagbody of tag %s has already exited", label)
+	}
+
+	return &object.NonLocalExit{Point: point, Label: label}
+}
+
+// evalCatch evaluates the body and returns the value thrown to the tag if throw is evaluated
+func evalCatch(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined catch tag")
+	}
+
+	tag := Eval(cdr.Car(), env)
+	if isUnwinding(tag) {
+		return tag
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("catch body must be a list, got %s", cdr.Cdr().String())
+	}
+
+	env.PushCatchTag(tag)
+	defer env.PopCatchTag()
+
+	result := evalBody(body, env)
+	if exit, ok := result.(*object.NonLocalExit); ok && exit.Point == nil && exit.CatchTag == tag {
+		return exit.Value
+	}
+
+	return result
+}
+
+// evalThrow transfers the control to the innermost catch whose tag is eq to the given one
+func evalThrow(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	args := evalArgs(consCell.Cdr(), env)
+	if len(args) == 1 && isUnwinding(args[0]) {
+		return args[0]
+	}
+	if len(args) != 2 {
+		return newError("throw expects 2 arguments, but got %d", len(args))
+	}
+
+	tags := env.CatchTags()
+	for i := len(tags) - 1; i >= 0; i-- {
+		if isEq(tags[i], args[0]) {
+			return &object.NonLocalExit{CatchTag: tags[i], Value: args[1]}
+		}
+	}
+
+	return newError("throw: no catch for tag %s", args[0].Inspect())
+}
+
+// evalUnwindProtect evaluates the cleanup forms however the protected form exits,
+// including errors and non-local exits
+func evalUnwindProtect(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined unwind-protect form")
+	}
+
+	cleanupForms, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("unwind-protect cleanup forms must be a list, got %s", cdr.Cdr().String())
+	}
+
+	result := Eval(cdr.Car(), env)
+
+	// an exit from the cleanup forms supersedes the original one
+	if cleanup := evalBody(cleanupForms, env); isUnwinding(cleanup) {
+		return cleanup
+	}
+
+	return result
+}
+
+// wrapInBlock turns (params . body) into (params (block name . body))
+// so that the function can be exited by return-from its name.
+// the block is omitted if the body never returns from it.
+func wrapInBlock(name *ast.Symbol, sexp ast.SExpression) ast.SExpression {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok || !containsReturnFrom(name.Value, consCell.Cdr()) {
+		return sexp
+	}
+
+	block := &ast.ConsCell{
+		CarField: &ast.SpecialForm{Token: token.Token{Type: token.BLOCK, Literal: "block"}, Value: "block"},
+		CdrField: &ast.ConsCell{CarField: name, CdrField: consCell.Cdr()},
+	}
+
+	return &ast.ConsCell{
+		CarField: consCell.Car(),
+		CdrField: &ast.ConsCell{CarField: block, CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}}},
+	}
+}
+
+// containsReturnFrom reports whether return-from the block named name appears in sexp
+func containsReturnFrom(name string, sexp ast.SExpression) bool {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return false
+	}
+
+	if spForm, ok := consCell.Car().(*ast.SpecialForm); ok && spForm.Value == "return-from" {
+		if cdr, ok := consCell.Cdr().(*ast.ConsCell); ok {
+			if symbol, ok := cdr.Car().(*ast.Symbol); ok && strings.EqualFold(symbol.Value, name) {
+				return true
+			}
+		}
+	}
+
+	return containsReturnFrom(name, consCell.Car()) || containsReturnFrom(name, consCell.Cdr())
+}
+
+// newExitError reports the non-local exit which reached the top level without finding its destination
+func newExitError(exit *object.NonLocalExit) *object.Error {
+	if exit.Point != nil {
+		return newError("non-local exit to %s escaped its extent", exit.Point.Name)
+	}
+	return newError("non-local exit to catch tag %s escaped its extent", exit.CatchTag.Inspect())
+}
@@ -1,476 +1,1043 @@
-package evaluator
-
-import (
-	"fmt"
-
-	"github.com/JunNishimura/go-lisp/ast"
-	"github.com/JunNishimura/go-lisp/object"
-	"github.com/JunNishimura/go-lisp/token"
-)
-
-var (
-	Nil  = &object.Nil{}
-	True = &object.True{}
-)
-
-func Eval(sexp ast.SExpression, env *object.Environment) object.Object {
-	switch sexp := sexp.(type) {
-	case *ast.Program:
-		return evalProgram(sexp, env)
-	case *ast.IntegerLiteral:
-		return &object.Integer{Value: sexp.Value}
-	case *ast.PrefixAtom:
-		right := Eval(sexp.Right, env)
-		if isError(right) {
-			return right
-		}
-		return evalPrefixAtom(sexp.Operator, right)
-	case *ast.True:
-		return True
-	case *ast.Nil:
-		return Nil
-	case *ast.Symbol:
-		return evalSymbol(sexp, env)
-	case *ast.ConsCell:
-		return evalList(sexp, env)
-	default:
-		return newError("unknown expression type: %T", sexp)
-	}
-}
-
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
-}
-
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
-	}
-	return false
-}
-
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
-
-	for _, exp := range program.Expressions {
-		result = Eval(exp, env)
-
-		switch result := result.(type) {
-		case *object.Error:
-			return result
-		}
-	}
-
-	return result
-}
-
-func evalPrefixAtom(operator string, right object.Object) object.Object {
-	switch operator {
-	case "+":
-		return evalPlusPrefix(right)
-	case "-":
-		return evalMinusPrefix(right)
-	default:
-		return newError("unknown operator: %s", operator)
-	}
-}
-
-func evalPlusPrefix(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: + %s", right.Type())
-	}
-
-	return right
-}
-
-func evalMinusPrefix(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: - %s", right.Type())
-	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
-}
-
-func evalSymbol(symbol *ast.Symbol, env *object.Environment) object.Object {
-	if val, ok := env.Get(symbol.Value); ok {
-		return val
-	}
-
-	builtin, ok := getBuiltinFunctions(symbol.Value)
-	if ok {
-		return builtin
-	}
-
-	return newError("symbol not found: %s", symbol.Value)
-}
-
-// evaluate cdr of the cons cell as arguments to the command car
-func evalList(sexp *ast.ConsCell, env *object.Environment) object.Object {
-	switch car := sexp.Car().(type) {
-	case *ast.Symbol:
-		return evalNormalForm(sexp, env)
-	case *ast.ConsCell:
-		if isLambdaExpression(car) {
-			return evalNormalForm(sexp, env)
-		}
-	case *ast.SpecialForm:
-		return evalSpecialForm(sexp, env)
-	}
-
-	return newError("unknown operator type: %T", sexp.Car())
-}
-
-func isLambdaExpression(consCell *ast.ConsCell) bool {
-	spForm, ok := consCell.Car().(*ast.SpecialForm)
-	if !ok {
-		return false
-	}
-
-	return spForm.Token.Type == token.LAMBDA
-}
-
-func evalNormalForm(consCell *ast.ConsCell, env *object.Environment) object.Object {
-	// Evaluate the car of the cons cell
-	car := Eval(consCell.Car(), env)
-	if isError(car) {
-		return car
-	}
-
-	// Evaluate the arguments
-	args := evalArgs(consCell.Cdr(), env)
-	if len(args) == 1 && isError(args[0]) {
-		return args[0]
-	}
-	return applyFunction(car, args, env)
-}
-
-func evalArgs(sexp ast.SExpression, env *object.Environment) []object.Object {
-	list := []object.Object{}
-
-	switch sexp := sexp.(type) {
-	case *ast.Nil:
-		return list
-	case *ast.ConsCell:
-		return evalValueList(sexp, env)
-	default:
-		return []object.Object{newError("arguments must be a list, got %T", sexp)}
-	}
-}
-
-func evalValueList(consCell *ast.ConsCell, env *object.Environment) []object.Object {
-	list := []object.Object{}
-
-	for {
-		// Evaluate the car of the cons cell
-		car := Eval(consCell.Car(), env)
-		if isError(car) {
-			return []object.Object{car}
-		}
-		if symbol, ok := car.(*object.Symbol); ok {
-			list = append(list, symbol.Value)
-		} else {
-			list = append(list, car)
-		}
-
-		// move to the next cons cell or return the list if the cdr is nil
-		switch cdr := consCell.Cdr().(type) {
-		case *ast.Nil:
-			return list
-		case *ast.ConsCell:
-			consCell = cdr
-		default:
-			err := newError("arguments must be a list, got %T", consCell.Cdr())
-			return []object.Object{err}
-		}
-	}
-}
-
-func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv, err := extendFunctionEnv(fn, args)
-		if err != nil {
-			return newError(err.Error())
-		}
-		return Eval(fn.Body, extendedEnv)
-	case *object.Symbol:
-		extendedEnv, err := extendFunctionEnv(fn.Function, args)
-		if err != nil {
-			return newError(err.Error())
-		}
-		symbolFunc := fn.Function
-		return Eval(symbolFunc.Body, extendedEnv)
-	case *object.Builtin:
-		return fn.Fn(env, args...)
-	default:
-		return newError("not a function: %s", fn.Type())
-	}
-}
-
-func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, error) {
-	if len(fn.Parameters) != len(args) {
-		return nil, fmt.Errorf("function expects %d arguments, but got %d", len(fn.Parameters), len(args))
-	}
-
-	env := object.NewEnclosedEnvironment(fn.Env)
-
-	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
-	}
-
-	return env, nil
-}
-
-func evalSpecialForm(sexp *ast.ConsCell, env *object.Environment) object.Object {
-	spForm, ok := sexp.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", sexp.Car())
-	}
-
-	switch spForm.Value {
-	case "lambda":
-		return evalLambda(sexp, env)
-	case "quote":
-		return evalQuote(sexp)
-	case "backquote":
-		return evalBackquote(sexp, env)
-	case "if":
-		return evalIf(sexp, env)
-	case "setq":
-		return evalSetq(sexp, env)
-	}
-
-	return newError("unknown special form: %s", spForm.Value)
-}
-
-func evalLambda(sexp *ast.ConsCell, env *object.Environment) object.Object {
-	spForm, ok := sexp.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", sexp.Car())
-	}
-	if spForm.Token.Type != token.LAMBDA {
-		return newError("expect special form lambda, got %s", spForm.Token.Type)
-	}
-
-	cdr, ok := sexp.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined lambda parameters")
-	}
-
-	params, err := evalLambdaParams(cdr.Car())
-	if err != nil {
-		return newError(err.Error())
-	}
-
-	cddr, ok := cdr.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined lambda body")
-	}
-
-	return &object.Function{
-		Parameters: params,
-		Body:       cddr.Car(),
-		Env:        env,
-	}
-}
-
-func evalLambdaParams(sexp ast.SExpression) ([]*ast.Symbol, error) {
-	params := []*ast.Symbol{}
-
-	if _, ok := sexp.(*ast.Nil); ok {
-		return params, nil
-	}
-
-	consCell, ok := sexp.(*ast.ConsCell)
-	if !ok {
-		return nil, fmt.Errorf("parameters must be a list, got %T", sexp)
-	}
-
-	for {
-		symbol, ok := consCell.Car().(*ast.Symbol)
-		if !ok {
-			return nil, fmt.Errorf("parameter must be a symbol, got %T", consCell.Car())
-		}
-		params = append(params, symbol)
-
-		switch cdr := consCell.Cdr().(type) {
-		case *ast.Nil:
-			return params, nil
-		case *ast.ConsCell:
-			consCell = cdr
-		default:
-			return nil, fmt.Errorf("parameters must be a list, got %T", consCell.Cdr())
-		}
-	}
-}
-
-func evalQuote(sexp *ast.ConsCell) object.Object {
-	spForm, ok := sexp.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", sexp.Car())
-	}
-	if spForm.Token.Type != token.QUOTE {
-		return newError("expect special form quote, got %s", spForm.Token.Type)
-	}
-
-	cdr, ok := sexp.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined quote expression")
-	}
-
-	return &object.Quote{
-		SExpression: cdr.Car(),
-	}
-}
-
-func evalBackquote(sexp *ast.ConsCell, env *object.Environment) object.Object {
-	spForm, ok := sexp.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", sexp.Car())
-	}
-	if spForm.Token.Type != token.BACKQUOTE {
-		return newError("expect special form backquote, got %s", spForm.Token.Type)
-	}
-
-	cdr, ok := sexp.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined backquote expression")
-	}
-
-	unquoted := evalUnquote(cdr.Car(), env)
-
-	return &object.Quote{
-		SExpression: unquoted,
-	}
-}
-
-func evalUnquote(sexp ast.SExpression, env *object.Environment) ast.SExpression {
-	return ast.ModifyByUnquote(sexp, func(sexp ast.SExpression) ast.SExpression {
-		consCell, ok := sexp.(*ast.ConsCell)
-		if !ok {
-			return sexp
-		}
-
-		if car, ok := consCell.Car().(*ast.SpecialForm); !ok || car.Value != "unquote" {
-			return sexp
-		}
-
-		// evaluate unquoted s-expression
-		cdr := consCell.Cdr().(*ast.ConsCell)
-		evaluated := Eval(cdr.Car(), env)
-
-		return convertObjectToSExpression(evaluated)
-	})
-}
-
-func convertObjectToSExpression(obj object.Object) ast.SExpression {
-	switch obj := obj.(type) {
-	case *object.Integer:
-		t := token.Token{
-			Type:    token.INT,
-			Literal: fmt.Sprintf("%d", obj.Value),
-		}
-		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
-	case *object.Quote:
-		return obj.SExpression
-	default:
-		return nil
-	}
-}
-
-func evalIf(consCell *ast.ConsCell, env *object.Environment) object.Object {
-	spForm, ok := consCell.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", consCell.Car())
-	}
-	if spForm.Token.Type != token.IF {
-		return newError("expect special form if, got %s", spForm.Token.Type)
-	}
-
-	cdr, ok := consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined if condition")
-	}
-
-	// evaluate the condition
-	cadr := cdr.Car()
-	condition := Eval(cadr, env)
-	if isError(condition) {
-		return condition
-	}
-
-	cddr, ok := cdr.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined if consequent")
-	}
-
-	// if condition is true, evaluate the consequent
-	if isTruthy(condition) {
-		caddr := cddr.Car()
-		return Eval(caddr, env)
-	}
-
-	// if alternative is not defined, return nil
-	cdddr, ok := cddr.Cdr().(*ast.ConsCell)
-	if !ok {
-		if _, ok := cddr.Cdr().(*ast.Nil); ok {
-			return Nil
-		}
-		return newError("invalid if alternative")
-	}
-
-	// evaluate the alternative
-	cadddr := cdddr.Car()
-	return Eval(cadddr, env)
-}
-
-func isTruthy(obj object.Object) bool {
-	switch obj.(type) {
-	case *object.True:
-		return true
-	case *object.Nil:
-		return false
-	default:
-		return true
-	}
-}
-
-func evalSetq(consCell *ast.ConsCell, env *object.Environment) object.Object {
-	spForm, ok := consCell.Car().(*ast.SpecialForm)
-	if !ok {
-		return newError("expect special form, got %T", consCell.Car())
-	}
-	if spForm.Token.Type != token.SETQ {
-		return newError("expect special form setq, got %s", spForm.Token.Type)
-	}
-
-	cdr, ok := consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined name of symbol")
-	}
-
-	symbolName, ok := cdr.Car().(*ast.Symbol)
-	if !ok {
-		return newError("expect symbol, got %T", cdr.Car())
-	}
-
-	cddr, ok := cdr.Cdr().(*ast.ConsCell)
-	if !ok {
-		return newError("not defined value of symbol")
-	}
-
-	value := Eval(cddr.Car(), env)
-	if isError(value) {
-		return value
-	}
-
-	symbol := &object.Symbol{
-		Name:  symbolName.Value,
-		Value: value,
-	}
-
-	env.Set(symbolName.Value, symbol)
-
-	return symbol
-}
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+var (
+	Nil  = &object.Nil{}
+	True = &object.True{}
+)
+
+// maxEvalDepth is the limit of the nested evaluations, beyond which the recursion is reported
+// as the stack overflow before it exhausts the stack of the Go runtime
+const maxEvalDepth = 100000
+
+func Eval(sexp ast.SExpression, env *object.Environment) object.Object {
+	var result object.Object
+	if env != nil && env.EnterEvaluation() > maxEvalDepth {
+		result = newError("stack overflow")
+	} else {
+		result = eval(sexp, env)
+	}
+	if env != nil {
+		env.LeaveEvaluation()
+	}
+
+	// the error is signaled by the innermost form so that the handlers run before the stack is unwound
+	if err, ok := result.(*object.Error); ok && !err.Signaled && env != nil {
+		return signalError(err, env)
+	}
+
+	return result
+}
+
+// tailForm is the form left in the tail position of a special form or a function body.
+// eval evaluates it by the loop instead of a recursive call, so that tail calls run in constant stack space.
+type tailForm struct {
+	sexp ast.SExpression
+	env  *object.Environment
+}
+
+func eval(sexp ast.SExpression, env *object.Environment) object.Object {
+	// the blocks in the tail position share the exit point, because all of them return the result of the loop
+	var point *object.ExitPoint
+
+	for {
+		if err := CheckInterrupt(); err != nil {
+			return err
+		}
+
+		var result object.Object
+		var tail *tailForm
+		if consCell, ok := sexp.(*ast.ConsCell); ok && isBlock(consCell) {
+			if point == nil {
+				point = &object.ExitPoint{Name: "block", Active: true}
+				defer func() { point.Active = false }()
+			}
+			result, tail = evalBlock(consCell, point, env)
+		} else {
+			result, tail = evalTail(sexp, env)
+		}
+
+		if tail == nil {
+			if exit, ok := result.(*object.NonLocalExit); ok && point != nil && exit.Point == point {
+				return exit.Value
+			}
+			// the error points to the innermost form, as the outer forms see the position already set
+			if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+				err.Pos = sexp.Span().Start
+			}
+			return result
+		}
+		sexp, env = tail.sexp, tail.env
+	}
+}
+
+// evalTail evaluates sexp except for the form in the tail position, which is returned instead
+func evalTail(sexp ast.SExpression, env *object.Environment) (object.Object, *tailForm) {
+	switch sexp := sexp.(type) {
+	case *ast.ConsCell:
+		return evalList(sexp, env)
+	default:
+		return evalAtom(sexp, env), nil
+	}
+}
+
+func evalAtom(sexp ast.SExpression, env *object.Environment) object.Object {
+	switch sexp := sexp.(type) {
+	case *ast.Program:
+		return evalProgram(sexp, env)
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: sexp.Value}
+	case *ast.BignumLiteral:
+		return normalizeInteger(sexp.Value)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: sexp.Value}
+	case *ast.RatioLiteral:
+		return normalizeRatio(sexp.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: sexp.Value}
+	case *ast.True:
+		return True
+	case *ast.Nil:
+		return Nil
+	case *ast.Symbol:
+		return evalSymbol(sexp, env)
+	case *ast.SpecialForm:
+		// the special form is only recognized in the function position, and otherwise it is the variable
+		symbol, _ := ast.AsSymbol(sexp)
+		return evalSymbol(symbol, env)
+	case *objectLiteral:
+		return sexp.object
+	default:
+		return newError("unknown expression type: %T", sexp)
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
+
+// isUnwinding reports whether obj is an error or a non-local exit,
+// both of which abort the evaluation of the enclosing forms
+func isUnwinding(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
+	}
+	return false
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, exp := range program.Expressions {
+		result = Eval(exp, env)
+
+		switch result := result.(type) {
+		case *object.Error:
+			return result
+		case *object.NonLocalExit:
+			return newExitError(result)
+		}
+	}
+
+	return result
+}
+
+func evalSymbol(symbol *ast.Symbol, env *object.Environment) object.Object {
+	if symbol.IsKeyword() {
+		return internKeyword(strings.TrimPrefix(symbol.Value, ":"), env)
+	}
+
+	if val, ok := env.Get(symbol.Value); ok {
+		return val
+	}
+
+	return newConditionError("unbound-variable", "symbol not found: %s", symbol.Value)
+}
+
+// internSymbol returns the symbol named name from the symbol table of env
+func internSymbol(name string, env *object.Environment) *object.Symbol {
+	if env == nil {
+		return &object.Symbol{Name: name}
+	}
+	return env.Intern(name)
+}
+
+// internKeyword returns the keyword from the symbol table, so that every occurrence of it is the same object.
+// name is that of the keyword without the leading colon, and the value of a keyword is the keyword itself.
+func internKeyword(name string, env *object.Environment) *object.Symbol {
+	if env == nil {
+		keyword := &object.Symbol{Name: name, Keyword: true}
+		keyword.Value = keyword
+		return keyword
+	}
+	return env.InternKeyword(name)
+}
+
+// designatorName returns the name by which the symbol designates a function, a type or a restart.
+// the keywords keep their colon, so that they do not designate the same things as the symbols of the same names.
+func designatorName(symbol *object.Symbol) string {
+	if symbol.IsKeyword() {
+		return ":" + symbol.Name
+	}
+	return symbol.Name
+}
+
+// lookupFunction returns the local function defined by flet or labels,
+// or the function which is stored in the function cell of the symbol.
+// builtin functions are used when the symbol has no function definition.
+func lookupFunction(name string, env *object.Environment) object.Object {
+	if fn, ok := env.GetFunction(name); ok {
+		return fn
+	}
+
+	if fn := env.Intern(name).Function; fn != nil {
+		return fn
+	}
+
+	if builtin, ok := getBuiltinFunctions(name); ok {
+		return builtin
+	}
+
+	return newConditionError("undefined-function", "undefined function: %s", name)
+}
+
+// evaluate cdr of the cons cell as arguments to the command car
+func evalList(sexp *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	switch car := sexp.Car().(type) {
+	case *ast.Symbol:
+		return evalNormalForm(sexp, env)
+	case *ast.ConsCell:
+		if isLambdaExpression(car) {
+			return evalNormalForm(sexp, env)
+		}
+	case *ast.SpecialForm:
+		// the special forms which have tail positions
+		switch car.Value {
+		case "if":
+			return evalIf(sexp, env)
+		case "progn":
+			return evalProgn(sexp, env)
+		case "let":
+			return evalLet(sexp, false, env)
+		case "let*":
+			return evalLet(sexp, true, env)
+		case "flet":
+			return evalFlet(sexp, false, env)
+		case "labels":
+			return evalFlet(sexp, true, env)
+		case "cond":
+			return evalCond(sexp, env)
+		case "when":
+			return evalWhen(sexp, false, env)
+		case "unless":
+			return evalWhen(sexp, true, env)
+		case "and":
+			return evalAnd(sexp, false, env)
+		case "or":
+			return evalAnd(sexp, true, env)
+		case "case", "ecase":
+			return evalCase(sexp, env)
+		case "typecase", "etypecase":
+			return evalTypecase(sexp, env)
+		}
+		return evalSpecialForm(sexp, env), nil
+	}
+
+	return newError("unknown operator type: %T", sexp.Car()), nil
+}
+
+func isLambdaExpression(consCell *ast.ConsCell) bool {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	if !ok {
+		return false
+	}
+
+	return spForm.Token.Type == token.LAMBDA
+}
+
+func evalNormalForm(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	// Evaluate the car of the cons cell as a function, not as a variable
+	car := evalFunctionName(consCell.Car(), env)
+	if isUnwinding(car) {
+		return car, nil
+	}
+
+	// Evaluate the arguments
+	args := evalArgs(consCell.Cdr(), env)
+	if len(args) == 1 && isUnwinding(args[0]) {
+		return args[0], nil
+	}
+
+	// the last form of the body is left to the caller so that the call does not grow the stack
+	if fn, ok := car.(*object.Function); ok {
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err, nil
+		}
+		return evalBodyTail(fn.Body, extendedEnv)
+	}
+
+	return applyFunction(car, args, env), nil
+}
+
+// evalFunctionName evaluates the symbol or the lambda expression in the function namespace
+func evalFunctionName(sexp ast.SExpression, env *object.Environment) object.Object {
+	if symbol, ok := sexp.(*ast.Symbol); ok {
+		return lookupFunction(symbol.Value, env)
+	}
+
+	if consCell, ok := sexp.(*ast.ConsCell); ok && isLambdaExpression(consCell) {
+		return Eval(consCell, env)
+	}
+
+	return newError("invalid function name: %s", sexp.String())
+}
+
+func evalArgs(sexp ast.SExpression, env *object.Environment) []object.Object {
+	list := []object.Object{}
+
+	switch sexp := sexp.(type) {
+	case *ast.Nil:
+		return list
+	case *ast.ConsCell:
+		return evalValueList(sexp, env)
+	default:
+		return []object.Object{newError("arguments must be a list, got %T", sexp)}
+	}
+}
+
+func evalValueList(consCell *ast.ConsCell, env *object.Environment) []object.Object {
+	list := []object.Object{}
+
+	for {
+		// Evaluate the car of the cons cell
+		car := Eval(consCell.Car(), env)
+		if isUnwinding(car) {
+			return []object.Object{car}
+		}
+		list = append(list, car)
+
+		// move to the next cons cell or return the list if the cdr is nil
+		switch cdr := consCell.Cdr().(type) {
+		case *ast.Nil:
+			return list
+		case *ast.ConsCell:
+			consCell = cdr
+		default:
+			err := newError("arguments must be a list, got %T", consCell.Cdr())
+			return []object.Object{err}
+		}
+	}
+}
+
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		return evalBody(fn.Body, extendedEnv)
+	case *object.Symbol:
+		// a symbol designates its global function definition
+		symbolFunc := lookupFunction(designatorName(fn), env)
+		if isUnwinding(symbolFunc) {
+			return symbolFunc
+		}
+		return applyFunction(symbolFunc, args, env)
+	case *object.Builtin:
+		return fn.Fn(env, args...)
+	case object.Applicable:
+		return fn.Apply(env, args)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	if err := bindLambdaList(fn.LambdaList, args, env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func evalSpecialForm(sexp *ast.ConsCell, env *object.Environment) object.Object {
+	spForm, ok := sexp.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", sexp.Car())
+	}
+
+	switch spForm.Value {
+	case "lambda":
+		return evalLambda(sexp, env)
+	case "quote":
+		return evalQuote(sexp, env)
+	case "backquote":
+		return evalBackquote(sexp, env)
+	case "setq":
+		return evalSetq(sexp, env)
+	case "defun":
+		return evalDefun(sexp, env)
+	case "function":
+		return evalFunction(sexp, env)
+	case "prog1":
+		return evalProgN(sexp, 1, env)
+	case "prog2":
+		return evalProgN(sexp, 2, env)
+	case "return-from":
+		return evalReturnFrom(sexp, env)
+	case "return":
+		return evalReturn(sexp, env)
+	case "tagbody":
+		return evalTagbody(sexp, env)
+	case "go":
+		return evalGo(sexp, env)
+	case "catch":
+		return evalCatch(sexp, env)
+	case "throw":
+		return evalThrow(sexp, env)
+	case "unwind-protect":
+		return evalUnwindProtect(sexp, env)
+	case "handler-case":
+		return evalHandlerCase(sexp, env)
+	case "handler-bind":
+		return evalHandlerBind(sexp, env)
+	case "ignore-errors":
+		return evalIgnoreErrors(sexp, env)
+	case "restart-case":
+		return evalRestartCase(sexp, env)
+	case "define-condition":
+		return evalDefineCondition(sexp, env)
+	}
+
+	return newError("unknown special form: %s", spForm.Value)
+}
+
+func evalLambda(sexp *ast.ConsCell, env *object.Environment) object.Object {
+	spForm, ok := sexp.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", sexp.Car())
+	}
+	if spForm.Token.Type != token.LAMBDA {
+		return newError("expect special form lambda, got %s", spForm.Token.Type)
+	}
+
+	return newFunction(sexp.Cdr(), env)
+}
+
+// newFunction creates the closure from the list of the parameters followed by the body
+func newFunction(sexp ast.SExpression, env *object.Environment) object.Object {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return newError("not defined lambda parameters")
+	}
+
+	lambdaList, err := parseLambdaList(consCell.Car(), false)
+	if err != nil {
+		return newError(err.Error())
+	}
+
+	body, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("lambda body must be a list, got %s", consCell.Cdr().String())
+	}
+
+	return &object.Function{
+		LambdaList: lambdaList,
+		Body:       body,
+		Env:        env,
+	}
+}
+
+// evalDefun stores the function in the function cell of the symbol,
+// which is separated from the value cell set by setq
+func evalDefun(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined name of function")
+	}
+
+	name, ok := cdr.Car().(*ast.Symbol)
+	if !ok {
+		return newError("expect symbol, got %T", cdr.Car())
+	}
+	if name.IsKeyword() {
+		return newError("keyword %s cannot be a function name", name.Value)
+	}
+
+	fn := newFunction(wrapInBlock(name, cdr.Cdr()), env)
+	if isUnwinding(fn) {
+		return fn
+	}
+
+	symbol := env.Intern(name.Value)
+	symbol.Function = fn
+
+	return symbol
+}
+
+// evalFunction returns the function named by the symbol or the closure of the lambda expression
+func evalFunction(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined function name")
+	}
+
+	return evalFunctionName(cdr.Car(), env)
+}
+
+func evalQuote(sexp *ast.ConsCell, env *object.Environment) object.Object {
+	spForm, ok := sexp.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", sexp.Car())
+	}
+	if spForm.Token.Type != token.QUOTE {
+		return newError("expect special form quote, got %s", spForm.Token.Type)
+	}
+
+	cdr, ok := sexp.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined quote expression")
+	}
+
+	return convertSExpressionToObject(cdr.Car(), env)
+}
+
+func evalBackquote(sexp *ast.ConsCell, env *object.Environment) object.Object {
+	spForm, ok := sexp.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", sexp.Car())
+	}
+	if spForm.Token.Type != token.BACKQUOTE {
+		return newError("expect special form backquote, got %s", spForm.Token.Type)
+	}
+
+	cdr, ok := sexp.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined backquote expression")
+	}
+
+	return evalQuasiquote(cdr.Car(), 1, env)
+}
+
+// evalQuasiquote converts the backquoted s-expression into data
+// while evaluating the unquoted s-expressions inside of it.
+// depth is the number of the enclosing backquotes which are not cancelled by the unquotes,
+// and only the unquotes at depth 1 are evaluated.
+func evalQuasiquote(sexp ast.SExpression, depth int, env *object.Environment) object.Object {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return convertSExpressionToObject(sexp, env)
+	}
+
+	switch quasiquoteFormName(consCell) {
+	case "backquote":
+		return evalNestedQuasiquote(consCell, depth+1, env)
+	case "unquote":
+		if depth > 1 {
+			return evalNestedQuasiquote(consCell, depth-1, env)
+		}
+		arg, ok := quasiquoteFormArg(consCell)
+		if !ok {
+			return newError("not defined unquote expression")
+		}
+		return Eval(arg, env)
+	case "unquote-splicing":
+		if depth > 1 {
+			return evalNestedQuasiquote(consCell, depth-1, env)
+		}
+		return newError(",@ must be an element of a list, got %s", consCell.String())
+	}
+
+	cdr := evalQuasiquote(consCell.Cdr(), depth, env)
+	if isUnwinding(cdr) {
+		return cdr
+	}
+
+	// ,@ splices the elements of the list into the enclosing list
+	if car, ok := consCell.Car().(*ast.ConsCell); ok && depth == 1 && quasiquoteFormName(car) == "unquote-splicing" {
+		arg, ok := quasiquoteFormArg(car)
+		if !ok {
+			return newError("not defined unquote-splicing expression")
+		}
+		spliced := Eval(arg, env)
+		if isUnwinding(spliced) {
+			return spliced
+		}
+		return appendSpliced(spliced, cdr)
+	}
+
+	car := evalQuasiquote(consCell.Car(), depth, env)
+	if isUnwinding(car) {
+		return car
+	}
+
+	return &object.ConsCell{Car: car, Cdr: cdr}
+}
+
+// evalNestedQuasiquote keeps the backquote or the unquote form of the nested backquote as data
+func evalNestedQuasiquote(consCell *ast.ConsCell, depth int, env *object.Environment) object.Object {
+	arg, ok := quasiquoteFormArg(consCell)
+	if !ok {
+		return newError("not defined %s expression", quasiquoteFormName(consCell))
+	}
+
+	quoted := evalQuasiquote(arg, depth, env)
+	if isUnwinding(quoted) {
+		return quoted
+	}
+
+	return sliceToList([]object.Object{internSymbol(quasiquoteFormName(consCell), env), quoted})
+}
+
+// appendSpliced copies the elements of the spliced list in front of rest.
+// the spliced value of the last element becomes the tail as it is, like the last argument of append.
+func appendSpliced(spliced object.Object, rest object.Object) object.Object {
+	if _, ok := rest.(*object.Nil); ok {
+		return spliced
+	}
+
+	elements, ok := listToSlice(spliced)
+	if !ok {
+		return newError(",@ must be followed by a proper list, got %s", spliced.Inspect())
+	}
+
+	for i := len(elements) - 1; i >= 0; i-- {
+		rest = &object.ConsCell{Car: elements[i], Cdr: rest}
+	}
+	return rest
+}
+
+// quasiquoteFormName returns the name of the backquote, unquote or unquote-splicing form,
+// or the empty string if consCell is none of them
+func quasiquoteFormName(consCell *ast.ConsCell) string {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	if !ok {
+		return ""
+	}
+
+	switch spForm.Value {
+	case "backquote", "unquote", "unquote-splicing":
+		return spForm.Value
+	default:
+		return ""
+	}
+}
+
+// quasiquoteFormArg returns the argument of the backquote, unquote or unquote-splicing form
+func quasiquoteFormArg(consCell *ast.ConsCell) (ast.SExpression, bool) {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return nil, false
+	}
+	return cdr.Car(), true
+}
+
+// convertSExpressionToObject converts the s-expression into the data it represents without evaluating it.
+// the symbols are interned in the symbol table of env, so every occurrence of the same name is the same object.
+// env is nil if the data is checked to have no symbols.
+func convertSExpressionToObject(sexp ast.SExpression, env *object.Environment) object.Object {
+	switch sexp := sexp.(type) {
+	case *ast.Symbol:
+		if sexp.IsKeyword() {
+			return internKeyword(strings.TrimPrefix(sexp.Value, ":"), env)
+		}
+		return internSymbol(sexp.Value, env)
+	case *ast.SpecialForm:
+		return internSymbol(sexp.Value, env)
+	case *ast.ConsCell:
+		return &object.ConsCell{
+			Car: convertSExpressionToObject(sexp.Car(), env),
+			Cdr: convertSExpressionToObject(sexp.Cdr(), env),
+		}
+	case *objectLiteral:
+		return sexp.object
+	case *ast.IntegerLiteral, *ast.BignumLiteral, *ast.FloatLiteral, *ast.RatioLiteral,
+		*ast.StringLiteral, *ast.True, *ast.Nil:
+		// self-evaluating atoms need no environment
+		return Eval(sexp, nil)
+	default:
+		return newError("unknown expression type: %T", sexp)
+	}
+}
+
+// objectLiteral is the object embedded in the s-expression by a macro, such as a function or a character,
+// which has no representation in the source code. it evaluates to the object itself.
+type objectLiteral struct {
+	object object.Object
+}
+
+func (ol *objectLiteral) Span() ast.Span { return ast.Span{} }
+func (ol *objectLiteral) String() string { return ol.object.Inspect() }
+
+// convertObjectToSExpression converts the data back into the s-expression.
+// the objects which have no representation in the source code are embedded as they are.
+func convertObjectToSExpression(obj object.Object) ast.SExpression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Bignum:
+		t := token.Token{Type: token.INT, Literal: obj.Inspect()}
+		return &ast.BignumLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.Ratio:
+		t := token.Token{Type: token.RATIO, Literal: obj.Inspect()}
+		return &ast.RatioLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Symbol:
+		if obj.IsKeyword() {
+			name := ":" + obj.Name
+			return &ast.Symbol{Token: token.Token{Type: token.KEYWORD, Literal: name}, Value: name}
+		}
+		if strings.HasPrefix(obj.Name, ":") {
+			// the symbol such as |:a| is not a keyword even though its name begins with the colon
+			return &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: obj.Name}, Value: obj.Name}
+		}
+		return convertSymbolToSExpression(obj.Name)
+	case *object.True:
+		return &ast.True{Token: token.Token{Type: token.TRUE, Literal: "t"}}
+	case *object.Nil:
+		return &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}}
+	case *object.ConsCell:
+		return &ast.ConsCell{
+			CarField: convertObjectToSExpression(obj.Car),
+			CdrField: convertObjectToSExpression(obj.Cdr),
+		}
+	default:
+		return &objectLiteral{object: obj}
+	}
+}
+
+// convertSymbolToSExpression restores special forms such as quote and lambda from their names
+func convertSymbolToSExpression(name string) ast.SExpression {
+	t := token.Token{Type: token.LookupKeyword(name), Literal: name}
+
+	switch t.Type {
+	case token.SYMBOL, token.KEYWORD:
+		return &ast.Symbol{Token: t, Value: name}
+	case token.NIL:
+		return &ast.Nil{Token: t}
+	case token.TRUE:
+		return &ast.True{Token: t}
+	default:
+		return &ast.SpecialForm{Token: t, Value: name}
+	}
+}
+
+func evalIf(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", consCell.Car()), nil
+	}
+	if spForm.Token.Type != token.IF {
+		return newError("expect special form if, got %s", spForm.Token.Type), nil
+	}
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined if condition"), nil
+	}
+
+	// evaluate the condition
+	cadr := cdr.Car()
+	condition := Eval(cadr, env)
+	if isUnwinding(condition) {
+		return condition, nil
+	}
+
+	cddr, ok := cdr.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined if consequent"), nil
+	}
+
+	// if condition is true, evaluate the consequent
+	if isTruthy(condition) {
+		caddr := cddr.Car()
+		return nil, &tailForm{sexp: caddr, env: env}
+	}
+
+	// if alternative is not defined, return nil
+	cdddr, ok := cddr.Cdr().(*ast.ConsCell)
+	if !ok {
+		if _, ok := cddr.Cdr().(*ast.Nil); ok {
+			return Nil, nil
+		}
+		return newError("invalid if alternative"), nil
+	}
+
+	// evaluate the alternative
+	cadddr := cdddr.Car()
+	return nil, &tailForm{sexp: cadddr, env: env}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.True:
+		return true
+	case *object.Nil:
+		return false
+	default:
+		return true
+	}
+}
+
+func evalSetq(consCell *ast.ConsCell, env *object.Environment) object.Object {
+	spForm, ok := consCell.Car().(*ast.SpecialForm)
+	if !ok {
+		return newError("expect special form, got %T", consCell.Car())
+	}
+	if spForm.Token.Type != token.SETQ {
+		return newError("expect special form setq, got %s", spForm.Token.Type)
+	}
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined name of symbol")
+	}
+
+	symbolName, ok := ast.AsSymbol(cdr.Car())
+	if !ok {
+		return newError("expect symbol, got %s", cdr.Car().String())
+	}
+	if symbolName.IsKeyword() {
+		return newError("keyword %s cannot be assigned", symbolName.Value)
+	}
+
+	cddr, ok := cdr.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined value of symbol")
+	}
+
+	value := Eval(cddr.Car(), env)
+	if isUnwinding(value) {
+		return value
+	}
+
+	env.Assign(symbolName.Value, value)
+
+	return value
+}
+
+// evalBody evaluates the forms in order and returns the value of the last one
+func evalBody(body []ast.SExpression, env *object.Environment) object.Object {
+	result, tail := evalBodyTail(body, env)
+	if tail != nil {
+		return Eval(tail.sexp, tail.env)
+	}
+	return result
+}
+
+// evalBodyTail evaluates the forms in order except for the last one, which is returned as the tail form
+func evalBodyTail(body []ast.SExpression, env *object.Environment) (object.Object, *tailForm) {
+	if len(body) == 0 {
+		return Nil, nil
+	}
+
+	for _, form := range body[:len(body)-1] {
+		if result := Eval(form, env); isUnwinding(result) {
+			return result, nil
+		}
+	}
+
+	return nil, &tailForm{sexp: body[len(body)-1], env: env}
+}
+
+func evalProgn(consCell *ast.ConsCell, env *object.Environment) (object.Object, *tailForm) {
+	body, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("progn body must be a list, got %s", consCell.Cdr().String()), nil
+	}
+
+	return evalBodyTail(body, env)
+}
+
+// evalProgN evaluates the forms in order like progn but returns the value of the nth form (prog1 and prog2)
+func evalProgN(consCell *ast.ConsCell, n int, env *object.Environment) object.Object {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	body, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return newError("%s body must be a list, got %s", name, consCell.Cdr().String())
+	}
+	if len(body) < n {
+		return newError("%s expects at least %d forms, but got %d", name, n, len(body))
+	}
+
+	var result object.Object
+	for i, form := range body {
+		value := Eval(form, env)
+		if isUnwinding(value) {
+			return value
+		}
+		if i == n-1 {
+			result = value
+		}
+	}
+
+	return result
+}
+
+// evalLet binds the variables in a new environment and evaluates the body in it.
+// if sequential is true (let*), each init form can refer to the preceding variables.
+func evalLet(consCell *ast.ConsCell, sequential bool, env *object.Environment) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined %s bindings", name), nil
+	}
+
+	bindings, ok := astListToSlice(cdr.Car())
+	if !ok {
+		return newError("%s bindings must be a list, got %s", name, cdr.Car().String()), nil
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("%s body must be a list, got %s", name, cdr.Cdr().String()), nil
+	}
+
+	extendedEnv := object.NewEnclosedEnvironment(env)
+
+	// let evaluates all the init forms before binding any of the variables
+	initEnv := env
+	if sequential {
+		initEnv = extendedEnv
+	}
+
+	symbols := make([]*ast.Symbol, len(bindings))
+	values := make([]object.Object, len(bindings))
+	for i, binding := range bindings {
+		symbol, init, err := parseLetBinding(binding)
+		if err != nil {
+			return newError("%s", err.Error()), nil
+		}
+
+		var value object.Object = Nil
+		if init != nil {
+			value = Eval(init, initEnv)
+			if isUnwinding(value) {
+				return value, nil
+			}
+		}
+
+		if sequential {
+			extendedEnv.Set(symbol.Value, value)
+		}
+		symbols[i], values[i] = symbol, value
+	}
+
+	for i, symbol := range symbols {
+		extendedEnv.Set(symbol.Value, values[i])
+	}
+
+	return evalBodyTail(body, extendedEnv)
+}
+
+// parseLetBinding parses var, (var) or (var init-form).
+// init is nil if the init form is omitted.
+func parseLetBinding(binding ast.SExpression) (*ast.Symbol, ast.SExpression, error) {
+	if symbol, ok := ast.AsSymbol(binding); ok {
+		if symbol.IsKeyword() {
+			return nil, nil, fmt.Errorf("keyword %s cannot be bound", symbol.Value)
+		}
+		return symbol, nil, nil
+	}
+
+	elements, ok := astListToSlice(binding)
+	if !ok || len(elements) == 0 || len(elements) > 2 {
+		return nil, nil, fmt.Errorf("invalid binding: %s", binding.String())
+	}
+
+	symbol, ok := ast.AsSymbol(elements[0])
+	if !ok {
+		return nil, nil, fmt.Errorf("variable must be a symbol, got %s", elements[0].String())
+	}
+	if symbol.IsKeyword() {
+		return nil, nil, fmt.Errorf("keyword %s cannot be bound", symbol.Value)
+	}
+
+	if len(elements) == 1 {
+		return symbol, nil, nil
+	}
+	return symbol, elements[1], nil
+}
+
+// evalFlet defines the local functions and evaluates the body in their scope.
+// if recursive is true (labels), the functions can refer to themselves and each other.
+func evalFlet(consCell *ast.ConsCell, recursive bool, env *object.Environment) (object.Object, *tailForm) {
+	name := consCell.Car().(*ast.SpecialForm).Value
+
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return newError("not defined %s definitions", name), nil
+	}
+
+	definitions, ok := astListToSlice(cdr.Car())
+	if !ok {
+		return newError("%s definitions must be a list, got %s", name, cdr.Car().String()), nil
+	}
+
+	body, ok := astListToSlice(cdr.Cdr())
+	if !ok {
+		return newError("%s body must be a list, got %s", name, cdr.Cdr().String()), nil
+	}
+
+	extendedEnv := object.NewEnclosedEnvironment(env)
+
+	// the functions defined by flet are closed over the outer environment
+	closureEnv := env
+	if recursive {
+		closureEnv = extendedEnv
+	}
+
+	for _, sexp := range definitions {
+		definition, ok := sexp.(*ast.ConsCell)
+		if !ok {
+			return newError("invalid function definition: %s", sexp.String()), nil
+		}
+
+		funcName, ok := definition.Car().(*ast.Symbol)
+		if !ok {
+			return newError("function name must be a symbol, got %s", definition.Car().String()), nil
+		}
+		if funcName.IsKeyword() {
+			return newError("keyword %s cannot be a function name", funcName.Value), nil
+		}
+
+		fn := newFunction(wrapInBlock(funcName, definition.Cdr()), closureEnv)
+		if isUnwinding(fn) {
+			return fn, nil
+		}
+
+		extendedEnv.SetFunction(funcName.Value, fn.(*object.Function))
+	}
+
+	return evalBodyTail(body, extendedEnv)
+}
@@ -1,251 +1,1600 @@
-package evaluator
-
-import (
-	"testing"
-
-	"github.com/JunNishimura/go-lisp/lexer"
-	"github.com/JunNishimura/go-lisp/object"
-	"github.com/JunNishimura/go-lisp/parser"
-)
-
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := object.NewEnvironment()
-
-	return Eval(program, env)
-}
-
-func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
-	result, ok := obj.(*object.Integer)
-	if ok {
-		if result.Value != expected {
-			t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
-			return false
-		}
-		return true
-	}
-
-	symbol, ok := obj.(*object.Symbol)
-	if ok {
-		integer, ok := symbol.Value.(*object.Integer)
-		if !ok {
-			t.Errorf("object is not Integer. got=%T (%+v)", result.Value, result.Value)
-			return false
-		}
-		if integer.Value != expected {
-			t.Errorf("object has wrong value. got=%d, want=%d", integer.Value, expected)
-			return false
-		}
-		return true
-	}
-
-	t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
-	return false
-}
-
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-		{"+5", 5},
-		{"-5", -5},
-		{"(+ . (1 . (2 . nil)))", 3},
-		{"(+ . (1 2))", 3},
-		{"(+ 5 5)", 10},
-		{"(- 5 5)", 0},
-		{"(* 5 5)", 25},
-		{"(/ 5 5)", 1},
-		{"(+ -5 5)", 0},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestLambda(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"((lambda () 5))", 5},
-		{"((lambda (x) x) 5)", 5},
-		{"((lambda (x y) (+ x y)) 5 5)", 10},
-		{"(+ ((lambda () 1)) ((lambda (x y) (+ x y)) 1 2))", 4},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestQuote(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"'5", "5"},
-		{"'-5", "-5"},
-		{"'(+ 1 2)", "(+ 1 2)"},
-		{"'(+ . (1 . (2 . nil)))", "(+ 1 2)"},
-		{"(quote 5)", "5"},
-		{"(quote -5)", "-5"},
-		{"(quote (+ 1 2))", "(+ 1 2)"},
-		{"(quote (+ . (1 . (2 . nil))))", "(+ 1 2)"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		if evaluated.Inspect() != tt.expected {
-			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
-		}
-	}
-}
-
-func TestBackQuote(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"`5", "5"},
-		{"`(+ 1 2)", "(+ 1 2)"},
-		{"`(+ 1 ,(+ 1 1))", "(+ 1 2)"},
-		{"`(+ ,((lambda () 1)) 2)", "(+ 1 2)"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		if evaluated.Inspect() != tt.expected {
-			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
-		}
-	}
-}
-
-func TestTrueExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"t", "T"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		if evaluated.Inspect() != tt.expected {
-			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
-		}
-	}
-}
-
-func testComparisonObject(t *testing.T, obj object.Object, expected string) {
-	if expected == "T" {
-		if obj != True {
-			t.Errorf("object is not TRUE. got=%T (%+v)", obj, obj)
-		}
-	} else {
-		if obj != Nil {
-			t.Errorf("object is not NIL. got=%T (%+v)", obj, obj)
-		}
-	}
-}
-
-func TestComparisonExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"(= 1)", "T"},
-		{"(= 1 1)", "T"},
-		{"(= 1 2)", "NIL"},
-		{"(/= 1)", "T"},
-		{"(/= 1 1)", "NIL"},
-		{"(/= 1 2)", "T"},
-		{"(< 1)", "T"},
-		{"(< 1 1)", "NIL"},
-		{"(< 1 2)", "T"},
-		{"(< 2 1)", "NIL"},
-		{"(> 1)", "T"},
-		{"(> 1 1)", "NIL"},
-		{"(> 1 2)", "NIL"},
-		{"(> 2 1)", "T"},
-		{"(<= 1)", "T"},
-		{"(<= 1 0)", "NIL"},
-		{"(<= 1 1)", "T"},
-		{"(<= 1 2)", "T"},
-		{"(<= 1)", "T"},
-		{"(<= 2 1)", "NIL"},
-		{"(<= 2 2)", "T"},
-		{"(<= 2 3)", "T"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testComparisonObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestIfExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"(if t 10)", "10"},
-		{"(if 0 10)", "10"},
-		{"(if 1 10)", "10"},
-		{"(if nil 10)", "nil"},
-		{"(if t 10 20)", "10"},
-		{"(if nil 10 20)", "20"},
-		{"(if (= 1 1) 10 20)", "10"},
-		{"(if (= 1 2) 10 20)", "20"},
-		{"(if t (+ 1 1) (+ 2 2))", "2"},
-		{"(if nil (+ 1 1) (+ 2 2))", "4"},
-		{"(if (= ((lambda (x y) (+ x y)) 1 1) (* 1 2)) 10 20)", "10"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		if evaluated.Inspect() != tt.expected {
-			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
-		}
-	}
-}
-
-func TestSetqExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"(setq x 10) x", 10},
-		{"(setq x 10) (setq x 20) x", 20},
-		{"(setq x 10) (setq y 20) (+ x y)", 30},
-		{"(setq x (+ 1 1)) x", 2},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestApplyExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"(setq f (lambda () (+ 1 1))) (apply f ())", 2},
-		{"(setq f (lambda (x) (+ x x))) (apply f '(1))", 2},
-		{"(setq f (lambda (x y) (+ x y))) (apply f '(1 1))", 2},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if ok {
+		if result.Value != expected {
+			t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+			return false
+		}
+		return true
+	}
+
+	symbol, ok := obj.(*object.Symbol)
+	if ok {
+		integer, ok := symbol.Value.(*object.Integer)
+		if !ok {
+			t.Errorf("object is not Integer. got=%T (%+v)", result.Value, result.Value)
+			return false
+		}
+		if integer.Value != expected {
+			t.Errorf("object has wrong value. got=%d, want=%d", integer.Value, expected)
+			return false
+		}
+		return true
+	}
+
+	t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+	return false
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"+5", 5},
+		{"-5", -5},
+		{"(+ . (1 . (2 . nil)))", 3},
+		{"(+ . (1 2))", 3},
+		{"(+ 5 5)", 10},
+		{"(- 5 5)", 0},
+		{"(* 5 5)", 25},
+		{"(/ 5 5)", 1},
+		{"(+ -5 5)", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalNumericTower(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"1e10", "1.0e10"},
+		{"1.5e-5", "1.5e-5"},
+		{"-0.5", "-0.5"},
+		{"2/3", "2/3"},
+		{"4/2", "2"},
+		{"-1/2", "-1/2"},
+		{"(/ 7 2)", "7/2"},
+		{"(/ 1 3)", "1/3"},
+		{"(/ 6 3)", "2"},
+		{"(/ 4)", "1/4"},
+		{"(+ 1/3 2/3)", "1"},
+		{"(+ 1/2 1/3)", "5/6"},
+		{"(* 2/3 3/4)", "1/2"},
+		{"(- 1/2)", "-1/2"},
+		{"(+ 1 2.5)", "3.5"},
+		{"(+ 1/2 0.5)", "1.0"},
+		{"(* 2 1.5)", "3.0"},
+		{"(/ 7.0 2)", "3.5"},
+		{"(- 10 0.5)", "9.5"},
+		{"(+)", "0"},
+		{"(*)", "1"},
+		{"(float 1/4)", "0.25"},
+		{"(numerator 6/4)", "3"},
+		{"(denominator 6/4)", "2"},
+		{"(= 1 1.0)", "T"},
+		{"(= 1/2 0.5)", "T"},
+		{"(< 1/3 0.34 1/2 1)", "T"},
+		{"(= 99999999999999999999 1e20)", "nil"},
+		{"(< 99999999999999999999 1e20)", "T"},
+		{"(= 1/3 0.3333333333333333)", "nil"},
+		{"(= 9007199254740993 9007199254740992.0)", "nil"},
+		{"(> 1e300 99999999999999999999)", "T"},
+		{"(> 1 0.5 1/3)", "T"},
+		{"(< 1/2 1/3)", "nil"},
+		{"(/= 1 2 1)", "nil"},
+		{"(/= 1 2 3)", "T"},
+		{"(integerp 2/2)", "T"},
+		{"(rationalp 1/2)", "T"},
+		{"(floatp 1/2)", "nil"},
+		{"(numberp 1.5)", "T"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBignumArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"99999999999999999999", "99999999999999999999"},
+		{"-99999999999999999999", "-99999999999999999999"},
+		{"(* 9999999999 9999999999)", "99999999980000000001"},
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(- -9223372036854775808 1)", "-9223372036854775809"},
+		{"(- -9223372036854775807 1)", "-9223372036854775808"},
+		{"(* -1 -9223372036854775808)", "9223372036854775808"},
+		{"(- (+ 9223372036854775807 1) 1)", "9223372036854775807"},
+		{"(* 4294967296 4294967296 4294967296)", "79228162514264337593543950336"},
+		{"(/ 99999999999999999999 3)", "33333333333333333333"},
+		{"(/ 99999999999999999999 99999999999999999999)", "1"},
+		{"(/ 1 99999999999999999999)", "1/99999999999999999999"},
+		{"(< 9223372036854775807 99999999999999999999)", "T"},
+		{"(= 99999999999999999999 99999999999999999999)", "T"},
+		{"(float 99999999999999999999)", "1.0e20"},
+		{"(integerp 99999999999999999999)", "T"},
+		{`(parse-integer "123456789012345678901234567890")`, "123456789012345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBignumDemotion(t *testing.T) {
+	evaluated := testEval("(- (* 9999999999 9999999999) 99999999980000000000)")
+	testIntegerObject(t, evaluated, 1)
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{
+		"(/ 1 0)",
+		"(/ 0)",
+		"(/ 1/2 0)",
+		"(/ 1.5 0.0)",
+	}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input=%s: object is not Error. got=%T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "division by zero" {
+			t.Errorf("wrong error message. got=%q", errObj.Message)
+		}
+	}
+}
+
+func TestLambda(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"((lambda () 5))", 5},
+		{"((lambda (x) x) 5)", 5},
+		{"((lambda (x y) (+ x y)) 5 5)", 10},
+		{"(+ ((lambda () 1)) ((lambda (x y) (+ x y)) 1 2))", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"'5", "5"},
+		{"'-5", "-5"},
+		{"'(+ 1 2)", "(+ 1 2)"},
+		{"'(+ . (1 . (2 . nil)))", "(+ 1 2)"},
+		{"(quote 5)", "5"},
+		{"(quote -5)", "-5"},
+		{"(quote (+ 1 2))", "(+ 1 2)"},
+		{"(quote (+ . (1 . (2 . nil))))", "(+ 1 2)"},
+		{"'hoge", "hoge"},
+		{`'"hello"`, `"hello"`},
+		{"'(1 . 2)", "(1 . 2)"},
+		{"'(1 2 . 3)", "(1 2 . 3)"},
+		{"'(a (b c) d)", "(a (b c) d)"},
+		{"''a", "(quote a)"},
+		{"'(lambda (x) x)", "(lambda (x) x)"},
+		{"'()", "nil"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBackQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`5", "5"},
+		{"`(+ 1 2)", "(+ 1 2)"},
+		{"`(+ 1 ,(+ 1 1))", "(+ 1 2)"},
+		{"`(+ ,((lambda () 1)) 2)", "(+ 1 2)"},
+		{"`(a . ,(+ 1 1))", "(a . 2)"},
+		{"`(a ,'(b c))", "(a (b c))"},
+		{"(setq x 1) `(x ,x)", "(x 1)"},
+		{"(setq x '(1 2)) `(a ,@x b)", "(a 1 2 b)"},
+		{"(setq x '(1 2)) `(a ,@x)", "(a 1 2)"},
+		{"(setq x '(1 2)) `(,@x ,@x)", "(1 2 1 2)"},
+		{"(setq x '(1 2)) `(a ,@x . b)", "(a 1 2 . b)"},
+		{"`(a ,@'() b)", "(a b)"},
+		{"`(a ,@3)", "(a . 3)"},
+		{"`(a ,@3 b)", ",@ must be followed by a proper list, got 3"},
+		{"(setq x '(1 2)) `,@x", ",@ must be an element of a list, got (unquote-splicing x)"},
+		{"(setq x '(1 2)) (eq (cdr `(a ,@x)) x)", "T"},
+		{"(setq x '(1 2)) (eq (cdr `(a ,@x b)) x)", "nil"},
+		{"(setq d 'dd) `(a `(b ,(c ,d)))", "(a (backquote (b (unquote (c dd)))))"},
+		{"(setq d 'dd) `(a `(b ,,d))", "(a (backquote (b (unquote dd))))"},
+		{"(setq x '(1 2)) `(a `(b ,@,x ,',x))", "(a (backquote (b (unquote-splicing (1 2)) (unquote (quote (1 2))))))"},
+		{"`(a `(b ,(c ,@'(1 2))))", "(a (backquote (b (unquote (c 1 2)))))"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestTrueExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"t", "T"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testComparisonObject(t *testing.T, obj object.Object, expected string) {
+	if expected == "T" {
+		if obj != True {
+			t.Errorf("object is not TRUE. got=%T (%+v)", obj, obj)
+		}
+	} else {
+		if obj != Nil {
+			t.Errorf("object is not NIL. got=%T (%+v)", obj, obj)
+		}
+	}
+}
+
+func TestComparisonExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(= 1)", "T"},
+		{"(= 1 1)", "T"},
+		{"(= 1 2)", "NIL"},
+		{"(/= 1)", "T"},
+		{"(/= 1 1)", "NIL"},
+		{"(/= 1 2)", "T"},
+		{"(< 1)", "T"},
+		{"(< 1 1)", "NIL"},
+		{"(< 1 2)", "T"},
+		{"(< 2 1)", "NIL"},
+		{"(> 1)", "T"},
+		{"(> 1 1)", "NIL"},
+		{"(> 1 2)", "NIL"},
+		{"(> 2 1)", "T"},
+		{"(<= 1)", "T"},
+		{"(<= 1 0)", "NIL"},
+		{"(<= 1 1)", "T"},
+		{"(<= 1 2)", "T"},
+		{"(<= 1)", "T"},
+		{"(<= 2 1)", "NIL"},
+		{"(<= 2 2)", "T"},
+		{"(<= 2 3)", "T"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testComparisonObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(if t 10)", "10"},
+		{"(if 0 10)", "10"},
+		{"(if 1 10)", "10"},
+		{"(if nil 10)", "nil"},
+		{"(if t 10 20)", "10"},
+		{"(if nil 10 20)", "20"},
+		{"(if (= 1 1) 10 20)", "10"},
+		{"(if (= 1 2) 10 20)", "20"},
+		{"(if t (+ 1 1) (+ 2 2))", "2"},
+		{"(if nil (+ 1 1) (+ 2 2))", "4"},
+		{"(if (= ((lambda (x y) (+ x y)) 1 1) (* 1 2)) 10 20)", "10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(cond ((= 1 2) 'a) ((= 1 1) 'b) (t 'c))", "b"},
+		{"(cond (nil 'a) (t 'b))", "b"},
+		{"(cond (nil 'a))", "nil"},
+		{"(cond)", "nil"},
+		{"(cond ((+ 1 2)))", "3"},
+		{"(cond (t (setq x 1) (+ x 1)))", "2"},
+		{"(cond (1))", "1"},
+		{"(cond 1)", "invalid cond clause: 1"},
+		{"(when t 1 2)", "2"},
+		{"(when nil 1 2)", "nil"},
+		{"(when t)", "nil"},
+		{"(unless nil 1 2)", "2"},
+		{"(unless t 1 2)", "nil"},
+		{"(when)", "not defined when condition"},
+		{"(and)", "T"},
+		{"(and 1 2 3)", "3"},
+		{"(and 1 nil (car 1))", "nil"},
+		{"(or)", "nil"},
+		{"(or nil 2 (car 1))", "2"},
+		{"(or nil nil)", "nil"},
+		{"(let ((x 1)) (or (setq x (+ x 1)) x))", "2"},
+		{"(not nil)", "T"},
+		{"(not 1)", "nil"},
+		{"(funcall #'not nil)", "T"},
+		{"'(cond when and or)", "(cond when and or)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestCaseAndTypecase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(case 2 (1 'a) (2 'b) (3 'c))", "b"},
+		{"(case 'y ((x y) 'a) (z 'b))", "a"},
+		{"(case :k (:j 1) (:k 2))", "2"},
+		{"(case 1.5 (1.5 'a))", "a"},
+		{"(case 4 (1 'a) (otherwise 'other))", "other"},
+		{"(case 4 (1 'a) (t 'other))", "other"},
+		{"(case 4 (1 'a))", "nil"},
+		{"(case nil (nil 'a) ((nil) 'b))", "b"},
+		{"(case 1 (1))", "nil"},
+		{"(case 1 (t 'a) (1 'b))", "case: the otherwise clause must be the last one"},
+		{"(ecase 2 (1 'a) (2 'b))", "b"},
+		{"(ecase 3 (1 'a) (2 'b))", "ecase: 3 fell through"},
+		{"(ecase 3 (otherwise 'a))", "ecase does not allow the otherwise clause"},
+		{"(handler-case (ecase 3) (type-error () 'caught))", "caught"},
+		{"(typecase 1 (string 'a) (integer 'b))", "b"},
+		{"(typecase \"s\" ((or symbol string) 'a))", "a"},
+		{"(typecase 'x (keyword 'a) (symbol 'b))", "b"},
+		{"(typecase nil (cons 'a) (list 'b))", "b"},
+		{"(typecase 1/2 (integer 'a) (rational 'b))", "b"},
+		{"(typecase 1.5 (integer 'a) (t 'b))", "b"},
+		{"(typecase 1.5 (integer 'a))", "nil"},
+		{"(typecase 3 ((member 1 2) 'a) ((not float) 'b))", "b"},
+		{"(typecase 3 ((and integer (not (eql 3))) 'a) (otherwise 'b))", "b"},
+		{"(typecase #'car (function 'a))", "a"},
+		{"(typecase (make-condition 'simple-error) (warning 'a) (error 'b))", "b"},
+		{"(typecase 1 (foo 'a))", "unknown type specifier: foo"},
+		{"(etypecase 'x (integer 'a))", "etypecase: x fell through"},
+		{"(typep 1 'integer)", "T"},
+		{"(typep 1 'string)", "nil"},
+		{"(typep :k '(or keyword string))", "T"},
+		{"(typep nil 'symbol)", "T"},
+		{"(typep 1 nil)", "nil"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestSetqExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"(setq x 10) x", 10},
+		{"(setq x 10) (setq x 20) x", 20},
+		{"(setq x 10) (setq y 20) (+ x y)", 30},
+		{"(setq x (+ 1 1)) x", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestApplyExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"(setq f (lambda () (+ 1 1))) (apply f ())", 2},
+		{"(setq f (lambda (x) (+ x x))) (apply f '(1))", 2},
+		{"(setq f (lambda (x y) (+ x y))) (apply f '(1 1))", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello world"`, `"hello world"`},
+		{`""`, `""`},
+		{`"a\"b"`, `"a\"b"`},
+		{`(setq s "hello") s`, `"hello"`},
+		{`((lambda (s) s) "hello")`, `"hello"`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(length "hello")`, "5"},
+		{`(length "")`, "0"},
+		{`(length "h\u00e9llo")`, "5"},
+		{`(concatenate 'string "hello" " " "world")`, `"hello world"`},
+		{`(concatenate 'string)`, `""`},
+		{`(subseq "hello world" 6)`, `"world"`},
+		{`(subseq "hello world" 0 5)`, `"hello"`},
+		{`(string= "abc" "abc")`, "T"},
+		{`(string= "abc" "abd")`, "nil"},
+		{`(string< "abc" "abd")`, "2"},
+		{`(string< "ab" "abc")`, "2"},
+		{`(string< "abd" "abc")`, "nil"},
+		{`(string< "abc" "abc")`, "nil"},
+		{`(string-upcase "Hello")`, `"HELLO"`},
+		{`(string-downcase "Hello")`, `"hello"`},
+		{`(string-trim " " "  hello  ")`, `"hello"`},
+		{`(search "lo" "hello")`, "3"},
+		{`(search "xyz" "hello")`, "nil"},
+		{`(char "hello" 1)`, `#\e`},
+		{`(char "a b" 1)`, `#\Space`},
+		{`(parse-integer "123")`, "123"},
+		{`(parse-integer " -42 ")`, "-42"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(length 1)`, "argument to `length` must be SEQUENCE, got INTEGER"},
+		{`(concatenate "a" "b")`, "first argument to `concatenate` must be 'string, got \"a\""},
+		{`(subseq "hello" 3 10)`, "bounding indices 3 and 10 are out of range for \"hello\""},
+		{`(string= "a" 1)`, "argument to `string=` must be STRING, got INTEGER"},
+		{`(char "hello" 5)`, "index 5 is out of range for \"hello\""},
+		{`(parse-integer "12a")`, "could not parse \"12a\" as integer"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input=%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestQuotedData(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(car '(1 2 3))", "1"},
+		{"(cdr '(1 2 3))", "(2 3)"},
+		{"(car (cdr '(1 2 3)))", "2"},
+		{"(car '())", "nil"},
+		{"(cdr nil)", "nil"},
+		{"(first '(a b))", "a"},
+		{"(rest '(a b))", "(b)"},
+		{"(cons 1 2)", "(1 . 2)"},
+		{"(cons 1 '(2 3))", "(1 2 3)"},
+		{"(cons 1 nil)", "(1)"},
+		{"(list 1 2 3)", "(1 2 3)"},
+		{"(list)", "nil"},
+		{"(list 'a (list 'b))", "(a (b))"},
+		{"(atom 1)", "T"},
+		{"(atom 'a)", "T"},
+		{"(atom nil)", "T"},
+		{"(atom '(1))", "nil"},
+		{"(consp '(1))", "T"},
+		{"(consp nil)", "nil"},
+		{"(listp nil)", "T"},
+		{"(listp 1)", "nil"},
+		{"(null nil)", "T"},
+		{"(null '())", "T"},
+		{"(null '(1))", "nil"},
+		{"(eq 'a 'a)", "T"},
+		{"(eq 'a 'b)", "nil"},
+		{"(eq 1 1)", "T"},
+		{"(eq '(1) '(1))", "nil"},
+		{"(setq x '(1)) (eq x x)", "T"},
+		{"(eql 1.5 1.5)", "T"},
+		{"(eql 1 1.0)", "nil"},
+		{"(equal '(1 (2 \"a\")) '(1 (2 \"a\")))", "T"},
+		{"(equal '(1 2) '(1 3))", "nil"},
+		{"(length '(1 2 3))", "3"},
+		{"(apply (lambda (x y) (+ x y)) 1 '(2))", "3"},
+		{"(apply (lambda (x) (car x)) '((a b)))", "a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestConvertObjectToSExpression(t *testing.T) {
+	tests := []string{
+		"1",
+		"-1",
+		"99999999999999999999",
+		"1.5",
+		"1/3",
+		`"hello"`,
+		"hoge",
+		"t",
+		"nil",
+		"(1 . 2)",
+		"(+ 1 (* 2 3))",
+		"(lambda (x) (if x 'a \"b\"))",
+		"`(a ,b)",
+	}
+
+	for _, input := range tests {
+		quoted := testEval("'" + input)
+		sexp := convertObjectToSExpression(quoted)
+		if sexp == nil {
+			t.Errorf("input=%s: could not convert %T", input, quoted)
+			continue
+		}
+
+		expected := testParseProgram(input).Expressions[0]
+		if sexp.String() != expected.String() {
+			t.Errorf("not equal. got=%q, want=%q", sexp.String(), expected.String())
+		}
+		roundTrip := convertSExpressionToObject(sexp, object.NewEnvironment())
+		if roundTrip.Inspect() != quoted.Inspect() {
+			t.Errorf("round trip is not equal. got=%q, want=%q", roundTrip.Inspect(), quoted.Inspect())
+		}
+	}
+
+	// the objects without representation in the source code are embedded as they are
+	for _, input := range []string{`(char "abc" 1)`, "#'car", "(lambda (x) x)", "(list 1 #'car)"} {
+		obj := testEval(input)
+		sexp := convertObjectToSExpression(obj)
+
+		roundTrip := convertSExpressionToObject(sexp, object.NewEnvironment())
+		if roundTrip.Inspect() != obj.Inspect() {
+			t.Errorf("input=%s: round trip is not equal. got=%q, want=%q", input, roundTrip.Inspect(), obj.Inspect())
+		}
+	}
+}
+
+func TestDefun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defun double (x) (* x 2))", "double"},
+		{"(defun double (x) (* x 2)) (double 21)", "42"},
+		{"(defun fact (n) (if (= n 0) 1 (* n (fact (- n 1))))) (fact 5)", "120"},
+		{"(defun fact (n) (if (= n 0) 1 (* n (fact (- n 1))))) (fact 25)", "15511210043330985984000000"},
+		{"(defun f () 1) (defun f () 2) (f)", "2"},
+		{"(setq n 10) (defun add-n (x) (+ x n)) (add-n 1)", "11"},
+		{"(defun list (x) x) (list 1)", "1"},
+		{"(defun :f () 1)", "ERROR: keyword :f cannot be a function name"},
+		{"(flet ((:f () 1)) (:f))", "ERROR: keyword :f cannot be a function name"},
+		{"(labels ((:f () 1)) (:f))", "ERROR: keyword :f cannot be a function name"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionNamespace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(setq list 1) (list list 2)", "(1 2)"},
+		{"(defun f (x) (+ x 1)) (setq f 10) (f f)", "11"},
+		{"((lambda (car) (car (list car))) 1)", "1"},
+		{"(function car)", "builtin function"},
+		{"(funcall #'car '(1 2))", "1"},
+		{"(funcall (function list) 1 2)", "(1 2)"},
+		{"(funcall 'list 1 2)", "(1 2)"},
+		{"(funcall #'(lambda (x) (* x x)) 3)", "9"},
+		{"(funcall (lambda (x) (* x x)) 3)", "9"},
+		{"(defun double (x) (* x 2)) (funcall #'double 2)", "4"},
+		{"(defun double (x) (* x 2)) (apply #'double '(3))", "6"},
+		{"(setq f #'car) (funcall f '(a b))", "a"},
+		{"(defun f () 1) (fboundp 'f)", "T"},
+		{"(fboundp 'car)", "T"},
+		{"(fboundp 'undefined)", "nil"},
+		{"(setq x 1) (fboundp 'x)", "nil"},
+		{"(defun f () 1) (fmakunbound 'f) (fboundp 'f)", "nil"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestUndefinedFunction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(undefined 1)", "undefined function: undefined"},
+		{"(setq f (lambda () 1)) (f)", "undefined function: f"},
+		{"(defun f () 1) (fmakunbound 'f) (f)", "undefined function: f"},
+		{"(funcall 'undefined)", "undefined function: undefined"},
+		{"car", "symbol not found: car"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input=%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestLambdaListKeywords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((lambda (a &optional b) (list a b)) 1)", "(1 nil)"},
+		{"((lambda (a &optional (b 2)) (list a b)) 1)", "(1 2)"},
+		{"((lambda (a &optional (b 2)) (list a b)) 1 3)", "(1 3)"},
+		{"((lambda (a &optional (b (* a 10))) b) 1)", "10"},
+		{"((lambda (&optional (a 1 a-p)) (list a a-p)))", "(1 nil)"},
+		{"((lambda (&optional (a 1 a-p)) (list a a-p)) 5)", "(5 T)"},
+		{"((lambda (a &rest rest) rest) 1 2 3)", "(2 3)"},
+		{"((lambda (a &rest rest) rest) 1)", "nil"},
+		{"((lambda (&key a (b 2)) (list a b)) :b 3 :a 1)", "(1 3)"},
+		{"((lambda (&key a (b 2)) (list a b)))", "(nil 2)"},
+		{"((lambda (&key (a 1 a-p)) (list a a-p)) :a 1)", "(1 T)"},
+		{"((lambda (&key ((:name n) 0)) n) :name 5)", "5"},
+		{"((lambda (&key a) a) :a 1 :a 2)", "1"},
+		{"((lambda (&key a &allow-other-keys) a) :a 1 :b 2)", "1"},
+		{"((lambda (&key a) a) :a 1 :b 2 :allow-other-keys t)", "1"},
+		{"((lambda (&rest args &key a b) (list args a b)) :a 1 :b 2)", "((:a 1 :b 2) 1 2)"},
+		{"((lambda (a &aux (b (* a 2)) c) (list a b c)) 1)", "(1 2 nil)"},
+		{"(defun my-max (x &rest xs) (if (null xs) x (apply #'my-max (if (> x (car xs)) x (car xs)) (cdr xs)))) (my-max 3 9 2 7)", "9"},
+		{"(lambda (a &optional (b 1)) b)", "(lambda (a &optional (b 1)) b)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLambdaListErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((lambda (a) a))", "function expects 1 arguments, but got 0"},
+		{"((lambda (a &optional b) a))", "function expects at least 1 arguments, but got 0"},
+		{"((lambda (a &optional b) a) 1 2 3)", "function expects at most 2 arguments, but got 3"},
+		{"((lambda (&key a) a) :a)", "odd number of keyword arguments: (:a)"},
+		{"((lambda (&key a) a) :b 1)", "unknown keyword argument: :b"},
+		{"((lambda (&key a) a) 1 2)", "keyword argument must be KEYWORD, got 1"},
+		{"(lambda (&rest) 1)", "&rest must be followed by a parameter"},
+		{"(lambda (&rest a b) 1)", "&rest must be followed by only one parameter"},
+		{"(lambda (&key a &optional b) 1)", "misplaced lambda list keyword: &optional"},
+		{"(lambda (&body a) 1)", "&body is only allowed in macro lambda lists"},
+		{"(lambda (&foo a) 1)", "unknown lambda list keyword: &foo"},
+		{"(lambda ((a)) 1)", "parameter must be a symbol, got (a)"},
+		{"(lambda (&optional (1)) 1)", "parameter must be a symbol, got 1"},
+		{"(lambda (&key ((:a 1))) 1)", "parameter must be a symbol, got 1"},
+		{"(lambda (&optional (a nil 1)) 1)", "supplied-p parameter must be a symbol, got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input=%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestSpecialFormNamesAsVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defun f (function) function) (f 1)", "1"},
+		{"(let ((block 1)) block)", "1"},
+		{"(let* ((if 1) (when (+ if 1))) (list if when))", "(1 2)"},
+		{"(setq go 1) go", "1"},
+		{"(let ((catch 1)) (setq catch 2) catch)", "2"},
+		{"(funcall (lambda (&optional (return 1) &key (throw 2)) (list return throw)))", "(1 2)"},
+		{"(defun make-getter (quote) (lambda () quote)) (funcall (make-getter 3))", "3"},
+		{"(let ((block 1)) (block block (return-from block block)))", "1"},
+		{"(let ((n 0)) (tagbody go (setq n (+ n 1)) (if (< n 3) (go go))) n)", "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestMultiFormBody(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((lambda (x) (setq y x) (* y 2)) 3)", "6"},
+		{"((lambda ()))", "nil"},
+		{"(defun f (x) (setq n x) (+ n 1)) (f 1)", "2"},
+		{"(defun f (x) (setq n x) (+ n 1)) (f 1) n", "1"},
+		{"(lambda (x) x (+ x 1))", "(lambda (x) x (+ x 1))"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestProgn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(progn)", "nil"},
+		{"(progn 1 2 3)", "3"},
+		{"(progn (setq x 1) (+ x 1))", "2"},
+		{"(prog1 1 2 3)", "1"},
+		{"(setq x 1) (prog1 x (setq x 2))", "1"},
+		{"(prog2 1 2 3)", "2"},
+		{"(prog1)", "prog1 expects at least 1 forms, but got 0"},
+		{"(prog2 1)", "prog2 expects at least 2 forms, but got 1"},
+		{"(progn 1 (car 1) 3)", "argument to `car` must be LIST, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(let ((x 1) (y 2)) (+ x y))", "3"},
+		{"(let (x (y)) (list x y))", "(nil nil)"},
+		{"(let ((x 1)) (setq x 2) x)", "2"},
+		{"(setq x 1) (let ((x 2) (y x)) y)", "1"},
+		{"(setq x 1) (let* ((x 2) (y x)) y)", "2"},
+		{"(setq x 1) (let ((x 2)) x) x", "1"},
+		{"(setq x 1) (let ((y 2)) (setq x y)) x", "2"},
+		{"(let ((x 1)) (let ((x 2)) x))", "2"},
+		{"(let ((n 0)) (defun counter () (setq n (+ n 1)))) (counter) (counter)", "2"},
+		{"(let ((x 1)))", "nil"},
+		{"(let ((1 2)) 1)", "variable must be a symbol, got 1"},
+		{"(let ((x 1 2)) x)", "invalid binding: (x 1 2)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestFletAndLabels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(flet ((double (x) (* x 2))) (double 3))", "6"},
+		{"(flet ((f (x) (setq y x) (* y 2))) (f 4))", "8"},
+		{"(flet ((car (x) x)) (car 1))", "1"},
+		{"(flet ((car (x) x)) (car 1)) (car '(2))", "2"},
+		{"(defun f () 1) (flet ((f () 2) (g () (f))) (g))", "1"},
+		{"(labels ((f () 2) (g () (f))) (g))", "2"},
+		{"(labels ((fact (n) (if (= n 0) 1 (* n (fact (- n 1)))))) (fact 5))", "120"},
+		{"(flet ((double (x) (* x 2))) (funcall #'double 5))", "10"},
+		{"(let ((y 10)) (flet ((add-y (x) (+ x y))) (add-y 1)))", "11"},
+		{"(flet ((f () 1)) (f)) (f)", "undefined function: f"},
+		{"(flet (foo) 1)", "invalid function definition: foo"},
+		{"(labels (1) 1)", "invalid function definition: 1"},
+		{"(flet 'x)", "invalid function definition: quote"},
+		{"(flet ((1 () 1)) 1)", "function name must be a symbol, got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(block b 1 2)", "2"},
+		{"(block b 1 (return-from b 2) 3)", "2"},
+		{"(block b (return-from b))", "nil"},
+		{"(block nil 1 (return 2) 3)", "2"},
+		{"(block outer (block inner (return-from outer 1)) 2)", "1"},
+		{"(block b (+ 1 (return-from b 10)))", "10"},
+		{"(block b (let ((x 1)) (return-from b x)))", "1"},
+		{"(block outer (block inner (return-from outer 1) 2) 3)", "1"},
+		{"(block outer (block inner (return-from inner 1) 2))", "1"},
+		{"(block outer (progn (block inner (return-from inner 1))) 2)", "2"},
+		{"(defun f (x) (if (> x 0) (return-from f 'positive)) 'other) (f 1)", "positive"},
+		{"(defun f (x) (if (> x 0) (return-from f 'positive)) 'other) (f 0)", "other"},
+		{"(block b (funcall (lambda () (return-from b 1))) 2)", "1"},
+		{"(labels ((f () (return-from f 1) 2)) (f))", "1"},
+		{"(return-from b 1)", "return-from: no block named b is visible"},
+		{"(setq f (block b (lambda () (return-from b 1)))) (funcall f)", "return-from: block b has already exited"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestTagbody(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(tagbody 1 2)", "nil"},
+		{"(setq n 0) (tagbody start (setq n (+ n 1)) (if (< n 10) (go start))) n", "10"},
+		{"(setq x 0) (tagbody (go skip) (setq x 1) skip) x", "0"},
+		{"(setq x 0) (tagbody (go 10) (setq x 1) 10 (setq x 2)) x", "2"},
+		{"(setq x 0) (tagbody (funcall (lambda () (go end))) (setq x 1) end) x", "0"},
+		{"(setq x 0) (tagbody (tagbody (go end)) (setq x 1) end) x", "0"},
+		{"(go end)", "go: no tag named end is visible"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestCatchThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(catch 'a 1 2)", "2"},
+		{"(catch 'a (throw 'a 1) 2)", "1"},
+		{"(catch 'a (catch 'b (throw 'a 1)) 2)", "1"},
+		{"(catch 'a (catch 'a (throw 'a 1)) 2)", "2"},
+		{"(defun f () (throw 'done 42)) (catch 'done (f) 0)", "42"},
+		{"(catch 'a (+ 1 (throw 'a 10)))", "10"},
+		{"(throw 'a 1)", "throw: no catch for tag a"},
+		{"(catch 'a (throw 'b 1))", "throw: no catch for tag b"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnwindProtect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(unwind-protect 1 2)", "1"},
+		{"(setq x 0) (unwind-protect 1 (setq x 2)) x", "2"},
+		{"(setq x 0) (block b (unwind-protect (return-from b 1) (setq x 2))) x", "2"},
+		{"(setq x 0) (catch 'a (unwind-protect (throw 'a 1) (setq x 2))) x", "2"},
+		{"(setq x 0) (tagbody (unwind-protect (go end) (setq x 2)) end) x", "2"},
+		{"(block b (unwind-protect (return-from b 1) 2))", "1"},
+		{"(block b (unwind-protect 1 (return-from b 2)))", "2"},
+		{"(unwind-protect (car 1) 2)", "argument to `car` must be LIST, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnwindProtectOnError(t *testing.T) {
+	env := object.NewEnvironment()
+
+	evaluated := Eval(parser.New(lexer.New("(setq x 0) (unwind-protect (car 1) (setq x 2))")).ParseProgram(), env)
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	x, ok := env.Get("x")
+	if !ok {
+		t.Fatalf("x is not bound")
+	}
+	testIntegerObject(t, x, 2)
+}
+
+func TestHandlerCase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(handler-case 1 (error () 2))", "1"},
+		{"(handler-case (error \"oops\") (error () 2))", "2"},
+		{"(handler-case (error \"bad ~a\" 1) (error (c) (princ-to-string c)))", "\"bad 1\""},
+		{"(handler-case (car 1) (error (c) (princ-to-string c)))", "\"argument to `car` must be LIST, got INTEGER\""},
+		{"(handler-case (/ 1 0) (division-by-zero () 'div) (error () 'other))", "div"},
+		{"(handler-case (/ 1 0) (arithmetic-error () 'arith))", "arith"},
+		{"(handler-case (car 1) (type-error () 'type))", "type"},
+		{"(handler-case (+ 1 \"a\") (type-error () 'type))", "type"},
+		{"(handler-case (symbol-name 1) (type-error () 'type))", "type"},
+		{"(handler-case (undefined) (undefined-function () 'undefined))", "undefined"},
+		{"(handler-case x (unbound-variable () 'unbound))", "unbound"},
+		{"(handler-case (error \"oops\") (t () 'any))", "any"},
+		{"(handler-case (signal \"hello\") (condition () 'signaled))", "signaled"},
+		{"(handler-case (signal \"hello\") (error () 'error))", "nil"},
+		{"(handler-case (+ 1 2) (error () 0) (:no-error (x) (* x 10)))", "30"},
+		{"(handler-case (handler-case (error \"inner\") (warning () 'warning)) (error () 'outer))", "outer"},
+		{"(defun safe-div (a b) (handler-case (/ a b) (division-by-zero () 0))) (safe-div 10 0)", "0"},
+		{"(setq x 0) (handler-case (unwind-protect (error \"oops\") (setq x 1)) (error () x))", "1"},
+		{"(handler-case (error \"oops\") (warning () 'warning))", "oops"},
+		{"(ignore-errors (error \"oops\") 1)", "nil"},
+		{"(ignore-errors 1 2)", "2"},
+		{"(ignore-errors (car 1))", "nil"},
+		{"(handler-case 1 foo)", "invalid handler-case clause: foo"},
+		{"(handler-case 1 2)", "invalid handler-case clause: 2"},
+		{"(handler-case (x) 1)", "invalid handler-case clause: 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestHandlerBind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(setq log nil) (handler-case (handler-bind ((error (lambda (c) (setq log 'seen)))) (error \"oops\")) (error () log))", "seen"},
+		{"(handler-bind ((error (lambda (c) 'declined))) (error \"oops\"))", "oops"},
+		{"(block b (handler-bind ((error (lambda (c) (return-from b 'handled)))) (error \"oops\")))", "handled"},
+		{"(catch 'done (handler-bind ((error (lambda (c) (throw 'done 'thrown)))) (car 1)))", "thrown"},
+		{"(block b (handler-bind ((warning (lambda (c) (return-from b 'warning)))) (error \"oops\")))", "oops"},
+		{"(setq n 0) (handler-bind ((condition (lambda (c) (setq n (+ n 1))))) (signal \"a\") (signal \"b\")) n", "2"},
+		{"(block b (handler-bind ((error (lambda (c) (return-from b 'outer)))) (handler-bind ((error (lambda (c) (error \"again\")))) (error \"oops\"))))", "outer"},
+		{"(setq log nil) (block b (handler-bind ((error (lambda (c) (return-from b log)))) (handler-bind ((error (lambda (c) (setq log 'inner)))) (error \"oops\"))))", "inner"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRestarts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(restart-case 1 (use-value (v) v))", "1"},
+		{"(restart-case (invoke-restart 'use-value 2) (use-value (v) v))", "2"},
+		{"(restart-case (invoke-restart 'retry) (use-value (v) v) (retry () 'retried))", "retried"},
+		{"(restart-case (invoke-restart (find-restart 'use-value) 3) (use-value (v) (* v 2)))", "6"},
+		{"(defun parse (x) (restart-case (if (numberp x) x (error \"not a number: ~s\" x)) (use-value (v) v))) (handler-bind ((error (lambda (c) (use-value 0)))) (+ (parse 1) (parse \"a\")))", "1"},
+		{"(handler-bind ((error (lambda (c) (continue)))) (cerror \"ignore\" \"oops\") 'continued)", "continued"},
+		{"(restart-case (continue) (continue () 'continued))", "continued"},
+		{"(use-value 1)", "nil"},
+		{"(find-restart 'use-value)", "nil"},
+		{"(restart-case (length (compute-restarts)) (a () 1) (b () 2))", "2"},
+		{"(restart-case (invoke-restart 'retry) (use-value (v) v))", "invoke-restart: no restart named retry is active"},
+		{"(restart-case (invoke-restart 'use-value 1) (use-value (v) :report \"Use a value.\" v))", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestDefineCondition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(define-condition my-error (error) ())", "my-error"},
+		{"(define-condition my-error (error) ()) (handler-case (error 'my-error) (my-error () 'mine))", "mine"},
+		{"(define-condition my-error (error) ()) (handler-case (error 'my-error) (error () 'error))", "error"},
+		{"(define-condition my-error (error) ()) (define-condition sub-error (my-error) ()) (handler-case (error 'sub-error) (my-error () 'parent))", "parent"},
+		{"(define-condition my-warning (warning) ()) (handler-case (error 'my-warning) (error () 'error) (warning () 'warning))", "warning"},
+		{"(define-condition bad-input (error) ((text :initarg :text :reader bad-input-text))) (handler-case (error 'bad-input :text \"abc\") (bad-input (c) (bad-input-text c)))", "\"abc\""},
+		{"(define-condition bad-input (error) ((text :initarg :text :initform \"none\"))) (slot-value (make-condition 'bad-input) 'text)", "\"none\""},
+		{"(define-condition bad-input (error) ((text :initarg :text)) (:report \"bad input\")) (handler-case (error 'bad-input :text 1) (error (c) (princ-to-string c)))", "\"bad input\""},
+		{"(define-condition bad-input (error) ((text :initarg :text :reader text)) (:report (lambda (c s) (format nil \"bad input: ~a\" (text c))))) (error 'bad-input :text \"x\")", "bad input: x"},
+		{"(define-condition my-condition () ()) (handler-case (signal 'my-condition) (condition () 'condition))", "condition"},
+		{"(define-condition my-error (error) ()) (error 'my-error)", "condition my-error was signaled"},
+		{"(define-condition my-error (unknown) ())", "unknown condition type: unknown"},
+		{"(error 'unknown)", "unknown condition type: unknown"},
+		{"(define-condition my-error (error) ((a :initarg :a))) (make-condition 'my-error :b 1)", "unknown initarg :b for condition my-error"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestWarn(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       string
+		expectedOutput string
+	}{
+		{"(warn \"careful ~a\" 1) 'done", "done", "WARNING: careful 1\n"},
+		{"(handler-bind ((warning (lambda (c) (muffle-warning)))) (warn \"careful\") 'done)", "done", ""},
+		{"(handler-case (warn \"careful\") (warning (c) (princ-to-string c)))", "\"careful\"", ""},
+		{"(warn 'error)", "argument to `warn` must be WARNING, got #<condition error>", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		warningOutput = &out
+
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+		if out.String() != tt.expectedOutput {
+			t.Errorf("input=%s: expected output=%q, got=%q", tt.input, tt.expectedOutput, out.String())
+		}
+	}
+
+	warningOutput = os.Stderr
+}
+
+func writeLispFiles(t *testing.T, dir string, files map[string]string) {
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func testLoadResult(t *testing.T, input string, expected string) {
+	evaluated := testEval(input)
+	actual := evaluated.Inspect()
+	if errObj, ok := evaluated.(*object.Error); ok {
+		actual = errObj.Message
+		// the errors in the loaded files are reported with their positions
+		if errObj.Pos.Filename() != "" {
+			actual = errObj.Pos.String() + ": " + actual
+		}
+	}
+	if actual != expected {
+		t.Errorf("input=%s: expected=%q, got=%q", input, expected, actual)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	writeLispFiles(t, dir, map[string]string{
+		"lib.lisp":      "(defmacro twice (x) (list 'progn x x)) (defun square (x) (* x x)) (setq loaded 'yes)",
+		"use.lisp":      "(setq n 0) (twice (setq n (+ n 1)))",
+		"broken.lisp":   "(defun f (x) (+ x 1)) (car 1)",
+		"unclosed.lisp": "(defun f (x)",
+		"self.lisp":     fmt.Sprintf("(load %q)", path("self.lisp")),
+		"throw.lisp":    "(throw 'done 42)",
+		"nested.lisp":   fmt.Sprintf("(load %q) (setq n 0) (twice (setq n (+ n 1)))", path("lib.lisp")),
+		"helper.lisp":   "(defun square-form (x) (list '* x x)) (defmacro square-of (x) (square-form x)) (setq squared (square-of 4))",
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{fmt.Sprintf("(load %q) (list (square 3) loaded)", path("lib.lisp")), "(9 yes)"},
+		{fmt.Sprintf("(let ((loaded 'no)) (load %q)) loaded", path("lib.lisp")), "yes"},
+		{fmt.Sprintf("(load %q) (load %q) n", path("lib.lisp"), path("use.lisp")), "2"},
+		{fmt.Sprintf("(load %q) n", path("nested.lisp")), "2"},
+		{fmt.Sprintf("(load %q) squared", path("helper.lisp")), "16"},
+		{fmt.Sprintf("(load %q)", path("broken.lisp")), path("broken.lisp") + ":1:23: argument to `car` must be LIST, got INTEGER"},
+		{fmt.Sprintf("(load %q)", path("unclosed.lisp")), path("unclosed.lisp") + ":1:1: expected token to be ) to close this list, got EOF instead\n(defun f (x)\n^"},
+		{fmt.Sprintf("(load %q)", path("self.lisp")), path("self.lisp") + ":1:1: circular load: " + path("self.lisp") + " -> " + path("self.lisp")},
+		{fmt.Sprintf("(catch 'done (load %q))", path("throw.lisp")), "42"},
+		{fmt.Sprintf("(load %q)", path("missing.lisp")), "load: open " + path("missing.lisp") + ": no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		testLoadResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	dir := t.TempDir()
+	libDir := filepath.Join(dir, "lib")
+	if err := os.Mkdir(libDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeLispFiles(t, libDir, map[string]string{
+		"counter.lisp": "(setq count (+ count 1)) (provide 'counter)",
+		"utils.lisp":   "(require 'counter) (defun double (x) (* x 2)) (defmacro twice (x) (list '+ x x))",
+		"user.lisp":    "(require 'utils) (setq result (twice 5))",
+		"a.lisp":       "(require 'b)",
+		"b.lisp":       "(require 'a)",
+	})
+	t.Setenv(loadPathVariable, libDir)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(setq count 0) (require 'utils) (list (double 4) count)", "(8 1)"},
+		{"(setq count 0) (require 'user) result", "10"},
+		{"(setq count 0) (require 'counter) (require \"counter\") (require 'utils) count", "1"},
+		{"(require 'counter)", filepath.Join(libDir, "counter.lisp") + ":1:16: symbol not found: count"},
+		{"(provide 'utils) (require 'utils)", "nil"},
+		{"(require 'missing)", "require: module missing is not found in GOLISP_PATH"},
+		{"(require 'a)", filepath.Join(libDir, "b.lisp") + ":1:1: circular load: " +
+			filepath.Join(libDir, "a.lisp") + " -> " + filepath.Join(libDir, "b.lisp") + " -> " + filepath.Join(libDir, "a.lisp")},
+	}
+
+	for _, tt := range tests {
+		testLoadResult(t, tt.input, tt.expected)
+	}
+}
+
+func TestTailCallOptimization(t *testing.T) {
+	// the stack is limited so that the deep recursion crashes unless the tail calls run in constant stack space
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defun count-down (n) (if (= n 0) 'done (count-down (- n 1)))) (count-down 100000)", "done"},
+		{"(defun sum (n acc) (if (= n 0) acc (progn (sum (- n 1) (+ acc n))))) (sum 100000 0)", "5000050000"},
+		{"(defun loop (n) (let ((m (- n 1))) (if (< m 0) 'done (loop m)))) (loop 100000)", "done"},
+		{"(defun loop (n) (let* ((m (- n 1))) (if (< m 0) 'done (loop m)))) (loop 100000)", "done"},
+		{"(labels ((even-p (n) (if (= n 0) t (odd-p (- n 1)))) (odd-p (n) (if (= n 0) nil (even-p (- n 1))))) (even-p 100000))", "T"},
+		{"(defun loop (n) (flet ((next () (- n 1))) (if (= n 0) 'done (loop (next))))) (loop 100000)", "done"},
+		{"(defun loop (n) (if (= n 0) (return-from loop 'done)) (loop (- n 1))) (loop 100000)", "done"},
+		{"(defun loop (n) (block b (if (= n 0) 'done (loop (- n 1))))) (loop 100000)", "done"},
+		{"(defun loop (n) (cond ((= n 0) 'done) (t (loop (- n 1))))) (loop 100000)", "done"},
+		{"(defun loop (n) (when (> n 0) (loop (- n 1)))) (loop 100000)", "nil"},
+		{"(defun loop (n) (unless (= n 0) (loop (- n 1)))) (loop 100000)", "nil"},
+		{"(defun loop (n) (or (= n 0) (loop (- n 1)))) (loop 100000)", "T"},
+		{"(defun loop (n) (and (> n 0) (loop (- n 1)))) (loop 100000)", "nil"},
+		{"(defun loop (n) (case n (0 'done) (t (loop (- n 1))))) (loop 100000)", "done"},
+		{"(defun loop (n) (typecase n (null 'done) (t (loop (if (= n 0) nil (- n 1)))))) (loop 100000)", "done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defun f (n) (if (= n 0) 0 (+ 1 (f (- n 1))))) (f 10000000)", "stack overflow"},
+		{"(defun f (n) (if (= n 0) 0 (+ 1 (f (- n 1))))) (handler-case (f 10000000) (error () 'caught))", "caught"},
+		{"(defun f (n) (if (= n 0) 0 (+ 1 (f (- n 1))))) (f 10000)", "10000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func BenchmarkTailRecursion(b *testing.B) {
+	input := "(defun count-down (n) (if (= n 0) 'done (count-down (- n 1)))) (count-down 1000000)"
+
+	for i := 0; i < b.N; i++ {
+		evaluated := testEval(input)
+		if evaluated.Inspect() != "done" {
+			b.Fatalf("unexpected result: %s", evaluated.Inspect())
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	// the loop is stopped by Ctrl-C in the REPL
+	timer := time.AfterFunc(10*time.Millisecond, Interrupt)
+	defer timer.Stop()
+
+	evaluated := testEval("(defun loop-forever () (loop-forever)) (loop-forever)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "interrupted" {
+		t.Fatalf("expected interrupted error, got %s", evaluated.Inspect())
+	}
+
+	// the interrupt is consumed by the evaluation it stopped
+	testIntegerObject(t, testEval("(+ 1 2)"), 3)
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(car 1)", "test.lisp:1:1: ERROR: argument to `car` must be LIST, got INTEGER\n(car 1)\n^"},
+		{"(+ 1\n   x)", "test.lisp:2:4: ERROR: symbol not found: x\n   x)\n   ^"},
+		{"(defun f (x)\n  (car x))\n(f 1)", "test.lisp:2:3: ERROR: argument to `car` must be LIST, got INTEGER\n  (car x))\n  ^"},
+		{"(defmacro first-of (x) (list 'car x))\n(progn (first-of 1))", "test.lisp:2:8: ERROR: argument to `car` must be LIST, got INTEGER\n(progn (first-of 1))\n       ^"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("test.lisp", tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		env := object.NewEnvironment()
+		expanded := testExpandMacros(t, program, env)
+
+		evaluated := Eval(expanded, env)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("input=%q: expected error, got %s", tt.input, evaluated.Inspect())
+		}
+		if errObj.Report() != tt.expected {
+			t.Errorf("input=%q: expected=%q, got=%q", tt.input, tt.expected, errObj.Report())
+		}
+	}
+}
+
+func TestSymbolNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defun 1+ (n) (+ n 1)) (1+ 2)", "3"},
+		{"(defun list->pair (a b) (cons a b)) (list->pair 1 2)", "(1 . 2)"},
+		{"(setq |my var| 10) (setq a2 5) (- |my var| a2)", "5"},
+		{"(let ((λ 2) (%x 3)) (* λ %x))", "6"},
+		{"(list +1 -2 '1- '-x)", "(1 -2 1- -x)"},
+		{"(setq |if| 1) |if|", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(eq 'foo 'foo)", "T"},
+		{"(eq 'foo 'FOO)", "T"},
+		{"(list 'foo 'Foo)", "(foo foo)"},
+		{"(list 'Foo 'foo)", "(foo foo)"},
+		{"(eq '|FOO| 'foo)", "nil"},
+		{"(eq '|foo| 'FOO)", "T"},
+		{"(eq (intern \"FOO\") 'foo)", "nil"},
+		{"(eq (intern \"FOO\") '|FOO|)", "T"},
+		{"(symbol-name 'Foo)", `"foo"`},
+		{"(symbol-name '|Foo|)", `"Foo"`},
+		{"(eq 'foo 'bar)", "nil"},
+		{"(eq 'foo (car '(foo)))", "T"},
+		{"(eq (intern \"foo\") 'foo)", "T"},
+		{"(intern \"foo\")", "foo"},
+		{"(intern 'foo)", "argument to `intern` must be STRING, got SYMBOL"},
+		{"(make-symbol \"foo\")", "#:foo"},
+		{"(eq (make-symbol \"foo\") 'foo)", "nil"},
+		{"(eq (make-symbol \"foo\") (make-symbol \"foo\"))", "nil"},
+		{"(let ((s (make-symbol \"foo\"))) (eq s s))", "T"},
+		{"(list (gensym) (gensym \"tmp\") (gensym))", "(#:g0 #:tmp1 #:g2)"},
+		{"(gensym \"TMP\")", "#:|TMP0|"},
+		{"(eq (gensym) (gensym))", "nil"},
+		{"(symbolp (gensym))", "T"},
+		{"(symbol-name 'foo)", `"foo"`},
+		{"(symbol-name :foo)", `"foo"`},
+		{"(intern \":a\")", "|:a|"},
+		{"'foo\\ bar", "|foo bar|"},
+		{"'|a\\|b|", "|a\\|b|"},
+		{"(intern \"FOO\")", "|FOO|"},
+		{"(intern \"123\")", "|123|"},
+		{"(intern \"\")", "||"},
+		{"(intern \"nil\")", "|nil|"},
+		{"(list 'if 'foo-bar '1+)", "(if foo-bar 1+)"},
+		{"(make-symbol \"a b\")", "#:|a b|"},
+		{"(symbol-name :|a b|)", `"a b"`},
+		{":|a b|", ":|a b|"},
+		{"(eq :|foo| :foo)", "T"},
+		{"(list 1.5d0 1.5f0)", "(1.5 1.5)"},
+		{"(eq (intern \":a\") :a)", "nil"},
+		{"(keywordp (intern \":a\"))", "nil"},
+		{"(eq :foo :FOO)", "T"},
+		{"(eq :foo 'foo)", "nil"},
+		{"(fboundp :car)", "nil"},
+		{"(symbol-name (gensym))", `"g0"`},
+		{"(symbol-name nil)", `"nil"`},
+		{"(symbol-name 1)", "argument to `symbol-name` must be SYMBOL, got INTEGER"},
+		{"(setq x 10) (symbol-value 'x)", "10"},
+		{"(symbol-value :foo)", ":foo"},
+		{"(symbol-value t)", "T"},
+		{"(symbol-value 'unbound)", "symbol not found: unbound"},
+		{"(setq x 10) (boundp 'x)", "T"},
+		{"(boundp 'unbound)", "nil"},
+		{"(boundp :foo)", "T"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":test", ":test"},
+		{"(list :a 'b :c)", "(:a b :c)"},
+		{"(eq :a :a)", "T"},
+		{"(eq :a ':a)", "T"},
+		{"(eq :a :b)", "nil"},
+		{"(eq :a 'a)", "nil"},
+		{"(let ((k :key)) (eq k :key))", "T"},
+		{"(keywordp :a)", "T"},
+		{"(keywordp 'a)", "nil"},
+		{"(keywordp \":a\")", "nil"},
+		{"(symbolp :a)", "T"},
+		{"(symbolp 'a)", "T"},
+		{"(symbolp nil)", "T"},
+		{"(symbolp 1)", "nil"},
+		{"(setq :a 1)", "keyword :a cannot be assigned"},
+		{"(let ((:a 1)) :a)", "keyword :a cannot be bound"},
+		{"(defun f (:a) :a)", "keyword :a cannot be a parameter"},
+		{"(defun f (&key a) a) (f :a 1)", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package evaluator
+
+import (
+	"sync/atomic"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+var interrupted atomic.Bool
+
+// Interrupt makes the running evaluation stop with an error at the next form.
+// it is safe to call from a signal handler goroutine.
+func Interrupt() {
+	interrupted.Store(true)
+}
+
+// ClearInterrupt discards the interrupt which arrived while nothing was evaluated
+func ClearInterrupt() {
+	interrupted.Store(false)
+}
+
+// CheckInterrupt returns the error if Interrupt has been called since the last check
+func CheckInterrupt() *object.Error {
+	if interrupted.Load() && interrupted.CompareAndSwap(true, false) {
+		return newError("interrupted")
+	}
+	return nil
+}
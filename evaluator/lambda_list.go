@@ -0,0 +1,418 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// lambdaListState is the section of the lambda list which is being parsed
+type lambdaListState int
+
+const (
+	requiredState lambdaListState = iota
+	optionalState
+	restState
+	keyState
+	allowOtherKeysState
+	auxState
+)
+
+var lambdaListKeywords = map[string]lambdaListState{
+	"&optional":         optionalState,
+	"&rest":             restState,
+	"&body":             restState,
+	"&key":              keyState,
+	"&allow-other-keys": allowOtherKeysState,
+	"&aux":              auxState,
+}
+
+// parseLambdaList parses the ordinary lambda list of lambda and defun.
+// if isMacro is true, &body, destructuring patterns and dotted rest parameters are also allowed.
+func parseLambdaList(sexp ast.SExpression, isMacro bool) (*object.LambdaList, error) {
+	lambdaList := &object.LambdaList{}
+	state := requiredState
+
+	for {
+		var element ast.SExpression
+		switch list := sexp.(type) {
+		case *ast.Nil:
+			if state == restState && lambdaList.Rest == nil {
+				return nil, fmt.Errorf("%s must be followed by a parameter", lambdaList.RestKeyword)
+			}
+			return lambdaList, nil
+		case *ast.Symbol:
+			// (a b . rest) is the same as (a b &rest rest) in macro lambda lists
+			if !isMacro || state > restState || lambdaList.Rest != nil {
+				return nil, fmt.Errorf("parameters must be a list, got %T", sexp)
+			}
+			lambdaList.Rest = &object.Parameter{Symbol: list}
+			lambdaList.RestKeyword = "&rest"
+			return lambdaList, nil
+		case *ast.ConsCell:
+			element = list.Car()
+			sexp = list.Cdr()
+		default:
+			return nil, fmt.Errorf("parameters must be a list, got %T", sexp)
+		}
+
+		if symbol, ok := element.(*ast.Symbol); ok && strings.HasPrefix(symbol.Value, "&") {
+			next, ok := lambdaListKeywords[strings.ToLower(symbol.Value)]
+			if !ok {
+				return nil, fmt.Errorf("unknown lambda list keyword: %s", symbol.Value)
+			}
+			if strings.EqualFold(symbol.Value, "&body") && !isMacro {
+				return nil, fmt.Errorf("&body is only allowed in macro lambda lists")
+			}
+			if next <= state || (next == allowOtherKeysState && state != keyState) {
+				return nil, fmt.Errorf("misplaced lambda list keyword: %s", symbol.Value)
+			}
+
+			state = next
+			switch state {
+			case restState:
+				lambdaList.RestKeyword = strings.ToLower(symbol.Value)
+			case keyState:
+				lambdaList.HasKeys = true
+			case allowOtherKeysState:
+				lambdaList.AllowOtherKeys = true
+			}
+			continue
+		}
+
+		if symbol, ok := element.(*ast.Symbol); ok && symbol.IsKeyword() {
+			return nil, fmt.Errorf("keyword %s cannot be a parameter", symbol.Value)
+		}
+
+		switch state {
+		case requiredState:
+			param, err := parseRequiredParameter(element, isMacro)
+			if err != nil {
+				return nil, err
+			}
+			lambdaList.Parameters = append(lambdaList.Parameters, param)
+		case optionalState:
+			param, err := parseParameterWithDefault(element, true)
+			if err != nil {
+				return nil, err
+			}
+			lambdaList.Optional = append(lambdaList.Optional, param)
+		case restState:
+			if lambdaList.Rest != nil {
+				return nil, fmt.Errorf("%s must be followed by only one parameter", lambdaList.RestKeyword)
+			}
+			param, err := parseRequiredParameter(element, isMacro)
+			if err != nil {
+				return nil, err
+			}
+			lambdaList.Rest = param
+		case keyState:
+			param, err := parseKeyParameter(element)
+			if err != nil {
+				return nil, err
+			}
+			lambdaList.Keys = append(lambdaList.Keys, param)
+		case allowOtherKeysState:
+			return nil, fmt.Errorf("&allow-other-keys must be followed by &aux or nothing, got %s", element.String())
+		case auxState:
+			param, err := parseParameterWithDefault(element, false)
+			if err != nil {
+				return nil, err
+			}
+			lambdaList.Aux = append(lambdaList.Aux, param)
+		}
+	}
+}
+
+// parseRequiredParameter parses a symbol, or a destructuring pattern in macro lambda lists
+func parseRequiredParameter(sexp ast.SExpression, isMacro bool) (*object.Parameter, error) {
+	if symbol, ok := ast.AsSymbol(sexp); ok {
+		return &object.Parameter{Symbol: symbol}, nil
+	}
+
+	switch sexp := sexp.(type) {
+	case *ast.ConsCell, *ast.Nil:
+		if !isMacro {
+			return nil, fmt.Errorf("parameter must be a symbol, got %s", sexp.String())
+		}
+		pattern, err := parseLambdaList(sexp, true)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Parameter{Pattern: pattern}, nil
+	default:
+		return nil, fmt.Errorf("parameter must be a symbol, got %s", sexp.String())
+	}
+}
+
+// parseParameterWithDefault parses var or (var [init-form [supplied-p]])
+func parseParameterWithDefault(sexp ast.SExpression, allowSuppliedP bool) (*object.Parameter, error) {
+	if symbol, ok := ast.AsSymbol(sexp); ok {
+		return &object.Parameter{Symbol: symbol}, nil
+	}
+
+	elements, ok := astListToSlice(sexp)
+	if !ok || len(elements) == 0 {
+		return nil, fmt.Errorf("parameter must be a symbol or a list, got %s", sexp.String())
+	}
+
+	symbol, ok := ast.AsSymbol(elements[0])
+	if !ok {
+		return nil, fmt.Errorf("parameter must be a symbol, got %s", elements[0].String())
+	}
+
+	return newParameterWithDefault(symbol, elements[1:], allowSuppliedP)
+}
+
+// parseKeyParameter parses var, (var [init-form [supplied-p]]) or ((keyword var) [init-form [supplied-p]])
+func parseKeyParameter(sexp ast.SExpression) (*object.Parameter, error) {
+	if symbol, ok := ast.AsSymbol(sexp); ok {
+		return &object.Parameter{Symbol: symbol, Keyword: symbol.Value}, nil
+	}
+
+	elements, ok := astListToSlice(sexp)
+	if !ok || len(elements) == 0 {
+		return nil, fmt.Errorf("parameter must be a symbol or a list, got %s", sexp.String())
+	}
+
+	if symbol, ok := ast.AsSymbol(elements[0]); ok {
+		param, err := newParameterWithDefault(symbol, elements[1:], true)
+		if err != nil {
+			return nil, err
+		}
+		param.Keyword = symbol.Value
+		return param, nil
+	}
+
+	names, ok := astListToSlice(elements[0])
+	if !ok || len(names) != 2 {
+		return nil, fmt.Errorf("keyword parameter must be (keyword var), got %s", elements[0].String())
+	}
+	keyword, ok := names[0].(*ast.Symbol)
+	if !ok || !keyword.IsKeyword() {
+		return nil, fmt.Errorf("keyword parameter must be (keyword var), got %s", elements[0].String())
+	}
+	symbol, ok := ast.AsSymbol(names[1])
+	if !ok {
+		return nil, fmt.Errorf("parameter must be a symbol, got %s", names[1].String())
+	}
+
+	param, err := newParameterWithDefault(symbol, elements[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	param.Keyword = strings.TrimPrefix(keyword.Value, ":")
+	return param, nil
+}
+
+func newParameterWithDefault(symbol *ast.Symbol, rest []ast.SExpression, allowSuppliedP bool) (*object.Parameter, error) {
+	param := &object.Parameter{Symbol: symbol}
+
+	maxLen := 1
+	if allowSuppliedP {
+		maxLen = 2
+	}
+	if len(rest) > maxLen {
+		return nil, fmt.Errorf("too many elements in parameter %s", symbol.Value)
+	}
+
+	if len(rest) > 0 {
+		param.Default = rest[0]
+	}
+	if len(rest) > 1 {
+		suppliedP, ok := ast.AsSymbol(rest[1])
+		if !ok {
+			return nil, fmt.Errorf("supplied-p parameter must be a symbol, got %s", rest[1].String())
+		}
+		param.SuppliedP = suppliedP
+	}
+
+	return param, nil
+}
+
+// astListToSlice returns the elements of the proper list in the source code
+func astListToSlice(sexp ast.SExpression) ([]ast.SExpression, bool) {
+	elements := []ast.SExpression{}
+
+	for {
+		switch list := sexp.(type) {
+		case *ast.Nil:
+			return elements, true
+		case *ast.ConsCell:
+			elements = append(elements, list.Car())
+			sexp = list.Cdr()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// bindLambdaList binds the arguments to the parameters in env.
+// default values are evaluated in env so that they can refer to the preceding parameters.
+func bindLambdaList(lambdaList *object.LambdaList, args []object.Object, env *object.Environment) object.Object {
+	if len(args) < len(lambdaList.Parameters) {
+		return newArityError(lambdaList, len(args))
+	}
+
+	for i, param := range lambdaList.Parameters {
+		if err := bindParameter(param, args[i], env); err != nil {
+			return err
+		}
+	}
+	rest := args[len(lambdaList.Parameters):]
+
+	for _, param := range lambdaList.Optional {
+		if len(rest) > 0 {
+			if err := bindSuppliedParameter(param, rest[0], env); err != nil {
+				return err
+			}
+			rest = rest[1:]
+			continue
+		}
+		if err := bindDefaultParameter(param, env); err != nil {
+			return err
+		}
+	}
+
+	if lambdaList.Rest != nil {
+		if err := bindParameter(lambdaList.Rest, sliceToList(rest), env); err != nil {
+			return err
+		}
+	}
+
+	if lambdaList.HasKeys {
+		if err := bindKeyParameters(lambdaList, rest, env); err != nil {
+			return err
+		}
+	} else if lambdaList.Rest == nil && len(rest) > 0 {
+		return newArityError(lambdaList, len(args))
+	}
+
+	for _, param := range lambdaList.Aux {
+		if err := bindDefaultParameter(param, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newArityError(lambdaList *object.LambdaList, got int) *object.Error {
+	required := len(lambdaList.Parameters)
+	if len(lambdaList.Optional) == 0 && lambdaList.Rest == nil && !lambdaList.HasKeys {
+		return newError("function expects %d arguments, but got %d", required, got)
+	}
+	if got < required {
+		return newError("function expects at least %d arguments, but got %d", required, got)
+	}
+	return newError("function expects at most %d arguments, but got %d", required+len(lambdaList.Optional), got)
+}
+
+func bindKeyParameters(lambdaList *object.LambdaList, args []object.Object, env *object.Environment) object.Object {
+	if len(args)%2 != 0 {
+		return newError("odd number of keyword arguments: %s", sliceToList(args).Inspect())
+	}
+
+	allowOtherKeys := lambdaList.AllowOtherKeys
+	for i := 0; i < len(args); i += 2 {
+		keyword, ok := args[i].(*object.Symbol)
+		if !ok || !keyword.IsKeyword() {
+			return newError("keyword argument must be KEYWORD, got %s", args[i].Inspect())
+		}
+		if strings.EqualFold(keyword.Name, "allow-other-keys") && isTruthy(args[i+1]) {
+			allowOtherKeys = true
+		}
+	}
+
+	for _, param := range lambdaList.Keys {
+		value, ok := findKeywordArgument(param.Keyword, args)
+		if ok {
+			if err := bindSuppliedParameter(param, value, env); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := bindDefaultParameter(param, env); err != nil {
+			return err
+		}
+	}
+
+	if allowOtherKeys {
+		return nil
+	}
+	for i := 0; i < len(args); i += 2 {
+		name := args[i].(*object.Symbol).Name
+		if strings.EqualFold(name, "allow-other-keys") {
+			continue
+		}
+		if !containsKeyParameter(lambdaList.Keys, name) {
+			return newError("unknown keyword argument: %s", args[i].Inspect())
+		}
+	}
+
+	return nil
+}
+
+// findKeywordArgument returns the value of the leftmost occurrence of the keyword
+func findKeywordArgument(keyword string, args []object.Object) (object.Object, bool) {
+	for i := 0; i < len(args); i += 2 {
+		name := args[i].(*object.Symbol).Name
+		if strings.EqualFold(name, keyword) {
+			return args[i+1], true
+		}
+	}
+	return nil, false
+}
+
+func containsKeyParameter(params []*object.Parameter, keyword string) bool {
+	for _, param := range params {
+		if strings.EqualFold(param.Keyword, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func bindSuppliedParameter(param *object.Parameter, value object.Object, env *object.Environment) object.Object {
+	if err := bindParameter(param, value, env); err != nil {
+		return err
+	}
+	if param.SuppliedP != nil {
+		env.Set(param.SuppliedP.Value, True)
+	}
+	return nil
+}
+
+func bindDefaultParameter(param *object.Parameter, env *object.Environment) object.Object {
+	var value object.Object = Nil
+	if param.Default != nil {
+		value = Eval(param.Default, env)
+		if isUnwinding(value) {
+			return value
+		}
+	}
+
+	if err := bindParameter(param, value, env); err != nil {
+		return err
+	}
+	if param.SuppliedP != nil {
+		env.Set(param.SuppliedP.Value, Nil)
+	}
+	return nil
+}
+
+// bindParameter binds the value to the variable, or destructures it by the pattern
+func bindParameter(param *object.Parameter, value object.Object, env *object.Environment) object.Object {
+	if param.Pattern == nil {
+		env.Set(param.Symbol.Value, value)
+		return nil
+	}
+
+	elements, ok := listToSlice(value)
+	if !ok {
+		return newError("cannot destructure %s by %s", value.Inspect(), param.Pattern.String())
+	}
+	return bindLambdaList(param.Pattern, elements, env)
+}
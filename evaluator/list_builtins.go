@@ -0,0 +1,201 @@
+package evaluator
+
+import (
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getListBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "car", "first":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.ConsCell:
+					return arg.Car
+				case *object.Nil:
+					return Nil
+				default:
+					return newConditionError("type-error", "argument to `%s` must be LIST, got %s", funcName, arg.Type())
+				}
+			},
+		}, true
+	case "cdr", "rest":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.ConsCell:
+					return arg.Cdr
+				case *object.Nil:
+					return Nil
+				default:
+					return newConditionError("type-error", "argument to `%s` must be LIST, got %s", funcName, arg.Type())
+				}
+			},
+		}, true
+	case "cons":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				return &object.ConsCell{Car: args[0], Cdr: args[1]}
+			},
+		}, true
+	case "list":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return sliceToList(args)
+			},
+		}, true
+	case "atom", "consp", "listp", "null", "not":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				_, isConsCell := args[0].(*object.ConsCell)
+				_, isNil := args[0].(*object.Nil)
+
+				var result bool
+				switch funcName {
+				case "atom":
+					result = !isConsCell
+				case "consp":
+					result = isConsCell
+				case "listp":
+					result = isConsCell || isNil
+				case "null", "not":
+					result = isNil
+				}
+
+				if result {
+					return True
+				}
+				return Nil
+			},
+		}, true
+	case "eq", "eql", "equal":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				var result bool
+				switch funcName {
+				case "eq":
+					result = isEq(args[0], args[1])
+				case "eql":
+					result = isEql(args[0], args[1])
+				case "equal":
+					result = isEqual(args[0], args[1])
+				}
+
+				if result {
+					return True
+				}
+				return Nil
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// listToSlice returns the elements of the proper list.
+// the second return value is false if obj is not a proper list.
+func listToSlice(obj object.Object) ([]object.Object, bool) {
+	elements := []object.Object{}
+
+	for {
+		switch list := obj.(type) {
+		case *object.Nil:
+			return elements, true
+		case *object.ConsCell:
+			elements = append(elements, list.Car)
+			obj = list.Cdr
+		default:
+			return nil, false
+		}
+	}
+}
+
+// sliceToList builds the proper list of the elements
+func sliceToList(elements []object.Object) object.Object {
+	var list object.Object = Nil
+	for i := len(elements) - 1; i >= 0; i-- {
+		list = &object.ConsCell{Car: elements[i], Cdr: list}
+	}
+	return list
+}
+
+// isEq reports whether the two objects are identical.
+// fixnums and characters with the same value are identical,
+// and symbols are identical only if they are the same object in the symbol table.
+func isEq(left, right object.Object) bool {
+	if left == right {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		r, ok := right.(*object.Integer)
+		return ok && left.Value == r.Value
+	case *object.Character:
+		r, ok := right.(*object.Character)
+		return ok && left.Value == r.Value
+	case *object.Nil:
+		_, ok := right.(*object.Nil)
+		return ok
+	case *object.True:
+		_, ok := right.(*object.True)
+		return ok
+	default:
+		return false
+	}
+}
+
+// isEql reports whether the two objects are eq or numbers of the same type and value
+func isEql(left, right object.Object) bool {
+	if isEq(left, right) {
+		return true
+	}
+
+	leftKind, ok := numberKindOf(left)
+	if !ok {
+		return false
+	}
+	rightKind, ok := numberKindOf(right)
+	if !ok || leftKind != rightKind {
+		return false
+	}
+
+	return compareNumbers(left, right) == 0
+}
+
+// isEqual reports whether the two objects are structurally similar
+func isEqual(left, right object.Object) bool {
+	if isEql(left, right) {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.ConsCell:
+		r, ok := right.(*object.ConsCell)
+		return ok && isEqual(left.Car, r.Car) && isEqual(left.Cdr, r.Cdr)
+	case *object.String:
+		r, ok := right.(*object.String)
+		return ok && left.Value == r.Value
+	default:
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+// loadPathVariable is the environment variable which lists the directories searched by require
+const loadPathVariable = "GOLISP_PATH"
+
+func getLoadBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "load":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				path, ok := args[0].(*object.String)
+				if !ok {
+					return newConditionError("type-error", "argument to `load` must be STRING, got %s", args[0].Type())
+				}
+
+				return LoadFile(path.Value, env)
+			},
+		}, true
+	case "require":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				module, err := moduleName("require", args[0])
+				if err != nil {
+					return err
+				}
+
+				// each module is loaded only once
+				if env.IsProvided(module) {
+					return Nil
+				}
+
+				var path string
+				if len(args) == 2 {
+					pathname, ok := args[1].(*object.String)
+					if !ok {
+						return newConditionError("type-error", "second argument to `require` must be STRING, got %s", args[1].Type())
+					}
+					path = pathname.Value
+				} else {
+					found, ok := findModule(module, env)
+					if !ok {
+						return newError("require: module %s is not found in %s", module, loadPathVariable)
+					}
+					path = found
+				}
+
+				if result := LoadFile(path, env); isUnwinding(result) {
+					return result
+				}
+				env.Provide(module)
+				return True
+			},
+		}, true
+	case "provide":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				module, err := moduleName("provide", args[0])
+				if err != nil {
+					return err
+				}
+
+				env.Provide(module)
+				return True
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// moduleName returns the name of the module designated by the string or the symbol.
+// the symbol names are lowercased because the symbols are case-insensitive.
+func moduleName(funcName string, designator object.Object) (string, *object.Error) {
+	switch designator := designator.(type) {
+	case *object.String:
+		return designator.Value, nil
+	case *object.Symbol:
+		return strings.ToLower(designator.Name), nil
+	default:
+		return "", newConditionError("type-error", "argument to `%s` must be STRING or SYMBOL, got %s", funcName, designator.Type())
+	}
+}
+
+// findModule searches the directory of the file being loaded, the directories in GOLISP_PATH
+// and the current directory in order for module.lisp or module
+func findModule(module string, env *object.Environment) (string, bool) {
+	dirs := []string{}
+	if loading := env.Loading(); len(loading) > 0 {
+		dirs = append(dirs, filepath.Dir(loading[len(loading)-1]))
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv(loadPathVariable))...)
+	dirs = append(dirs, ".")
+
+	for _, dir := range dirs {
+		for _, name := range []string{module + ".lisp", module} {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// LoadFile reads and parses the file, and macro-expands and evaluates its forms one by one in the global environment.
+// the errors are reported with the path of the file, either in their position or in their message.
+func LoadFile(path string, env *object.Environment) object.Object {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return newError("load: %s", err)
+	}
+
+	loading := env.Loading()
+	for i, loadingPath := range loading {
+		if loadingPath == absPath {
+			chain := append(append([]string{}, loading[i:]...), absPath)
+			return newError("circular load: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("load: %s", err)
+	}
+
+	l := lexer.NewFile(path, string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		// the parser errors already begin with the path
+		messages := make([]string, len(p.Errors()))
+		for i, err := range p.Errors() {
+			messages[i] = err.Error()
+		}
+		return newError("%s", strings.Join(messages, "\n"))
+	}
+
+	env.PushLoading(absPath)
+	defer env.PopLoading()
+
+	// each form is expanded just before it is evaluated,
+	// so that it can use the macros and the functions defined by the forms before it
+	macroEnv := env.MacroEnvironment()
+	globalEnv := env.Global()
+	for _, form := range program.Expressions {
+		expanded, expandErr := ExpandMacros(form, macroEnv)
+		if expandErr != nil {
+			return expandErr
+		}
+
+		// the non-local exits are propagated to the caller of load unlike the top level of a program
+		result := Eval(expanded, globalEnv)
+		if err, ok := result.(*object.Error); ok {
+			if err.Pos.IsValid() {
+				return err
+			}
+			return &object.Error{Message: path + ": " + err.Message, Condition: err.Condition, Signaled: err.Signaled}
+		}
+		if isUnwinding(result) {
+			return result
+		}
+	}
+
+	return True
+}
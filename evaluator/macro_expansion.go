@@ -1,235 +1,454 @@
-package evaluator
-
-import (
-	"github.com/JunNishimura/go-lisp/ast"
-	"github.com/JunNishimura/go-lisp/object"
-)
-
-var macroNames = []string{}
-
-func DefineMacros(program *ast.Program, env *object.Environment) {
-	definitions := []int{}
-
-	for i, exp := range program.Expressions {
-		if isMacroDefinition(exp) {
-			addMacro(exp, env)
-			definitions = append(definitions, i)
-		}
-	}
-
-	for i := len(definitions) - 1; i >= 0; i-- {
-		definitionIndex := definitions[i]
-		program.Expressions = append(program.Expressions[:definitionIndex], program.Expressions[definitionIndex+1:]...)
-	}
-}
-
-func isMacroDefinition(sexp ast.SExpression) bool {
-	consCell, ok := sexp.(*ast.ConsCell)
-	if !ok {
-		return false
-	}
-
-	car, ok := consCell.Car().(*ast.Symbol)
-	if !ok {
-		return false
-	}
-
-	return car.Value == "defmacro"
-}
-
-func addMacro(sexp ast.SExpression, env *object.Environment) {
-	macroName, ok := getMacroName(sexp)
-	if !ok {
-		return
-	}
-
-	params, ok := getMacroParams(sexp)
-	if !ok {
-		return
-	}
-
-	body, ok := getMacroBody(sexp)
-	if !ok {
-		return
-	}
-
-	macro := &object.Macro{
-		Parameters: params,
-		Body:       body,
-		Env:        env,
-	}
-
-	macroNames = append(macroNames, macroName)
-	env.Set(macroName, macro)
-}
-
-func getMacroName(sexp ast.SExpression) (string, bool) {
-	consCell, ok := sexp.(*ast.ConsCell)
-	if !ok {
-		return "", false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return "", false
-	}
-
-	symbol, ok := consCell.Car().(*ast.Symbol)
-	if !ok {
-		return "", false
-	}
-
-	return symbol.Value, true
-}
-
-func getMacroParams(sexp ast.SExpression) ([]*ast.Symbol, bool) {
-	consCell, ok := sexp.(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	paramConsCell, ok := consCell.Car().(*ast.ConsCell)
-	if !ok {
-		if _, ok := consCell.Car().(*ast.Nil); ok {
-			// No parameters
-			return []*ast.Symbol{}, true
-		}
-		return nil, false
-	}
-
-	params := []*ast.Symbol{}
-	for {
-		symbol, ok := paramConsCell.Car().(*ast.Symbol)
-		if !ok {
-			return nil, false
-		}
-
-		params = append(params, symbol)
-
-		if _, ok := paramConsCell.Cdr().(*ast.Nil); ok {
-			break
-		}
-
-		paramConsCell, ok = paramConsCell.Cdr().(*ast.ConsCell)
-		if !ok {
-			return nil, false
-		}
-	}
-
-	return params, true
-}
-
-func getMacroBody(sexp ast.SExpression) (ast.SExpression, bool) {
-	consCell, ok := sexp.(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	consCell, ok = consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return nil, false
-	}
-
-	return consCell.Car(), true
-}
-
-func ExpandMacros(program ast.SExpression, env *object.Environment) ast.SExpression {
-	return ast.ModifyByMacro(program, func(sexp ast.SExpression) ast.SExpression {
-		consCell, ok := sexp.(*ast.ConsCell)
-		if !ok {
-			return sexp
-		}
-
-		macro, ok := isMacroCall(consCell, env)
-		if !ok {
-			return sexp
-		}
-
-		args := quoteArgs(consCell)
-
-		evalEnv := extendMacroEnv(macro, args)
-
-		evaluated := Eval(macro.Body, evalEnv)
-
-		quote, ok := evaluated.(*object.Quote)
-		if !ok {
-			panic("we only support returning AST-nodes from macros")
-		}
-
-		return quote.SExpression
-	}, macroNames)
-}
-
-func isMacroCall(consCell *ast.ConsCell, env *object.Environment) (*object.Macro, bool) {
-	symbol, ok := consCell.Car().(*ast.Symbol)
-
-	if !ok {
-		return nil, false
-	}
-
-	obj, ok := env.Get(symbol.Value)
-	if !ok {
-		return nil, false
-	}
-
-	macro, ok := obj.(*object.Macro)
-	if !ok {
-		return nil, false
-	}
-
-	return macro, true
-}
-
-func quoteArgs(consCell *ast.ConsCell) []*object.Quote {
-	args := []*object.Quote{}
-
-	consCell, ok := consCell.Cdr().(*ast.ConsCell)
-	if !ok {
-		return args
-	}
-
-	for {
-		args = append(args, &object.Quote{SExpression: consCell.Car()})
-
-		if _, ok := consCell.Cdr().(*ast.Nil); ok {
-			break
-		}
-
-		consCell, ok = consCell.Cdr().(*ast.ConsCell)
-		if !ok {
-			return args
-		}
-	}
-
-	return args
-}
-
-func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
-	env := object.NewEnclosedEnvironment(macro.Env)
-
-	for i, param := range macro.Parameters {
-		env.Set(param.Value, args[i])
-	}
-
-	return env
-}
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// ExpandMacros defines the macros of the top-level defmacro forms and expands the macro calls in the program.
+// the forms are processed in order, so the calls after a macro is redefined use the new definition.
+// the defmacro forms are removed from the program.
+// the error is positioned at the malformed definition or the macro call which failed to expand.
+func ExpandMacros(program ast.SExpression, env *object.Environment) (ast.SExpression, *object.Error) {
+	prog, ok := program.(*ast.Program)
+	if !ok {
+		return expandMacroCalls(program, env)
+	}
+
+	expressions := []ast.SExpression{}
+	for _, exp := range prog.Expressions {
+		if isMacroDefinition(exp) {
+			if err := addMacro(exp, env); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		expanded, err := expandMacroCalls(exp, env)
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, expanded)
+	}
+	prog.Expressions = expressions
+
+	return prog, nil
+}
+
+func isMacroDefinition(sexp ast.SExpression) bool {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return false
+	}
+
+	car, ok := consCell.Car().(*ast.Symbol)
+	if !ok {
+		return false
+	}
+
+	return car.Value == "defmacro"
+}
+
+func addMacro(sexp ast.SExpression, env *object.Environment) *object.Error {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return newMacroError(sexp, "malformed defmacro: %s", sexp.String())
+	}
+
+	return defineMacro(consCell.Cdr(), consCell, env)
+}
+
+// defineMacro defines the macro from the list of its name, lambda list and body.
+// the error is positioned at form, which is the defmacro form or the macrolet definition.
+func defineMacro(definition ast.SExpression, form ast.SExpression, env *object.Environment) *object.Error {
+	elements, ok := astListToSlice(definition)
+	if !ok || len(elements) < 2 {
+		return newMacroError(form, "macro definition needs name and lambda list, got %s", form.String())
+	}
+
+	macroName, ok := elements[0].(*ast.Symbol)
+	if !ok || macroName.IsKeyword() {
+		return newMacroError(form, "macro name must be SYMBOL, got %s", elements[0].String())
+	}
+
+	params, err := parseLambdaList(elements[1], true)
+	if err != nil {
+		return newMacroError(form, "%s", err.Error())
+	}
+
+	env.SetMacro(macroName.Value, &object.Macro{
+		LambdaList: params,
+		Body:       elements[2:],
+		Env:        env,
+	})
+	return nil
+}
+
+// newMacroError returns the error which points to the form
+func newMacroError(form ast.SExpression, format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Pos = form.Span().Start
+	return err
+}
+
+// expandMacroCalls expands the macro calls in sexp with the macros defined in env.
+// the result of a macro call is expanded again until no macro calls remain.
+// the quoted data and the parts of the special forms which are not evaluated are left as they are.
+func expandMacroCalls(sexp ast.SExpression, env *object.Environment) (ast.SExpression, *object.Error) {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return sexp, nil
+	}
+
+	switch car := consCell.Car().(type) {
+	case *ast.SpecialForm:
+		if err := expandSpecialForm(car.Value, consCell, env); err != nil {
+			return nil, err
+		}
+		return consCell, nil
+	case *ast.Symbol:
+		switch {
+		case isMacroDefinition(consCell):
+			// the macro is available to the forms after the definition, which evaluates to its name
+			if err := addMacro(consCell, env); err != nil {
+				return nil, err
+			}
+			return quoteMacroName(consCell), nil
+		case isMacrolet(consCell):
+			return expandMacrolet(consCell, env)
+		}
+
+		expanded, ok, err := expandMacroCall(consCell, env)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return expandExpansion(expanded, consCell, env)
+		}
+	case *ast.ConsCell:
+		// the lambda expression in the function position
+		expanded, err := expandMacroCalls(car, env)
+		if err != nil {
+			return nil, err
+		}
+		consCell.CarField = expanded
+	}
+
+	if err := expandForms(consCell.Cdr(), env); err != nil {
+		return nil, err
+	}
+	return consCell, nil
+}
+
+// maxExpansionDepth limits the nesting of the expansions of the macro calls in the results of the other macro calls,
+// so that the macro which keeps expanding into its own call is reported instead of running forever.
+const maxExpansionDepth = 10000
+
+// expandExpansion expands the macro calls in the result of the macro call form
+func expandExpansion(expanded ast.SExpression, form *ast.ConsCell, env *object.Environment) (ast.SExpression, *object.Error) {
+	if err := CheckInterrupt(); err != nil {
+		err.Pos = form.Span().Start
+		return nil, err
+	}
+	defer env.LeaveExpansion()
+	if env.EnterExpansion() > maxExpansionDepth {
+		return nil, newMacroError(form, "macro %s: expansion is nested too deeply", form.Car().String())
+	}
+
+	return expandMacroCalls(expanded, env)
+}
+
+// expandForms expands the macro calls in the elements of the list of forms
+func expandForms(forms ast.SExpression, env *object.Environment) *object.Error {
+	for consCell, ok := forms.(*ast.ConsCell); ok; consCell, ok = consCell.Cdr().(*ast.ConsCell) {
+		expanded, err := expandMacroCalls(consCell.CarField, env)
+		if err != nil {
+			return err
+		}
+		consCell.CarField = expanded
+	}
+	return nil
+}
+
+// expandSpecialForm expands the macro calls in the parts of the special form which are evaluated
+func expandSpecialForm(name string, consCell *ast.ConsCell, env *object.Environment) *object.Error {
+	args, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return nil
+	}
+
+	switch name {
+	case "quote", "go", "define-condition":
+		// nothing is evaluated
+		return nil
+	case "backquote":
+		return expandUnquotes(args.Car(), 1, env)
+	case "function":
+		if lambda, ok := args.Car().(*ast.ConsCell); ok && isLambdaExpression(lambda) {
+			return expandSpecialForm("lambda", lambda, env)
+		}
+		return nil
+	case "lambda", "block", "return-from":
+		// (lambda lambda-list . body) and (block name . body)
+		return expandForms(args.Cdr(), env)
+	case "defun":
+		// (defun name lambda-list . body)
+		if rest, ok := args.Cdr().(*ast.ConsCell); ok {
+			return expandForms(rest.Cdr(), env)
+		}
+		return nil
+	case "let", "let*", "handler-bind":
+		// the second elements of the bindings are evaluated
+		for bindings, ok := args.Car().(*ast.ConsCell); ok; bindings, ok = bindings.Cdr().(*ast.ConsCell) {
+			if binding, ok := bindings.Car().(*ast.ConsCell); ok {
+				if err := expandForms(binding.Cdr(), env); err != nil {
+					return err
+				}
+			}
+		}
+		return expandForms(args.Cdr(), env)
+	case "flet", "labels":
+		// the definitions are (name lambda-list . body).
+		// the local functions shadow the macros of the same name in the body, and also in the definitions of labels.
+		functionEnv := object.NewEnclosedEnvironment(env)
+		definitions, _ := astListToSlice(args.Car())
+		for _, definition := range definitions {
+			if definition, ok := definition.(*ast.ConsCell); ok {
+				if name, ok := definition.Car().(*ast.Symbol); ok {
+					functionEnv.SetMacro(name.Value, nil)
+				}
+			}
+		}
+		definitionEnv := env
+		if name == "labels" {
+			definitionEnv = functionEnv
+		}
+		for _, definition := range definitions {
+			if err := expandClauseBody(definition, definitionEnv); err != nil {
+				return err
+			}
+		}
+		return expandForms(args.Cdr(), functionEnv)
+	case "cond":
+		// the clauses are (test . body)
+		for clauses, ok := args, true; ok; clauses, ok = clauses.Cdr().(*ast.ConsCell) {
+			if err := expandForms(clauses.Car(), env); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "case", "ecase", "typecase", "etypecase":
+		// the clauses are (keys . body) or (type . body)
+		expanded, err := expandMacroCalls(args.CarField, env)
+		if err != nil {
+			return err
+		}
+		args.CarField = expanded
+		for clauses, ok := args.Cdr().(*ast.ConsCell); ok; clauses, ok = clauses.Cdr().(*ast.ConsCell) {
+			if clause, ok := clauses.Car().(*ast.ConsCell); ok {
+				if err := expandForms(clause.Cdr(), env); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "handler-case", "restart-case":
+		// the clauses are (type ([var]) . body) or (name lambda-list . body)
+		expanded, err := expandMacroCalls(args.CarField, env)
+		if err != nil {
+			return err
+		}
+		args.CarField = expanded
+		for clauses, ok := args.Cdr().(*ast.ConsCell); ok; clauses, ok = clauses.Cdr().(*ast.ConsCell) {
+			if err := expandClauseBody(clauses.Car(), env); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return expandForms(args, env)
+	}
+}
+
+// expandClauseBody expands the body of the clause, which follows the name and the lambda list
+func expandClauseBody(clause ast.SExpression, env *object.Environment) *object.Error {
+	consCell, ok := clause.(*ast.ConsCell)
+	if !ok {
+		return nil
+	}
+	rest, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return nil
+	}
+
+	return expandForms(rest.Cdr(), env)
+}
+
+// expandUnquotes expands the macro calls in the forms in the backquoted data which are evaluated,
+// that is, the unquoted forms at depth 1
+func expandUnquotes(sexp ast.SExpression, depth int, env *object.Environment) *object.Error {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return nil
+	}
+
+	switch quasiquoteFormName(consCell) {
+	case "backquote":
+		return expandUnquotes(consCell.Cdr(), depth+1, env)
+	case "unquote", "unquote-splicing":
+		if depth == 1 {
+			return expandForms(consCell.Cdr(), env)
+		}
+		return expandUnquotes(consCell.Cdr(), depth-1, env)
+	}
+
+	if err := expandUnquotes(consCell.Car(), depth, env); err != nil {
+		return err
+	}
+	return expandUnquotes(consCell.Cdr(), depth, env)
+}
+
+// quoteMacroName returns the form which evaluates to the name of the macro defined by the defmacro form
+func quoteMacroName(consCell *ast.ConsCell) ast.SExpression {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return convertSymbolToSExpression("nil")
+	}
+
+	return &ast.ConsCell{
+		CarField: convertSymbolToSExpression("quote"),
+		CdrField: &ast.ConsCell{
+			CarField:  cdr.Car(),
+			CdrField:  convertSymbolToSExpression("nil"),
+			SpanField: consCell.Span(),
+		},
+		SpanField: consCell.Span(),
+	}
+}
+
+func isMacrolet(consCell *ast.ConsCell) bool {
+	symbol, ok := consCell.Car().(*ast.Symbol)
+	return ok && strings.EqualFold(symbol.Value, "macrolet")
+}
+
+// expandMacrolet defines the local macros in the new environment and expands the body with them.
+// the macrolet form is replaced with the progn form of the expanded body.
+func expandMacrolet(consCell *ast.ConsCell, env *object.Environment) (ast.SExpression, *object.Error) {
+	cdr, ok := consCell.Cdr().(*ast.ConsCell)
+	if !ok {
+		return nil, newMacroError(consCell, "macrolet needs the list of the macro definitions, got %s", consCell.String())
+	}
+
+	definitions, ok := astListToSlice(cdr.Car())
+	if !ok {
+		return nil, newMacroError(consCell, "macro definitions must be LIST, got %s", cdr.Car().String())
+	}
+
+	macroletEnv := object.NewEnclosedEnvironment(env)
+	for _, definition := range definitions {
+		if err := defineMacro(definition, definition, macroletEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expandForms(cdr.Cdr(), macroletEnv); err != nil {
+		return nil, err
+	}
+
+	return &ast.ConsCell{
+		CarField:  convertSymbolToSExpression("progn"),
+		CdrField:  cdr.Cdr(),
+		SpanField: consCell.Span(),
+	}, nil
+}
+
+// expandMacroCall expands the macro call once.
+// the second return value is false if consCell is not a macro call.
+func expandMacroCall(consCell *ast.ConsCell, env *object.Environment) (ast.SExpression, bool, *object.Error) {
+	macro, ok := isMacroCall(consCell, env)
+	if !ok {
+		return consCell, false, nil
+	}
+	name := consCell.Car().String()
+
+	args, ok := quoteArgs(consCell, env)
+	if !ok {
+		return nil, false, newMacroError(consCell, "arguments to macro %s must be a proper list, got %s", name, consCell.Cdr().String())
+	}
+
+	evalEnv, err := extendMacroEnv(macro, args)
+	if errObj, ok := err.(*object.Error); ok && !errObj.Pos.IsValid() {
+		// the arguments do not match the lambda list
+		errObj.Message = fmt.Sprintf("macro %s: %s", name, errObj.Message)
+	}
+	if err != nil {
+		return nil, false, macroCallError(err, name, consCell)
+	}
+
+	evaluated := evalBody(macro.Body, evalEnv)
+	if isUnwinding(evaluated) {
+		return nil, false, macroCallError(evaluated, name, consCell)
+	}
+
+	expanded := convertObjectToSExpression(evaluated)
+	ast.FillSpan(expanded, consCell.Span())
+
+	return expanded, true, nil
+}
+
+// macroCallError converts the error or the non-local exit which aborted the expansion into the error.
+// the error without its position points to the macro call.
+func macroCallError(obj object.Object, name string, consCell *ast.ConsCell) *object.Error {
+	var err *object.Error
+	switch obj := obj.(type) {
+	case *object.Error:
+		err = obj
+	case *object.NonLocalExit:
+		err = newExitError(obj)
+	default:
+		err = newError("macro %s failed to expand: %s", name, obj.Inspect())
+	}
+
+	if !err.Pos.IsValid() {
+		err.Pos = consCell.Span().Start
+	}
+	return err
+}
+
+func isMacroCall(consCell *ast.ConsCell, env *object.Environment) (*object.Macro, bool) {
+	symbol, ok := consCell.Car().(*ast.Symbol)
+
+	if !ok {
+		return nil, false
+	}
+
+	return env.GetMacro(symbol.Value)
+}
+
+// quoteArgs converts the arguments of the macro call into data without evaluating them.
+// the second return value is false if the arguments are not a proper list.
+func quoteArgs(consCell *ast.ConsCell, env *object.Environment) ([]object.Object, bool) {
+	elements, ok := astListToSlice(consCell.Cdr())
+	if !ok {
+		return nil, false
+	}
+
+	args := make([]object.Object, len(elements))
+	for i, element := range elements {
+		args[i] = convertSExpressionToObject(element, env)
+	}
+
+	return args, true
+}
+
+func extendMacroEnv(macro *object.Macro, args []object.Object) (*object.Environment, object.Object) {
+	env := object.NewEnclosedEnvironment(macro.Env)
+
+	if err := bindLambdaList(macro.LambdaList, args, env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
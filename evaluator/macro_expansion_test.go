@@ -1,93 +1,368 @@
-package evaluator
-
-import (
-	"testing"
-
-	"github.com/JunNishimura/go-lisp/ast"
-	"github.com/JunNishimura/go-lisp/lexer"
-	"github.com/JunNishimura/go-lisp/object"
-	"github.com/JunNishimura/go-lisp/parser"
-)
-
-func TestDefineMacros(t *testing.T) {
-	input := "(defmacro myMacro (x y) '(+ x y))"
-
-	env := object.NewEnvironment()
-	program := testParseProgram(input)
-
-	DefineMacros(program, env)
-
-	obj, ok := env.Get("myMacro")
-	if !ok {
-		t.Fatalf("macro not in environment")
-	}
-
-	macro, ok := obj.(*object.Macro)
-	if !ok {
-		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
-	}
-
-	if len(macro.Parameters) != 2 {
-		t.Fatalf("wrong number of identifiers. got=%d", len(macro.Parameters))
-	}
-
-	if macro.Parameters[0].String() != "x" {
-		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
-	}
-	if macro.Parameters[1].String() != "y" {
-		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
-	}
-
-	expectedBody := "(quote (+ x y))"
-	if macro.Body.String() != expectedBody {
-		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
-	}
-}
-
-func testParseProgram(input string) *ast.Program {
-	l := lexer.New(input)
-	p := parser.New(l)
-	return p.ParseProgram()
-}
-
-func TestExpandMacros(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name: "expands macro which has no arguments",
-			input: `
-				(defmacro hoge () '1)
-				(hoge)
-			`,
-			expected: "1",
-		},
-		{
-			name: "expands macro which has arguments",
-			input: `
-				(defmacro hoge (x y) ` + "`" + `(- ,y ,x))
-				(hoge (+ 2 2) (- 10 5))
-			`,
-			expected: "(- (- 10 5) (+ 2 2))",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			expected := testParseProgram(tt.expected)
-
-			program := testParseProgram(tt.input)
-			env := object.NewEnvironment()
-
-			DefineMacros(program, env)
-
-			expanded := ExpandMacros(program, env)
-
-			if expanded.String() != expected.String() {
-				t.Errorf("not equal. got=%q, want=%q", expanded.String(), expected.String())
-			}
-		})
-	}
-}
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := "(defmacro myMacro (x y) '(+ x y))"
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	expanded := testExpandMacros(t, program, env).(*ast.Program)
+	if len(expanded.Expressions) != 0 {
+		t.Fatalf("defmacro is not removed. got=%q", expanded.String())
+	}
+
+	macro, ok := env.GetMacro("myMacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of identifiers. got=%d", len(macro.Parameters))
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+
+	expectedBody := "(quote (+ x y))"
+	if len(macro.Body) != 1 {
+		t.Fatalf("wrong number of body forms. got=%d", len(macro.Body))
+	}
+	if macro.Body[0].String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body[0].String())
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testExpandMacros(t *testing.T, program ast.SExpression, env *object.Environment) ast.SExpression {
+	t.Helper()
+
+	expanded, err := ExpandMacros(program, env)
+	if err != nil {
+		t.Fatalf("failed to expand macros: %s", err.Report())
+	}
+	return expanded
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "expands macro which has no arguments",
+			input: `
+				(defmacro hoge () '1)
+				(hoge)
+			`,
+			expected: "1",
+		},
+		{
+			name: "expands macro which has arguments",
+			input: `
+				(defmacro hoge (x y) ` + "`" + `(- ,y ,x))
+				(hoge (+ 2 2) (- 10 5))
+			`,
+			expected: "(- (- 10 5) (+ 2 2))",
+		},
+		{
+			name: "expands macro which is called multiple times",
+			input: `
+				(defmacro hoge (x) ` + "`" + `(car ',x))
+				(hoge (1 2))
+				(hoge (3 4))
+			`,
+			expected: "(car '(1 2)) (car '(3 4))",
+		},
+		{
+			name: "expands macro which inspects its arguments as data",
+			input: `
+				(defmacro hoge (x) (car (cdr x)))
+				(hoge (1 (+ 2 3)))
+			`,
+			expected: "(+ 2 3)",
+		},
+		{
+			name: "expands macro which has &body parameter",
+			input: `
+				(defmacro hoge (x &body body) (cons 'if (cons x body)))
+				(hoge t 1 2)
+			`,
+			expected: "(if t 1 2)",
+		},
+		{
+			name: "expands macro which has multiple body forms",
+			input: `
+				(defmacro hoge (x) (setq y x) ` + "`" + `(+ ,y 1))
+				(hoge 2)
+			`,
+			expected: "(+ 2 1)",
+		},
+		{
+			name: "expands macro which has optional parameter",
+			input: `
+				(defmacro hoge (x &optional (y 10)) ` + "`" + `(+ ,x ,y))
+				(hoge 1)
+			`,
+			expected: "(+ 1 10)",
+		},
+		{
+			name: "expands macro which destructures its arguments",
+			input: `
+				(defmacro hoge ((var init) &rest body) ` + "`" + `((lambda (,var) ,(car body)) ,init))
+				(hoge (x 1) (+ x 1))
+			`,
+			expected: "((lambda (x) (+ x 1)) 1)",
+		},
+		{
+			name: "expands macro which has dotted parameter list",
+			input: `
+				(defmacro hoge (x . rest) (cons 'list (cons x rest)))
+				(hoge 1 2 3)
+			`,
+			expected: "(list 1 2 3)",
+		},
+		{
+			name: "expands macro which returns atoms",
+			input: `
+				(defmacro hoge () 1)
+				(defmacro fuga () 'x)
+				(defmacro piyo () "s")
+				(list (hoge) (fuga) (piyo))
+			`,
+			expected: `(list 1 x "s")`,
+		},
+		{
+			name: "expands macro which returns objects without representation in the source code",
+			input: `
+				(defmacro hoge () (list 'funcall #'car ''(1 2)))
+				(hoge)
+			`,
+			expected: "(funcall builtin function (quote (1 2)))",
+		},
+		{
+			name: "expands macro which makes variable by gensym",
+			input: `
+				(defmacro swap (a b) (let ((tmp (gensym))) ` + "`" + `(let ((,tmp ,a)) (setq ,a ,b) (setq ,b ,tmp))))
+				(swap x y)
+			`,
+			expected: "(let ((g0 x)) (setq x y) (setq y g0))",
+		},
+		{
+			name: "expands macro which compares its argument with quoted symbol",
+			input: `
+				(defmacro hoge (x) (if (eq x 'foo) 1 2))
+				(hoge foo)
+				(hoge bar)
+			`,
+			expected: "1 2",
+		},
+		{
+			name: "expands calls with the definition at the time",
+			input: `
+				(defmacro hoge () 1)
+				(hoge)
+				(defmacro hoge () 2)
+				(hoge)
+			`,
+			expected: "1 2",
+		},
+		{
+			name: "expands local macros defined by macrolet",
+			input: `
+				(defmacro hoge (x) x)
+				(macrolet ((hoge (x) (list 'car x)) (fuga () 1)) (list (hoge 3) (fuga)))
+				(hoge 2)
+			`,
+			expected: "(progn (list (car 3) 1)) 2",
+		},
+		{
+			name: "expands macro calls in the expansion until none remain",
+			input: `
+				(defmacro inc (x) ` + "`" + `(+ ,x 1))
+				(defmacro twice (x) ` + "`" + `(inc (inc ,x)))
+				(twice 1)
+			`,
+			expected: "(+ (+ 1 1) 1)",
+		},
+		{
+			name: "defines macro nested in progn",
+			input: `
+				(progn (defmacro hoge () 1) (hoge))
+			`,
+			expected: "(progn (quote hoge) 1)",
+		},
+		{
+			name: "defines macro by the expansion of another macro",
+			input: `
+				(defmacro defconst (name value) ` + "`" + `(defmacro ,name () ,value))
+				(defconst ten 10)
+				(ten)
+			`,
+			expected: "(quote ten) 10",
+		},
+		{
+			name: "does not expand inside quote",
+			input: `
+				(defmacro hoge () 1)
+				(list '(hoge) ` + "`" + `((hoge) ,(hoge)))
+			`,
+			expected: "(list (quote (hoge)) (backquote ((hoge) (unquote 1))))",
+		},
+		{
+			name: "does not expand the names which are not evaluated",
+			input: `
+				(defmacro hoge (x) x)
+				(let ((hoge 1)) (flet ((hoge (hoge) hoge)) (hoge (hoge 2))))
+			`,
+			expected: "(let ((hoge 1)) (flet ((hoge (hoge) hoge)) (hoge (hoge 2))))",
+		},
+		{
+			name: "does not expand the calls of the local functions",
+			input: `
+				(defmacro hoge (x) x)
+				(list (flet ((hoge (x) x)) (hoge 1)) (flet ((fuga (x) (hoge x))) (fuga 2)) (labels ((hoge (x) (hoge x))) 3))
+			`,
+			expected: "(list (flet ((hoge (x) x)) (hoge 1)) (flet ((fuga (x) x)) (fuga 2)) (labels ((hoge (x) (hoge x))) 3))",
+		},
+		{
+			name: "expands macro calls in the clauses of cond and case, but not the keys",
+			input: `
+				(defmacro hoge () 1)
+				(cond ((hoge) (hoge)) (hoge))
+				(case (hoge) ((hoge) (hoge)))
+				(when (hoge) (or (hoge) (hoge)))
+			`,
+			expected: "(cond (1 1) (hoge)) (case 1 ((hoge) 1)) (when 1 (or 1 1))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := testParseProgram(tt.expected)
+
+			program := testParseProgram(tt.input)
+			env := object.NewEnvironment()
+
+			expanded := testExpandMacros(t, program, env)
+
+			if expanded.String() != expected.String() {
+				t.Errorf("not equal. got=%q, want=%q", expanded.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestExpandMacrosError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defmacro)", "1:1: ERROR: macro definition needs name and lambda list, got (defmacro)\n(defmacro)\n^"},
+		{"(defmacro 1 (x) x)", "1:1: ERROR: macro name must be SYMBOL, got 1\n(defmacro 1 (x) x)\n^"},
+		{"(defmacro m (&foo) 1)", "1:1: ERROR: unknown lambda list keyword: &foo\n(defmacro m (&foo) 1)\n^"},
+		{"(progn (defmacro m))", "1:8: ERROR: macro definition needs name and lambda list, got (defmacro m)\n(progn (defmacro m))\n       ^"},
+		{"(macrolet ((1 () 1)) 2)", "1:12: ERROR: macro name must be SYMBOL, got 1\n(macrolet ((1 () 1)) 2)\n           ^"},
+		{"(macrolet x 2)", "1:1: ERROR: macro definitions must be LIST, got x\n(macrolet x 2)\n^"},
+		{"(defmacro m (x) x)\n(list (m))", "2:7: ERROR: macro m: function expects 1 arguments, but got 0\n(list (m))\n      ^"},
+		{"(defmacro m (x) x)\n(m 1 2)", "2:1: ERROR: macro m: function expects 1 arguments, but got 2\n(m 1 2)\n^"},
+		{"(defmacro m (x) x)\n(m . 1)", "2:1: ERROR: arguments to macro m must be a proper list, got 1\n(m . 1)\n^"},
+		{"(defmacro m ()\n  (car 1))\n(m)", "2:3: ERROR: argument to `car` must be LIST, got INTEGER\n  (car 1))\n  ^"},
+		{"(defmacro m () (throw 'tag 1))\n(m)", "1:16: ERROR: throw: no catch for tag tag\n(defmacro m () (throw 'tag 1))\n               ^"},
+		{"(defmacro m (x) `(m ,x))\n(m 1)", "2:1: ERROR: macro m: expansion is nested too deeply\n(m 1)\n^"},
+		{"(defmacro m () '(m))\n(m)", "2:1: ERROR: macro m: expansion is nested too deeply\n(m)\n^"},
+		{"(defmacro m (x) `(list (m ,x)))\n(m 1)", "2:1: ERROR: macro m: expansion is nested too deeply\n(m 1)\n^"},
+	}
+
+	for _, tt := range tests {
+		_, err := ExpandMacros(testParseProgram(tt.input), object.NewEnvironment())
+		if err == nil {
+			t.Errorf("input=%q: expected error", tt.input)
+			continue
+		}
+		if err.Report() != tt.expected {
+			t.Errorf("input=%q: expected=%q, got=%q", tt.input, tt.expected, err.Report())
+		}
+	}
+}
+
+func TestExpandMacrosInterrupt(t *testing.T) {
+	defer ClearInterrupt()
+
+	env := object.NewEnvironment()
+	testExpandMacros(t, testParseProgram("(defmacro m () '(m))"), env)
+
+	Interrupt()
+	_, err := ExpandMacros(testParseProgram("(m)"), env)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.Report() != "1:1: ERROR: interrupted\n(m)\n^" {
+		t.Errorf("wrong error. got=%q", err.Report())
+	}
+}
+
+func TestMacroIsolation(t *testing.T) {
+	env := object.NewEnvironment()
+	testExpandMacros(t, testParseProgram("(defmacro hoge () 1)"), env.MacroEnvironment())
+
+	other := object.NewEnvironment()
+	expanded := testExpandMacros(t, testParseProgram("(hoge)"), other.MacroEnvironment())
+	if expanded.String() != "(hoge)" {
+		t.Errorf("macro leaks into the other environment. got=%q", expanded.String())
+	}
+
+	expanded = testExpandMacros(t, testParseProgram("(hoge)"), env.MacroEnvironment())
+	if expanded.String() != "1" {
+		t.Errorf("macro is not expanded. got=%q", expanded.String())
+	}
+}
+
+func TestMacroBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(defmacro hoge (x) x) (macro-function (intern \"hoge\"))", "(macro (x) x)"},
+		{"(macro-function 'car)", "nil"},
+		{"(macro-function 1)", "argument to `macro-function` must be SYMBOL, got INTEGER"},
+		{"(defmacro hoge (x) x) (fboundp (intern \"hoge\"))", "T"},
+		{"(defmacro hoge (x) x) (setq s (intern \"hoge\")) (fmakunbound s) (list (macro-function s) (fboundp s))", "(nil nil)"},
+		{"(defmacro inc (x) `(+ ,x 1)) (defmacro twice (x) `(inc (inc ,x))) (macroexpand-1 '(twice 1))", "(inc (inc 1))"},
+		{"(defmacro inc (x) `(+ ,x 1)) (defmacro twice (x) `(inc (inc ,x))) (macroexpand '(twice 1))", "(+ (inc 1) 1)"},
+		{"(macroexpand '(car x))", "(car x)"},
+		{"(macroexpand-1 1)", "1"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		program := testExpandMacros(t, testParseProgram(tt.input), env.MacroEnvironment())
+
+		evaluated := Eval(program, env)
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("input=%s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
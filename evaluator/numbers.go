@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+// numberKind orders the numeric types by floating-point contagion:
+// an operation on two numbers is carried out in the larger kind of the two
+type numberKind int
+
+const (
+	integerKind numberKind = iota
+	ratioKind
+	floatKind
+)
+
+func numberKindOf(obj object.Object) (numberKind, bool) {
+	switch obj.(type) {
+	case *object.Integer, *object.Bignum:
+		return integerKind, true
+	case *object.Ratio:
+		return ratioKind, true
+	case *object.Float:
+		return floatKind, true
+	default:
+		return 0, false
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := numberKindOf(obj)
+	return ok
+}
+
+func contagion(left, right object.Object) numberKind {
+	leftKind, _ := numberKindOf(left)
+	rightKind, _ := numberKindOf(right)
+	return max(leftKind, rightKind)
+}
+
+// toBigInt converts an integer (fixnum or bignum) to *big.Int
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.Bignum:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+// toRat converts a rational number (integer or ratio) to *big.Rat
+func toRat(obj object.Object) *big.Rat {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return new(big.Rat).SetInt64(obj.Value)
+	case *object.Bignum:
+		return new(big.Rat).SetInt(obj.Value)
+	case *object.Ratio:
+		return obj.Value
+	default:
+		return new(big.Rat)
+	}
+}
+
+// exactRat converts a real number to *big.Rat without rounding.
+// it returns nil for the infinite floats, which have no rational value.
+func exactRat(obj object.Object) *big.Rat {
+	if f, ok := obj.(*object.Float); ok {
+		return new(big.Rat).SetFloat64(f.Value)
+	}
+	return toRat(obj)
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Bignum:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Ratio:
+		f, _ := obj.Value.Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+// normalizeInteger demotes n to a fixnum if it fits in int64
+func normalizeInteger(n *big.Int) object.Object {
+	if n.IsInt64() {
+		return &object.Integer{Value: n.Int64()}
+	}
+	return &object.Bignum{Value: n}
+}
+
+// normalizeRatio returns an integer if the denominator of rat is 1
+func normalizeRatio(rat *big.Rat) object.Object {
+	if rat.IsInt() {
+		return normalizeInteger(new(big.Int).Set(rat.Num()))
+	}
+	return &object.Ratio{Value: rat}
+}
+
+func isZero(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value == 0
+	case *object.Bignum:
+		return obj.Value.Sign() == 0
+	case *object.Ratio:
+		return obj.Value.Sign() == 0
+	case *object.Float:
+		return obj.Value == 0
+	default:
+		return false
+	}
+}
+
+func addNumbers(left, right object.Object) object.Object {
+	switch contagion(left, right) {
+	case integerKind:
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if lok && rok {
+			sum := l.Value + r.Value
+			// adding r moves the sum in the direction of the sign of r unless it overflows
+			if (sum > l.Value) == (r.Value > 0) {
+				return &object.Integer{Value: sum}
+			}
+		}
+		return normalizeInteger(new(big.Int).Add(toBigInt(left), toBigInt(right)))
+	case ratioKind:
+		return normalizeRatio(new(big.Rat).Add(toRat(left), toRat(right)))
+	default:
+		return &object.Float{Value: toFloat(left) + toFloat(right)}
+	}
+}
+
+func subtractNumbers(left, right object.Object) object.Object {
+	switch contagion(left, right) {
+	case integerKind:
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if lok && rok {
+			diff := l.Value - r.Value
+			if (diff < l.Value) == (r.Value > 0) {
+				return &object.Integer{Value: diff}
+			}
+		}
+		return normalizeInteger(new(big.Int).Sub(toBigInt(left), toBigInt(right)))
+	case ratioKind:
+		return normalizeRatio(new(big.Rat).Sub(toRat(left), toRat(right)))
+	default:
+		return &object.Float{Value: toFloat(left) - toFloat(right)}
+	}
+}
+
+func multiplyNumbers(left, right object.Object) object.Object {
+	switch contagion(left, right) {
+	case integerKind:
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if lok && rok {
+			product := l.Value * r.Value
+			// dividing the product back gives the other operand only if nothing overflowed
+			if l.Value == 0 || (product/l.Value == r.Value && !(l.Value == -1 && r.Value == math.MinInt64)) {
+				return &object.Integer{Value: product}
+			}
+		}
+		return normalizeInteger(new(big.Int).Mul(toBigInt(left), toBigInt(right)))
+	case ratioKind:
+		return normalizeRatio(new(big.Rat).Mul(toRat(left), toRat(right)))
+	default:
+		return &object.Float{Value: toFloat(left) * toFloat(right)}
+	}
+}
+
+// divideNumbers divides left by right. integers which do not divide evenly produce a ratio.
+func divideNumbers(left, right object.Object) object.Object {
+	if isZero(right) {
+		return newConditionError("division-by-zero", "division by zero")
+	}
+
+	switch contagion(left, right) {
+	case integerKind, ratioKind:
+		return normalizeRatio(new(big.Rat).Quo(toRat(left), toRat(right)))
+	default:
+		return &object.Float{Value: toFloat(left) / toFloat(right)}
+	}
+}
+
+func negateNumber(obj object.Object) object.Object {
+	return subtractNumbers(&object.Integer{Value: 0}, obj)
+}
+
+// compareNumbers returns -1, 0 or +1 depending on whether left is less than, equal to or greater than right
+func compareNumbers(left, right object.Object) int {
+	switch contagion(left, right) {
+	case integerKind:
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if !lok || !rok {
+			return toBigInt(left).Cmp(toBigInt(right))
+		}
+		switch {
+		case l.Value < r.Value:
+			return -1
+		case l.Value > r.Value:
+			return 1
+		default:
+			return 0
+		}
+	case ratioKind:
+		return toRat(left).Cmp(toRat(right))
+	default:
+		// a rational is compared with the exact value of the float, as converting it to a float may round it
+		_, lFloat := left.(*object.Float)
+		_, rFloat := right.(*object.Float)
+		if lFloat != rFloat {
+			if l, r := exactRat(left), exactRat(right); l != nil && r != nil {
+				return l.Cmp(r)
+			}
+		}
+
+		l, r := toFloat(left), toFloat(right)
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		default:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getStringBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "length":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.String:
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *object.Nil, *object.ConsCell:
+					list, ok := listToSlice(arg)
+					if !ok {
+						return newConditionError("type-error", "argument to `length` must be a proper list, got %s", arg.Inspect())
+					}
+					return &object.Integer{Value: int64(len(list))}
+				default:
+					return newConditionError("type-error", "argument to `length` must be SEQUENCE, got %s", arg.Type())
+				}
+			},
+		}, true
+	case "concatenate":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if !isStringTypeSpecifier(args[0]) {
+					return newConditionError("type-error", "first argument to `concatenate` must be 'string, got %s", args[0].Inspect())
+				}
+
+				var out strings.Builder
+				for _, arg := range args[1:] {
+					str, ok := arg.(*object.String)
+					if !ok {
+						return newConditionError("type-error", "argument to `concatenate` must be STRING, got %s", arg.Type())
+					}
+					out.WriteString(str.Value)
+				}
+				return &object.String{Value: out.String()}
+			},
+		}, true
+	case "subseq":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newConditionError("type-error", "first argument to `subseq` must be STRING, got %s", args[0].Type())
+				}
+				runes := []rune(str.Value)
+
+				start, ok := args[1].(*object.Integer)
+				if !ok {
+					return newConditionError("type-error", "second argument to `subseq` must be INTEGER, got %s", args[1].Type())
+				}
+
+				end := int64(len(runes))
+				if len(args) == 3 {
+					switch arg := args[2].(type) {
+					case *object.Integer:
+						end = arg.Value
+					case *object.Nil:
+					default:
+						return newConditionError("type-error", "third argument to `subseq` must be INTEGER, got %s", arg.Type())
+					}
+				}
+
+				if start.Value < 0 || end > int64(len(runes)) || start.Value > end {
+					return newError("bounding indices %d and %d are out of range for %s", start.Value, end, str.Inspect())
+				}
+
+				return &object.String{Value: string(runes[start.Value:end])}
+			},
+		}, true
+	case "string=":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("string=", 2, args)
+				if err != nil {
+					return err
+				}
+
+				if strs[0] != strs[1] {
+					return Nil
+				}
+				return True
+			},
+		}, true
+	case "string<":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("string<", 2, args)
+				if err != nil {
+					return err
+				}
+
+				// return the index of the first mismatch if the first string is less than the second one
+				left, right := []rune(strs[0]), []rune(strs[1])
+				for i := 0; i < len(left); i++ {
+					if i >= len(right) || left[i] > right[i] {
+						return Nil
+					}
+					if left[i] < right[i] {
+						return &object.Integer{Value: int64(i)}
+					}
+				}
+				if len(left) < len(right) {
+					return &object.Integer{Value: int64(len(left))}
+				}
+				return Nil
+			},
+		}, true
+	case "string-upcase":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("string-upcase", 1, args)
+				if err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToUpper(strs[0])}
+			},
+		}, true
+	case "string-downcase":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("string-downcase", 1, args)
+				if err != nil {
+					return err
+				}
+				return &object.String{Value: strings.ToLower(strs[0])}
+			},
+		}, true
+	case "string-trim":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("string-trim", 2, args)
+				if err != nil {
+					return err
+				}
+				return &object.String{Value: strings.Trim(strs[1], strs[0])}
+			},
+		}, true
+	case "search":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("search", 2, args)
+				if err != nil {
+					return err
+				}
+
+				index := strings.Index(strs[1], strs[0])
+				if index < 0 {
+					return Nil
+				}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(strs[1][:index]))}
+			},
+		}, true
+	case "char":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newConditionError("type-error", "first argument to `char` must be STRING, got %s", args[0].Type())
+				}
+				index, ok := args[1].(*object.Integer)
+				if !ok {
+					return newConditionError("type-error", "second argument to `char` must be INTEGER, got %s", args[1].Type())
+				}
+
+				runes := []rune(str.Value)
+				if index.Value < 0 || index.Value >= int64(len(runes)) {
+					return newError("index %d is out of range for %s", index.Value, str.Inspect())
+				}
+				return &object.Character{Value: runes[index.Value]}
+			},
+		}, true
+	case "parse-integer":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs("parse-integer", 1, args)
+				if err != nil {
+					return err
+				}
+
+				value, ok := new(big.Int).SetString(strings.TrimSpace(strs[0]), 10)
+				if !ok {
+					return newError("could not parse %q as integer", strs[0])
+				}
+				return normalizeInteger(value)
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// stringArgs checks that exactly n arguments are given and all of them are strings
+func stringArgs(funcName string, n int, args []object.Object) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newConditionError("type-error", "argument to `%s` must be STRING, got %s", funcName, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	return strs, nil
+}
+
+// isStringTypeSpecifier reports whether obj is the quoted result type 'string
+func isStringTypeSpecifier(obj object.Object) bool {
+	symbol, ok := obj.(*object.Symbol)
+	if !ok {
+		return false
+	}
+
+	return strings.EqualFold(designatorName(symbol), "string")
+}
@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getSymbolBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "symbolp", "keywordp":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				var result bool
+				switch arg := args[0].(type) {
+				case *object.Symbol:
+					result = funcName == "symbolp" || arg.IsKeyword()
+				case *object.Nil, *object.True:
+					// nil and t are symbols, but not keywords
+					result = funcName == "symbolp"
+				}
+
+				if result {
+					return True
+				}
+				return Nil
+			},
+		}, true
+	case "symbol-name":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.Symbol:
+					return &object.String{Value: arg.Name}
+				case *object.Nil, *object.True:
+					return &object.String{Value: arg.Inspect()}
+				default:
+					return newConditionError("type-error", "argument to `symbol-name` must be SYMBOL, got %s", arg.Type())
+				}
+			},
+		}, true
+	case "intern", "make-symbol":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				strs, err := stringArgs(funcName, 1, args)
+				if err != nil {
+					return err
+				}
+
+				if funcName == "make-symbol" {
+					return &object.Symbol{Name: strs[0], Uninterned: true}
+				}
+				return internSymbol(strs[0], env)
+			},
+		}, true
+	case "gensym":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+				}
+
+				// the default prefix is in lowercase like the names of the symbols read without escapes
+				prefix := "g"
+				if len(args) == 1 {
+					str, ok := args[0].(*object.String)
+					if !ok {
+						return newConditionError("type-error", "argument to `gensym` must be STRING, got %s", args[0].Type())
+					}
+					prefix = str.Value
+				}
+
+				return &object.Symbol{
+					Name:       fmt.Sprintf("%s%d", prefix, env.NextGensymCounter()),
+					Uninterned: true,
+				}
+			},
+		}, true
+	case "symbol-value", "boundp":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				var value object.Object
+				switch arg := args[0].(type) {
+				case *object.Symbol:
+					// the value of a symbol is the global one, as the lexical variables are not visible from functions
+					if arg.IsKeyword() {
+						value = arg
+					} else if v, ok := env.Global().Get(arg.Name); ok {
+						value = v
+					} else if funcName == "symbol-value" {
+						return newConditionError("unbound-variable", "symbol not found: %s", arg.Name)
+					}
+				case *object.Nil, *object.True:
+					value = arg
+				default:
+					return newConditionError("type-error", "argument to `%s` must be SYMBOL, got %s", funcName, arg.Type())
+				}
+
+				if funcName == "symbol-value" {
+					return value
+				}
+				if value == nil {
+					return Nil
+				}
+				return True
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,171 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+func getTypeBuiltinFunctions(funcName string) (*object.Builtin, bool) {
+	switch funcName {
+	case "typep":
+		return &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				ok, err := isOfType(args[0], args[1], env)
+				if err != nil {
+					return err
+				}
+				if ok {
+					return True
+				}
+				return Nil
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// isOfType reports whether obj is of the type specified by typeSpec,
+// which is a type name such as integer or a compound type specifier such as (or string symbol).
+// the condition types defined by define-condition are also type names.
+func isOfType(obj object.Object, typeSpec object.Object, env *object.Environment) (bool, object.Object) {
+	switch typeSpec := typeSpec.(type) {
+	case *object.True:
+		return true, nil
+	case *object.Nil:
+		return false, nil
+	case *object.Symbol:
+		return isOfTypeName(obj, designatorName(typeSpec), env)
+	case *object.ConsCell:
+		return isOfCompoundType(obj, typeSpec, env)
+	default:
+		return false, newError("invalid type specifier: %s", typeSpec.Inspect())
+	}
+}
+
+func isOfTypeName(obj object.Object, name string, env *object.Environment) (bool, object.Object) {
+	kind, isNum := numberKindOf(obj)
+
+	switch strings.ToLower(name) {
+	case "t":
+		return true, nil
+	case "nil":
+		return false, nil
+	case "null":
+		_, ok := obj.(*object.Nil)
+		return ok, nil
+	case "boolean":
+		switch obj.(type) {
+		case *object.Nil, *object.True:
+			return true, nil
+		}
+		return false, nil
+	case "symbol":
+		switch obj.(type) {
+		case *object.Symbol, *object.Nil, *object.True:
+			return true, nil
+		}
+		return false, nil
+	case "keyword":
+		symbol, ok := obj.(*object.Symbol)
+		return ok && symbol.IsKeyword(), nil
+	case "cons":
+		_, ok := obj.(*object.ConsCell)
+		return ok, nil
+	case "atom":
+		_, ok := obj.(*object.ConsCell)
+		return !ok, nil
+	case "list":
+		switch obj.(type) {
+		case *object.ConsCell, *object.Nil:
+			return true, nil
+		}
+		return false, nil
+	case "number", "real":
+		return isNum, nil
+	case "rational":
+		return isNum && kind != floatKind, nil
+	case "integer":
+		return isNum && kind == integerKind, nil
+	case "fixnum":
+		_, ok := obj.(*object.Integer)
+		return ok, nil
+	case "bignum":
+		_, ok := obj.(*object.Bignum)
+		return ok, nil
+	case "ratio":
+		return isNum && kind == ratioKind, nil
+	case "float":
+		return isNum && kind == floatKind, nil
+	case "string":
+		_, ok := obj.(*object.String)
+		return ok, nil
+	case "character":
+		_, ok := obj.(*object.Character)
+		return ok, nil
+	case "function":
+		switch obj.(type) {
+		case *object.Function, *object.Builtin, object.Applicable:
+			return true, nil
+		}
+		return false, nil
+	}
+
+	if _, ok := lookupConditionType(name, env); ok {
+		condition, ok := obj.(*object.Condition)
+		return ok && isConditionOfType(condition, name), nil
+	}
+
+	return false, newError("unknown type specifier: %s", name)
+}
+
+// isOfCompoundType handles the type specifiers (or type...), (and type...), (not type), (member object...) and (eql object)
+func isOfCompoundType(obj object.Object, typeSpec *object.ConsCell, env *object.Environment) (bool, object.Object) {
+	symbol, ok := typeSpec.Car.(*object.Symbol)
+	if !ok {
+		return false, newError("invalid type specifier: %s", typeSpec.Inspect())
+	}
+	args, ok := listToSlice(typeSpec.Cdr)
+	if !ok {
+		return false, newError("invalid type specifier: %s", typeSpec.Inspect())
+	}
+
+	switch strings.ToLower(designatorName(symbol)) {
+	case "or", "and":
+		// (or) is the empty type and (and) is the type of every object
+		isOr := strings.EqualFold(symbol.Name, "or")
+		for _, arg := range args {
+			ok, err := isOfType(obj, arg, env)
+			if err != nil {
+				return false, err
+			}
+			if ok == isOr {
+				return isOr, nil
+			}
+		}
+		return !isOr, nil
+	case "not":
+		if len(args) != 1 {
+			return false, newError("invalid type specifier: %s", typeSpec.Inspect())
+		}
+		ok, err := isOfType(obj, args[0], env)
+		return !ok, err
+	case "member", "eql":
+		if strings.EqualFold(symbol.Name, "eql") && len(args) != 1 {
+			return false, newError("invalid type specifier: %s", typeSpec.Inspect())
+		}
+		for _, arg := range args {
+			if isEql(obj, arg) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, newError("unknown type specifier: %s", typeSpec.Inspect())
+	}
+}
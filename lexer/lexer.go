@@ -1,162 +1,439 @@
-package lexer
-
-import "github.com/JunNishimura/go-lisp/token"
-
-type Lexer struct {
-	input    string
-	curPos   int
-	nextPos  int
-	prevChar byte
-	curChar  byte
-}
-
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
-	l.readChar()
-	return l
-}
-
-func (l *Lexer) readChar() {
-	// move to the next character
-	if l.nextPos >= len(l.input) {
-		l.curChar = 0
-	} else {
-		l.curChar = l.input[l.nextPos]
-	}
-	l.curPos = l.nextPos
-	l.nextPos++
-
-	// store the previous character
-	if l.curPos >= len(l.input) {
-		l.prevChar = l.input[len(l.input)-1]
-	} else if l.curPos > 0 {
-		l.prevChar = l.input[l.curPos-1]
-	}
-}
-
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
-	l.skipWhitespace()
-
-	switch l.curChar {
-	case '(':
-		tok = newToken(token.LPAREN, l.curChar)
-	case ')':
-		tok = newToken(token.RPAREN, l.curChar)
-	case '+':
-		if isSymbol(l.prevChar) {
-			tok = newToken(token.SYMBOL, l.curChar)
-		} else {
-			tok = newToken(token.PLUS, l.curChar)
-		}
-	case '-':
-		if isSymbol(l.prevChar) {
-			tok = newToken(token.SYMBOL, l.curChar)
-		} else {
-			tok = newToken(token.MINUS, l.curChar)
-		}
-	case '.':
-		tok = newToken(token.DOT, l.curChar)
-	case '\'':
-		tok = newToken(token.QUOTE, l.curChar)
-	case '`':
-		tok = newToken(token.BACKQUOTE, l.curChar)
-	case ',':
-		tok = newToken(token.COMMA, l.curChar)
-	case '<':
-		if l.peekChar() == '=' {
-			lt := l.curChar
-			l.readChar()
-			literal := string(lt) + string(l.curChar)
-			tok = token.Token{Type: token.SYMBOL, Literal: literal}
-		} else {
-			tok = newToken(token.SYMBOL, l.curChar)
-		}
-	case '>':
-		if l.peekChar() == '=' {
-			gt := l.curChar
-			l.readChar()
-			literal := string(gt) + string(l.curChar)
-			tok = token.Token{Type: token.SYMBOL, Literal: literal}
-		} else {
-			tok = newToken(token.SYMBOL, l.curChar)
-		}
-	case '/':
-		if l.peekChar() == '=' {
-			ne := l.curChar
-			l.readChar()
-			literal := string(ne) + string(l.curChar)
-			tok = token.Token{Type: token.SYMBOL, Literal: literal}
-		} else {
-			tok = newToken(token.SYMBOL, l.curChar)
-		}
-	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
-	default:
-		if isLetter(l.curChar) || isSpecialChar(l.curChar) {
-			tok.Literal = l.readString()
-			tok.Type = token.LookupKeyword(tok.Literal)
-			return tok
-		} else if isDigit(l.curChar) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
-		}
-		tok = newToken(token.ILLEGAL, l.curChar)
-	}
-
-	l.readChar()
-	return tok
-}
-
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch)}
-}
-
-func (l *Lexer) skipWhitespace() {
-	for l.curChar == ' ' || l.curChar == '\t' || l.curChar == '\n' || l.curChar == '\r' {
-		l.readChar()
-	}
-}
-
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z'
-}
-
-func isDigit(ch byte) bool {
-	return '0' <= ch && ch <= '9'
-}
-
-func isSpecialChar(ch byte) bool {
-	return ch == '*' ||
-		ch == '='
-}
-
-func isSymbol(ch byte) bool {
-	return string(ch) == token.LPAREN
-}
-
-func (l *Lexer) readString() string {
-	startPos := l.curPos
-	for isLetter(l.curChar) || isSpecialChar(l.curChar) {
-		l.readChar()
-	}
-	return l.input[startPos:l.curPos]
-}
-
-func (l *Lexer) readNumber() string {
-	startPos := l.curPos
-	for isDigit(l.curChar) {
-		l.readChar()
-	}
-	return l.input[startPos:l.curPos]
-}
-
-func (l *Lexer) peekChar() byte {
-	if l.nextPos >= len(l.input) {
-		return 0
-	}
-	return l.input[l.nextPos]
-}
+package lexer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+type Lexer struct {
+	input   string
+	curPos  int
+	nextPos int
+	curChar byte
+
+	// source is the input with its file name, which the positions of the tokens refer to.
+	// line and column are the position of curChar.
+	source *token.Source
+	line   int
+	column int
+}
+
+func New(input string) *Lexer {
+	return NewFile("", input)
+}
+
+// NewFile returns the lexer which reads input from the file named filename
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{
+		input:  input,
+		source: &token.Source{Name: filename, Text: input},
+		line:   1,
+	}
+	l.readChar()
+	return l
+}
+
+// Source returns the input which the positions of the tokens refer to
+func (l *Lexer) Source() *token.Source {
+	return l.source
+}
+
+func (l *Lexer) readChar() {
+	// the lexer stays at the end of the input once it is reached
+	if l.nextPos > len(l.input) {
+		return
+	}
+
+	// the line ends when the character being left is a newline
+	if l.nextPos > 0 && l.curPos < len(l.input) && l.input[l.curPos] == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	// move to the next character
+	if l.nextPos >= len(l.input) {
+		l.curChar = 0
+	} else {
+		l.curChar = l.input[l.nextPos]
+	}
+	l.curPos = l.nextPos
+	l.nextPos++
+
+	// the continuation bytes of a UTF-8 character do not start a new column
+	if l.curPos >= len(l.input) || utf8.RuneStart(l.input[l.curPos]) {
+		l.column++
+	}
+}
+
+// pos returns the position of the current character
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Source: l.source,
+		Offset: l.curPos,
+		Line:   l.line,
+		Column: l.column,
+	}
+}
+
+func (l *Lexer) NextToken() token.Token {
+	comments, ok := l.readTrivia()
+	if !ok {
+		// the unterminated block comment is reported as the illegal token
+		last := comments[len(comments)-1]
+		return token.Token{
+			Type:     token.ILLEGAL,
+			Literal:  last.Text,
+			Pos:      last.Pos,
+			End:      last.End,
+			Comments: comments[:len(comments)-1],
+		}
+	}
+
+	pos := l.pos()
+	tok := l.readToken()
+	tok.Pos = pos
+	tok.End = l.pos()
+	tok.Comments = comments
+
+	return tok
+}
+
+// readTrivia skips the whitespaces and the comments before the next token, and returns the comments.
+// it returns false if the last comment is a block comment which is not terminated.
+func (l *Lexer) readTrivia() ([]token.Comment, bool) {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+
+		start := l.pos()
+		switch {
+		case l.curChar == ';':
+			for l.curChar != '\n' && l.curChar != 0 {
+				l.readChar()
+			}
+		case l.curChar == '#' && l.peekChar() == '|':
+			if !l.skipBlockComment() {
+				comments = append(comments, l.newComment(start))
+				return comments, false
+			}
+		case l.curChar == '#' && l.peekChar() == ';':
+			l.readChar()
+			l.readChar()
+			l.skipDatum()
+		default:
+			return comments, true
+		}
+		comments = append(comments, l.newComment(start))
+	}
+}
+
+func (l *Lexer) newComment(start token.Position) token.Comment {
+	text := strings.TrimRight(l.input[start.Offset:l.curPos], "\r")
+	return token.Comment{Text: text, Pos: start, End: l.pos()}
+}
+
+// skipBlockComment skips #| ... |#, in which the block comments can be nested
+func (l *Lexer) skipBlockComment() bool {
+	depth := 0
+	for l.curChar != 0 {
+		switch {
+		case l.curChar == '#' && l.peekChar() == '|':
+			l.readChar()
+			depth++
+		case l.curChar == '|' && l.peekChar() == '#':
+			l.readChar()
+			depth--
+		}
+		l.readChar()
+
+		if depth == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDatum skips the S-expression commented out by #;
+func (l *Lexer) skipDatum() {
+	tok := l.NextToken()
+	switch tok.Type {
+	case token.LPAREN:
+		for depth := 1; depth > 0; {
+			switch l.NextToken().Type {
+			case token.LPAREN:
+				depth++
+			case token.RPAREN:
+				depth--
+			case token.EOF:
+				return
+			}
+		}
+	case token.QUOTE, token.BACKQUOTE, token.COMMA, token.COMMA_AT, token.FUNCTION:
+		// the shorthands such as 'x are followed by the S-expression they apply to,
+		// while the names such as quote are symbols by themselves
+		if tok.Literal == "'" || tok.Literal == "`" || tok.Literal == "," || tok.Literal == ",@" || tok.Literal == "#'" {
+			l.skipDatum()
+		}
+	}
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
+	switch l.curChar {
+	case '(':
+		tok = newToken(token.LPAREN, l.curChar)
+	case ')':
+		tok = newToken(token.RPAREN, l.curChar)
+	case '\'':
+		tok = newToken(token.QUOTE, l.curChar)
+	case '`':
+		tok = newToken(token.BACKQUOTE, l.curChar)
+	case ',':
+		if l.peekChar() == '@' {
+			l.readChar()
+			tok = token.Token{Type: token.COMMA_AT, Literal: ",@"}
+		} else {
+			tok = newToken(token.COMMA, l.curChar)
+		}
+	case '#':
+		if l.peekChar() == '\'' {
+			l.readChar()
+			tok = token.Token{Type: token.FUNCTION, Literal: "#'"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.curChar)
+		}
+	case '"':
+		if str, err := l.readStringLiteral(); err == nil {
+			tok = token.Token{Type: token.STRING, Literal: str}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: str, Err: err}
+		}
+	case 0:
+		tok.Literal = ""
+		tok.Type = token.EOF
+	default:
+		if isConstituent(l.curChar) || isEscape(l.curChar) {
+			return l.readAtom()
+		}
+		tok = newToken(token.ILLEGAL, l.curChar)
+	}
+
+	l.readChar()
+	return tok
+}
+
+func newToken(tokenType token.TokenType, ch byte) token.Token {
+	return token.Token{Type: tokenType, Literal: string(ch)}
+}
+
+func (l *Lexer) skipWhitespace() {
+	for isWhitespace(l.curChar) {
+		l.readChar()
+	}
+}
+
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f'
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// isConstituent reports whether ch can be a part of a symbol or a number without being escaped.
+// the bytes of the UTF-8 characters other than ASCII are constituents.
+func isConstituent(ch byte) bool {
+	if ch <= ' ' || ch == 0x7f || isEscape(ch) {
+		return false
+	}
+	return !isTerminatingMacroChar(ch)
+}
+
+// isTerminatingMacroChar reports whether ch ends the symbol or the number before it
+func isTerminatingMacroChar(ch byte) bool {
+	switch ch {
+	case '(', ')', '\'', '`', ',', '"', ';':
+		return true
+	}
+	return false
+}
+
+// isEscape reports whether ch is the single escape \ or the multiple escape |
+func isEscape(ch byte) bool {
+	return ch == '\\' || ch == '|'
+}
+
+// readAtom reads the constituents and the escaped characters up to the next whitespace or terminating macro character.
+// the token is a number if it is written like a number, or a symbol otherwise.
+// the symbol with escapes, such as |foo bar| and a\(b, is never a number nor a special form.
+// the case of the characters without escapes is folded, so that Foo and foo are the same symbol.
+func (l *Lexer) readAtom() token.Token {
+	startPos := l.curPos
+	var name, unescaped strings.Builder
+	escaped := false
+
+	// foldCase writes the characters read since the last escape to name in lowercase.
+	// the invalid UTF-8 is left as it is so that the token is reported as illegal.
+	foldCase := func() {
+		if utf8.ValidString(unescaped.String()) {
+			name.WriteString(strings.ToLower(unescaped.String()))
+		} else {
+			name.WriteString(unescaped.String())
+		}
+		unescaped.Reset()
+	}
+
+	for {
+		switch {
+		case l.curChar == '\\':
+			foldCase()
+			escaped = true
+			l.readChar()
+			if l.curChar == 0 {
+				return token.Token{Type: token.ILLEGAL, Literal: l.input[startPos:l.curPos]}
+			}
+			name.WriteByte(l.curChar)
+			l.readChar()
+		case l.curChar == '|':
+			foldCase()
+			escaped = true
+			l.readChar()
+			for l.curChar != '|' {
+				if l.curChar == '\\' {
+					l.readChar()
+				}
+				if l.curChar == 0 {
+					return token.Token{Type: token.ILLEGAL, Literal: l.input[startPos:l.curPos]}
+				}
+				name.WriteByte(l.curChar)
+				l.readChar()
+			}
+			l.readChar()
+		case isConstituent(l.curChar):
+			unescaped.WriteByte(l.curChar)
+			l.readChar()
+		default:
+			foldCase()
+			return newAtomToken(l.input[startPos:l.curPos], name.String(), escaped)
+		}
+	}
+}
+
+// newAtomToken returns the token of the atom written as literal, whose name is the one with the case folded.
+// the numbers keep their spelling, e.g. 1E5.
+func newAtomToken(literal, name string, escaped bool) token.Token {
+	if escaped {
+		// the colon without escape makes the keyword, such as :|a b|, while |:a| is not a keyword
+		if strings.HasPrefix(literal, ":") && len(name) > 1 {
+			return token.Token{Type: token.KEYWORD, Literal: name}
+		}
+		return token.Token{Type: token.SYMBOL, Literal: name}
+	}
+	if literal == "." {
+		return token.Token{Type: token.DOT, Literal: literal}
+	}
+	if tokType, ok := numberType(literal); ok {
+		return token.Token{Type: tokType, Literal: literal}
+	}
+	if !utf8.ValidString(literal) {
+		return token.Token{Type: token.ILLEGAL, Literal: literal}
+	}
+	return token.Token{Type: token.LookupKeyword(name), Literal: name}
+}
+
+var (
+	// the integer may end with a decimal point, which says that it is written in decimal
+	integerSyntax = regexp.MustCompile(`^[+-]?[0-9]+\.?$`)
+	ratioSyntax   = regexp.MustCompile(`^[+-]?[0-9]+/[0-9]+$`)
+	// the exponent markers d, f, s and l stand for the precisions of the float, which are all float64 here
+	floatSyntax = regexp.MustCompile(`^[+-]?([0-9]*\.[0-9]+([eEdDfFsSlL][+-]?[0-9]+)?|[0-9]+(\.[0-9]*)?[eEdDfFsSlL][+-]?[0-9]+)$`)
+)
+
+// numberType returns the type of the number which literal is written as.
+// a sign can only precede the number, so +1 is an integer while 1+ is a symbol.
+func numberType(literal string) (token.TokenType, bool) {
+	switch {
+	case integerSyntax.MatchString(literal):
+		return token.INT, true
+	case ratioSyntax.MatchString(literal):
+		return token.RATIO, true
+	case floatSyntax.MatchString(literal):
+		return token.FLOAT, true
+	}
+	return "", false
+}
+
+// readStringLiteral reads a double-quoted string and returns its unescaped content.
+// the error is returned if the literal is not terminated or has an invalid escape.
+// the literal is read up to the closing quote even after an invalid escape, so that the lexer resumes after it.
+func (l *Lexer) readStringLiteral() (string, *token.TokenError) {
+	var out strings.Builder
+	start := l.pos()
+	var escapeErr *token.TokenError
+
+	for {
+		l.readChar()
+		switch l.curChar {
+		case '"':
+			return out.String(), escapeErr
+		case 0:
+			return out.String(), &token.TokenError{Message: "unterminated string literal", Pos: start}
+		case '\\':
+			escapePos := l.pos()
+			l.readChar()
+			switch l.curChar {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'u':
+				r, ok := l.readUnicodeEscape()
+				if !ok {
+					if escapeErr == nil {
+						escapeErr = &token.TokenError{Message: `invalid \u escape, expected 4 hex digits`, Pos: escapePos}
+					}
+					continue
+				}
+				out.WriteRune(r)
+			case 0:
+				return out.String(), &token.TokenError{Message: "unterminated string literal", Pos: start}
+			default:
+				// any other escaped character stands for itself, e.g. \" and \\
+				out.WriteByte(l.curChar)
+			}
+		default:
+			out.WriteByte(l.curChar)
+		}
+	}
+}
+
+// readUnicodeEscape reads the four hex digits following \u
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	if l.nextPos+4 > len(l.input) {
+		return utf8.RuneError, false
+	}
+
+	code, err := strconv.ParseUint(l.input[l.nextPos:l.nextPos+4], 16, 32)
+	if err != nil {
+		return utf8.RuneError, false
+	}
+
+	for i := 0; i < 4; i++ {
+		l.readChar()
+	}
+
+	return rune(code), true
+}
+
+func (l *Lexer) peekChar() byte {
+	if l.nextPos >= len(l.input) {
+		return 0
+	}
+	return l.input[l.nextPos]
+}
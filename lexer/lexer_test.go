@@ -1,517 +1,837 @@
-package lexer
-
-import (
-	"testing"
-
-	"github.com/JunNishimura/go-lisp/token"
-)
-
-func TestProgram(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected []token.Token
-	}{
-		{
-			name: "multiple atoms in multiple lines",
-			input: `
-				1
-				hoge
-				-10
-				nil
-			`,
-			expected: []token.Token{
-				{Type: token.INT, Literal: "1"},
-				{Type: token.SYMBOL, Literal: "hoge"},
-				{Type: token.MINUS, Literal: "-"},
-				{Type: token.INT, Literal: "10"},
-				{Type: token.NIL, Literal: "nil"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name: "multiple lists in multiple lines",
-			input: `
-				(+ 1 2)
-				(hoge)
-				((lambda (x) (+ x 1)) 2)
-			`,
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "hoge"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.LAMBDA, Literal: "lambda"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "x"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.SYMBOL, Literal: "x"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name: "multiple atoms and lists in multiple lines",
-			input: `
-				1
-				(+ 1 2)
-			`,
-			expected: []token.Token{
-				{Type: token.INT, Literal: "1"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := New(tt.input)
-			for i, expected := range tt.expected {
-				tok := l.NextToken()
-				if tok.Type != expected.Type {
-					t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
-						i, expected.Type, tok.Type)
-				}
-
-				if tok.Literal != expected.Literal {
-					t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
-						i, expected.Literal, tok.Literal)
-				}
-			}
-		})
-	}
-}
-
-func TestAtom(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected []token.Token
-	}{
-		{
-			name:  "integer",
-			input: "123",
-			expected: []token.Token{
-				{Type: token.INT, Literal: "123"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "prefix + integer",
-			input: "+123",
-			expected: []token.Token{
-				{Type: token.PLUS, Literal: "+"},
-				{Type: token.INT, Literal: "123"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "prefix - integer",
-			input: "-123",
-			expected: []token.Token{
-				{Type: token.MINUS, Literal: "-"},
-				{Type: token.INT, Literal: "123"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "T",
-			input: "t",
-			expected: []token.Token{
-				{Type: token.TRUE, Literal: "t"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		l := New(tt.input)
-		for i, expected := range tt.expected {
-			tok := l.NextToken()
-			if tok.Type != expected.Type {
-				t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
-					i, expected.Type, tok.Type)
-			}
-
-			if tok.Literal != expected.Literal {
-				t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
-					i, expected.Literal, tok.Literal)
-			}
-		}
-	}
-}
-
-func TestList(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected []token.Token
-	}{
-		{
-			name:  "empty list",
-			input: "()",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "list with integer",
-			input: "(123)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "123"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "dotted pair",
-			input: "(+ . (1 . (2 . nil)))",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.DOT, Literal: "."},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.DOT, Literal: "."},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.DOT, Literal: "."},
-				{Type: token.NIL, Literal: "nil"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "plus",
-			input: "(+ 1 2)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "minus",
-			input: "(- 3 4)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "-"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.INT, Literal: "4"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "multiply",
-			input: "(* 5 6)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "*"},
-				{Type: token.INT, Literal: "5"},
-				{Type: token.INT, Literal: "6"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "divide",
-			input: "(/ 7 8)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "/"},
-				{Type: token.INT, Literal: "7"},
-				{Type: token.INT, Literal: "8"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "equal",
-			input: "(= 9 10)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "="},
-				{Type: token.INT, Literal: "9"},
-				{Type: token.INT, Literal: "10"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "less than",
-			input: "(< 11 12)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "<"},
-				{Type: token.INT, Literal: "11"},
-				{Type: token.INT, Literal: "12"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "greater than",
-			input: "(> 13 14)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: ">"},
-				{Type: token.INT, Literal: "13"},
-				{Type: token.INT, Literal: "14"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "less than or equal",
-			input: "(<= 15 16)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "<="},
-				{Type: token.INT, Literal: "15"},
-				{Type: token.INT, Literal: "16"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "greater than or equal",
-			input: "(>= 17 18)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: ">="},
-				{Type: token.INT, Literal: "17"},
-				{Type: token.INT, Literal: "18"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "not equal",
-			input: "(/= 19 20)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "/="},
-				{Type: token.INT, Literal: "19"},
-				{Type: token.INT, Literal: "20"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "list with prefix +",
-			input: "+(+ 1 2)",
-			expected: []token.Token{
-				{Type: token.PLUS, Literal: "+"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "list with prefix -",
-			input: "-(- 3 4)",
-			expected: []token.Token{
-				{Type: token.MINUS, Literal: "-"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "-"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.INT, Literal: "4"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "lambda",
-			input: "(lambda (x) (+ x 1))",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.LAMBDA, Literal: "lambda"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "x"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.SYMBOL, Literal: "x"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "defmacro",
-			input: "(defmacro unless (cond body) `(if (not ,cond) ,body nil))",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "defmacro"},
-				{Type: token.SYMBOL, Literal: "unless"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "cond"},
-				{Type: token.SYMBOL, Literal: "body"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.BACKQUOTE, Literal: "`"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.IF, Literal: "if"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "not"},
-				{Type: token.COMMA, Literal: ","},
-				{Type: token.SYMBOL, Literal: "cond"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.COMMA, Literal: ","},
-				{Type: token.SYMBOL, Literal: "body"},
-				{Type: token.NIL, Literal: "nil"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "quote",
-			input: "'(1 2 3)",
-			expected: []token.Token{
-				{Type: token.QUOTE, Literal: "'"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "quote(symbol)",
-			input: "(quote 1 2 3)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.QUOTE, Literal: "quote"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "backquote",
-			input: "`(1 2 3)",
-			expected: []token.Token{
-				{Type: token.BACKQUOTE, Literal: "`"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "comma in backquotted list",
-			input: "`(1 2 ,(+ 1 2))",
-			expected: []token.Token{
-				{Type: token.BACKQUOTE, Literal: "`"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.COMMA, Literal: ","},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "+"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "if expression",
-			input: "(if (= 1 2) 3 4)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.IF, Literal: "if"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "="},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.INT, Literal: "4"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "setq special form",
-			input: "(setq x 1)",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SETQ, Literal: "setq"},
-				{Type: token.SYMBOL, Literal: "x"},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-		{
-			name:  "apply function",
-			input: "(apply f '(1 2 3))",
-			expected: []token.Token{
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.SYMBOL, Literal: "apply"},
-				{Type: token.SYMBOL, Literal: "f"},
-				{Type: token.QUOTE, Literal: "'"},
-				{Type: token.LPAREN, Literal: "("},
-				{Type: token.INT, Literal: "1"},
-				{Type: token.INT, Literal: "2"},
-				{Type: token.INT, Literal: "3"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.RPAREN, Literal: ")"},
-				{Type: token.EOF, Literal: ""},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		l := New(tt.input)
-		for i, expected := range tt.expected {
-			tok := l.NextToken()
-			if tok.Type != expected.Type {
-				t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
-					i, expected.Type, tok.Type)
-			}
-
-			if tok.Literal != expected.Literal {
-				t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
-					i, expected.Literal, tok.Literal)
-			}
-		}
-	}
-}
+package lexer
+
+import (
+	"testing"
+
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token.Token
+	}{
+		{
+			name: "multiple atoms in multiple lines",
+			input: `
+				1
+				hoge
+				-10
+				nil
+			`,
+			expected: []token.Token{
+				{Type: token.INT, Literal: "1"},
+				{Type: token.SYMBOL, Literal: "hoge"},
+				{Type: token.INT, Literal: "-10"},
+				{Type: token.NIL, Literal: "nil"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name: "multiple lists in multiple lines",
+			input: `
+				(+ 1 2)
+				(hoge)
+				((lambda (x) (+ x 1)) 2)
+			`,
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "hoge"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.LAMBDA, Literal: "lambda"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name: "multiple atoms and lists in multiple lines",
+			input: `
+				1
+				(+ 1 2)
+			`,
+			expected: []token.Token{
+				{Type: token.INT, Literal: "1"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			for i, expected := range tt.expected {
+				tok := l.NextToken()
+				if tok.Type != expected.Type {
+					t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+						i, expected.Type, tok.Type)
+				}
+
+				if tok.Literal != expected.Literal {
+					t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+						i, expected.Literal, tok.Literal)
+				}
+			}
+		})
+	}
+}
+
+func TestAtom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token.Token
+	}{
+		{
+			name:  "integer",
+			input: "123",
+			expected: []token.Token{
+				{Type: token.INT, Literal: "123"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "signed + integer",
+			input: "+123",
+			expected: []token.Token{
+				{Type: token.INT, Literal: "+123"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "signed - integer",
+			input: "-123",
+			expected: []token.Token{
+				{Type: token.INT, Literal: "-123"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "T",
+			input: "t",
+			expected: []token.Token{
+				{Type: token.TRUE, Literal: "t"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "float",
+			input: "3.14",
+			expected: []token.Token{
+				{Type: token.FLOAT, Literal: "3.14"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "float with exponent",
+			input: "1e10 1.5e-3 2E+2",
+			expected: []token.Token{
+				{Type: token.FLOAT, Literal: "1e10"},
+				{Type: token.FLOAT, Literal: "1.5e-3"},
+				{Type: token.FLOAT, Literal: "2E+2"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "float with exponent markers",
+			input: "1.5d0 1.5f0 2s-1 3L2 1.5x0",
+			expected: []token.Token{
+				{Type: token.FLOAT, Literal: "1.5d0"},
+				{Type: token.FLOAT, Literal: "1.5f0"},
+				{Type: token.FLOAT, Literal: "2s-1"},
+				{Type: token.FLOAT, Literal: "3L2"},
+				{Type: token.SYMBOL, Literal: "1.5x0"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "ratio",
+			input: "2/3",
+			expected: []token.Token{
+				{Type: token.RATIO, Literal: "2/3"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "signed - float",
+			input: "-0.5",
+			expected: []token.Token{
+				{Type: token.FLOAT, Literal: "-0.5"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "string",
+			input: `"hello world"`,
+			expected: []token.Token{
+				{Type: token.STRING, Literal: "hello world"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "string with escape sequences",
+			input: `"say \"hi\"\n\tback\\slash \u00e9"`,
+			expected: []token.Token{
+				{Type: token.STRING, Literal: "say \"hi\"\n\tback\\slash é"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "unterminated string",
+			input: `"hello`,
+			expected: []token.Token{
+				{Type: token.ILLEGAL, Literal: "hello"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "invalid unicode escape",
+			input: `"a\uzz" b`,
+			expected: []token.Token{
+				{Type: token.ILLEGAL, Literal: "azz"},
+				{Type: token.SYMBOL, Literal: "b"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "function shorthand",
+			input: "#'car",
+			expected: []token.Token{
+				{Type: token.FUNCTION, Literal: "#'"},
+				{Type: token.SYMBOL, Literal: "car"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "symbol with hyphen",
+			input: "string-upcase",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "string-upcase"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "symbol with digits",
+			input: "prog1",
+			expected: []token.Token{
+				{Type: token.PROG1, Literal: "prog1"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "lambda list keyword",
+			input: "&optional",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "&optional"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "keyword",
+			input: ":allow-other-keys",
+			expected: []token.Token{
+				{Type: token.KEYWORD, Literal: ":allow-other-keys"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type {
+				t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+					i, expected.Type, tok.Type)
+			}
+
+			if tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+					i, expected.Literal, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token.Token
+	}{
+		{
+			name:  "empty list",
+			input: "()",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "list with integer",
+			input: "(123)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "123"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "dotted pair of integers",
+			input: "(1 . 2)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "dotted pair",
+			input: "(+ . (1 . (2 . nil)))",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.NIL, Literal: "nil"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "plus",
+			input: "(+ 1 2)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "minus",
+			input: "(- 3 4)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "-"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.INT, Literal: "4"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "multiply",
+			input: "(* 5 6)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "*"},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.INT, Literal: "6"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "divide",
+			input: "(/ 7 8)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "/"},
+				{Type: token.INT, Literal: "7"},
+				{Type: token.INT, Literal: "8"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "equal",
+			input: "(= 9 10)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "="},
+				{Type: token.INT, Literal: "9"},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "less than",
+			input: "(< 11 12)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "<"},
+				{Type: token.INT, Literal: "11"},
+				{Type: token.INT, Literal: "12"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "greater than",
+			input: "(> 13 14)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: ">"},
+				{Type: token.INT, Literal: "13"},
+				{Type: token.INT, Literal: "14"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "less than or equal",
+			input: "(<= 15 16)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "<="},
+				{Type: token.INT, Literal: "15"},
+				{Type: token.INT, Literal: "16"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "greater than or equal",
+			input: "(>= 17 18)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: ">="},
+				{Type: token.INT, Literal: "17"},
+				{Type: token.INT, Literal: "18"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "not equal",
+			input: "(/= 19 20)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "/="},
+				{Type: token.INT, Literal: "19"},
+				{Type: token.INT, Literal: "20"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "symbol + before list",
+			input: "+(+ 1 2)",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "symbol - before list",
+			input: "-(- 3 4)",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "-"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "-"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.INT, Literal: "4"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "lambda",
+			input: "(lambda (x) (+ x 1))",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.LAMBDA, Literal: "lambda"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "defmacro",
+			input: "(defmacro unless (cond body) `(if (not ,cond) ,body nil))",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "defmacro"},
+				{Type: token.UNLESS, Literal: "unless"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.COND, Literal: "cond"},
+				{Type: token.SYMBOL, Literal: "body"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.BACKQUOTE, Literal: "`"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IF, Literal: "if"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "not"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.COND, Literal: "cond"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.SYMBOL, Literal: "body"},
+				{Type: token.NIL, Literal: "nil"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "comma at",
+			input: "`(progn ,@body , @x)",
+			expected: []token.Token{
+				{Type: token.BACKQUOTE, Literal: "`"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.PROGN, Literal: "progn"},
+				{Type: token.COMMA_AT, Literal: ",@"},
+				{Type: token.SYMBOL, Literal: "body"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.SYMBOL, Literal: "@x"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "quote",
+			input: "'(1 2 3)",
+			expected: []token.Token{
+				{Type: token.QUOTE, Literal: "'"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "quote(symbol)",
+			input: "(quote 1 2 3)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.QUOTE, Literal: "quote"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "backquote",
+			input: "`(1 2 3)",
+			expected: []token.Token{
+				{Type: token.BACKQUOTE, Literal: "`"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "comma in backquotted list",
+			input: "`(1 2 ,(+ 1 2))",
+			expected: []token.Token{
+				{Type: token.BACKQUOTE, Literal: "`"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "+"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "if expression",
+			input: "(if (= 1 2) 3 4)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IF, Literal: "if"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "="},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.INT, Literal: "4"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "setq special form",
+			input: "(setq x 1)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SETQ, Literal: "setq"},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "apply function",
+			input: "(apply f '(1 2 3))",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "apply"},
+				{Type: token.SYMBOL, Literal: "f"},
+				{Type: token.QUOTE, Literal: "'"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type {
+				t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+					i, expected.Type, tok.Type)
+			}
+
+			if tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+					i, expected.Literal, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, input := range []string{"", "   "} {
+		l := New(input)
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("wrong token for %q. got=%q, want=EOF", input, tok.Type)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "(car\n\t'(\"é\" x))\r\n1"
+
+	expected := []struct {
+		literal string
+		pos     string
+		end     string
+	}{
+		{"(", "test.lisp:1:1", "test.lisp:1:2"},
+		{"car", "test.lisp:1:2", "test.lisp:1:5"},
+		{"'", "test.lisp:2:2", "test.lisp:2:3"},
+		{"(", "test.lisp:2:3", "test.lisp:2:4"},
+		{"é", "test.lisp:2:4", "test.lisp:2:7"},
+		{"x", "test.lisp:2:8", "test.lisp:2:9"},
+		{")", "test.lisp:2:9", "test.lisp:2:10"},
+		{")", "test.lisp:2:10", "test.lisp:2:11"},
+		{"1", "test.lisp:3:1", "test.lisp:3:2"},
+		{"", "test.lisp:3:2", "test.lisp:3:2"},
+	}
+
+	l := NewFile("test.lisp", input)
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Literal != exp.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, exp.literal, tok.Literal)
+		}
+		if tok.Pos.String() != exp.pos || tok.End.String() != exp.end {
+			t.Fatalf("tests[%d] - position wrong. expected=%s-%s, got=%s-%s",
+				i, exp.pos, exp.end, tok.Pos, tok.End)
+		}
+	}
+
+	if line := l.Source().Line(2); line != "\t'(\"é\" x))" {
+		t.Fatalf("source line wrong. got=%q", line)
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token.Token
+	}{
+		{
+			name:  "line comment",
+			input: "; square\n(f x) ; call f\r\n",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "(", Comments: []token.Comment{{Text: "; square"}}},
+				{Type: token.SYMBOL, Literal: "f"},
+				{Type: token.SYMBOL, Literal: "x"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: "", Comments: []token.Comment{{Text: "; call f"}}},
+			},
+		},
+		{
+			name:  "nested block comment",
+			input: "a #| outer #| inner |# (still comment |# b",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "a"},
+				{Type: token.SYMBOL, Literal: "b", Comments: []token.Comment{{Text: "#| outer #| inner |# (still comment |#"}}},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "datum comment",
+			input: "(a #;(b (c)) #;'d #;-1 e)",
+			expected: []token.Token{
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.SYMBOL, Literal: "a"},
+				{Type: token.SYMBOL, Literal: "e", Comments: []token.Comment{{Text: "#;(b (c))"}, {Text: "#;'d"}, {Text: "#;-1"}}},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			name:  "unterminated block comment",
+			input: "a #| b",
+			expected: []token.Token{
+				{Type: token.SYMBOL, Literal: "a"},
+				{Type: token.ILLEGAL, Literal: "#| b"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.input)
+			for i, expected := range tt.expected {
+				tok := l.NextToken()
+				if tok.Type != expected.Type || tok.Literal != expected.Literal {
+					t.Fatalf("tests[%d] - token wrong. expected=%s %q, got=%s %q",
+						i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+				}
+				if len(tok.Comments) != len(expected.Comments) {
+					t.Fatalf("tests[%d] - wrong number of comments. expected=%d, got=%d",
+						i, len(expected.Comments), len(tok.Comments))
+				}
+				for j, comment := range tok.Comments {
+					if comment.Text != expected.Comments[j].Text {
+						t.Errorf("tests[%d] - comments[%d] wrong. expected=%q, got=%q",
+							i, j, expected.Comments[j].Text, comment.Text)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSymbolSyntax(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected token.Token
+	}{
+		{"list->vector", token.Token{Type: token.SYMBOL, Literal: "list->vector"}},
+		{"1+", token.Token{Type: token.SYMBOL, Literal: "1+"}},
+		{"1-", token.Token{Type: token.SYMBOL, Literal: "1-"}},
+		{"+1", token.Token{Type: token.INT, Literal: "+1"}},
+		{"-x", token.Token{Type: token.SYMBOL, Literal: "-x"}},
+		{"string-upcase", token.Token{Type: token.SYMBOL, Literal: "string-upcase"}},
+		{"my_var", token.Token{Type: token.SYMBOL, Literal: "my_var"}},
+		{"foo?", token.Token{Type: token.SYMBOL, Literal: "foo?"}},
+		{"%internal", token.Token{Type: token.SYMBOL, Literal: "%internal"}},
+		{"a2", token.Token{Type: token.SYMBOL, Literal: "a2"}},
+		{"a#b", token.Token{Type: token.SYMBOL, Literal: "a#b"}},
+		{"1/2/3", token.Token{Type: token.SYMBOL, Literal: "1/2/3"}},
+		{"1.5.2", token.Token{Type: token.SYMBOL, Literal: "1.5.2"}},
+		{"1.", token.Token{Type: token.INT, Literal: "1."}},
+		{"-12.", token.Token{Type: token.INT, Literal: "-12."}},
+		{"1..", token.Token{Type: token.SYMBOL, Literal: "1.."}},
+		{"λ", token.Token{Type: token.SYMBOL, Literal: "λ"}},
+		{"変数", token.Token{Type: token.SYMBOL, Literal: "変数"}},
+		{"|hello world|", token.Token{Type: token.SYMBOL, Literal: "hello world"}},
+		{"|a\\|b|", token.Token{Type: token.SYMBOL, Literal: "a|b"}},
+		{"a\\ b", token.Token{Type: token.SYMBOL, Literal: "a b"}},
+		{"ab|(c)|d", token.Token{Type: token.SYMBOL, Literal: "ab(c)d"}},
+		{"Foo", token.Token{Type: token.SYMBOL, Literal: "foo"}},
+		{"LIST->VECTOR", token.Token{Type: token.SYMBOL, Literal: "list->vector"}},
+		{"ΛX", token.Token{Type: token.SYMBOL, Literal: "λx"}},
+		{"|Foo|", token.Token{Type: token.SYMBOL, Literal: "Foo"}},
+		{"Ab|Cd|Ef", token.Token{Type: token.SYMBOL, Literal: "abCdef"}},
+		{"A\\Bc", token.Token{Type: token.SYMBOL, Literal: "aBc"}},
+		{"IF", token.Token{Type: token.IF, Literal: "if"}},
+		{":Test", token.Token{Type: token.KEYWORD, Literal: ":test"}},
+		{"|123|", token.Token{Type: token.SYMBOL, Literal: "123"}},
+		{"|if|", token.Token{Type: token.SYMBOL, Literal: "if"}},
+		{"|abc", token.Token{Type: token.ILLEGAL, Literal: "|abc"}},
+		{":test", token.Token{Type: token.KEYWORD, Literal: ":test"}},
+		{":1+", token.Token{Type: token.KEYWORD, Literal: ":1+"}},
+		{"|:test|", token.Token{Type: token.SYMBOL, Literal: ":test"}},
+		{":|a b|", token.Token{Type: token.KEYWORD, Literal: ":a b"}},
+		{":|A|", token.Token{Type: token.KEYWORD, Literal: ":A"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expected.Type || tok.Literal != tt.expected.Literal {
+			t.Errorf("input=%q: expected=%s %q, got=%s %q",
+				tt.input, tt.expected.Type, tt.expected.Literal, tok.Type, tok.Literal)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("input=%q: expected EOF, got=%s %q", tt.input, tok.Type, tok.Literal)
+		}
+	}
+}
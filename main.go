@@ -1,19 +1,76 @@
-package main
-
-import (
-	"fmt"
-	"os"
-	"os/user"
-
-	"github.com/JunNishimura/go-lisp/repl"
-)
-
-func main() {
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("Hello %s! This is the tiny Lisp interpreter written in Golang!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
-}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/JunNishimura/go-lisp/repl"
+)
+
+func main() {
+	backend := flag.String("backend", string(repl.BackendEval), "backend to run the program: eval or vm")
+	expr := flag.String("e", "", "evaluate the expressions and print the value of the last one")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: go-lisp [flags] [file [args...]]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "without file, the program is read from stdin if it is not a terminal.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "the arguments after file, or after the flags with -e, are bound to %s.\n\n", repl.CommandLineArgs)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *backend != string(repl.BackendEval) && *backend != string(repl.BackendVM) {
+		fmt.Fprintf(os.Stderr, "unknown backend: %s\n", *backend)
+		os.Exit(2)
+	}
+
+	script := &repl.Script{Backend: repl.Backend(*backend)}
+	switch {
+	case isFlagSet("e"):
+		script.Name = "-e"
+		script.Source = *expr
+		script.Args = flag.Args()
+		script.PrintResult = true
+	case flag.NArg() > 0:
+		source, err := os.ReadFile(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(repl.ExitError)
+		}
+		script.Name = flag.Arg(0)
+		script.Source = string(source)
+		script.Args = flag.Args()[1:]
+	case !repl.IsTerminal(os.Stdin):
+		// the piped program runs silently without the greeting and the prompts
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(repl.ExitError)
+		}
+		script.Name = "<stdin>"
+		script.Source = string(source)
+	default:
+		user, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Hello %s! This is the tiny Lisp interpreter written in Golang!\n", user.Username)
+		fmt.Printf("Feel free to type in commands\n")
+		repl.Start(os.Stdin, os.Stdout, repl.Backend(*backend))
+		return
+	}
+
+	os.Exit(script.Run(os.Stdout, os.Stderr))
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
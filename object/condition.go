@@ -0,0 +1,72 @@
+package object
+
+import (
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+)
+
+// ConditionType is the type of conditions defined by define-condition
+type ConditionType struct {
+	Name    string
+	Parents []*ConditionType
+	Slots   []*SlotDefinition
+	// Report is the string or the function which describes the condition. it is nil if not specified.
+	Report Object
+	// Env is the environment in which the initforms of the slots are evaluated
+	Env *Environment
+}
+
+// IsSubtypeOf reports whether the type is the named type or inherits from it
+func (ct *ConditionType) IsSubtypeOf(name string) bool {
+	if strings.EqualFold(ct.Name, name) {
+		return true
+	}
+
+	for _, parent := range ct.Parents {
+		if parent.IsSubtypeOf(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SlotDefinition is the slot specifier of define-condition
+type SlotDefinition struct {
+	Name string
+	// Initarg is the keyword name without the leading colon which initializes the slot
+	Initarg  string
+	Initform ast.SExpression
+}
+
+type Condition struct {
+	ConditionType *ConditionType
+	// Slots are keyed by the lowercase slot names
+	Slots map[string]Object
+	// Message is the report of the condition which has no report function, such as a simple error
+	Message string
+}
+
+func (c *Condition) Type() ObjectType { return CONDITION_OBJ }
+func (c *Condition) Inspect() string  { return "#<condition " + c.ConditionType.Name + ">" }
+
+// Handler is a condition handler established by handler-bind, handler-case or ignore-errors
+type Handler struct {
+	// TypeName is the condition type which the handler handles
+	TypeName string
+	// Function is called with the condition when a condition of the type is signaled
+	Function Object
+}
+
+// Restart is established by restart-case, and transfers the control back to it when invoked
+type Restart struct {
+	Name string
+	// Point is the exit point of the form which established the restart
+	Point *ExitPoint
+	// Index identifies the clause of the restart-case
+	Index int
+}
+
+func (r *Restart) Type() ObjectType { return RESTART_OBJ }
+func (r *Restart) Inspect() string  { return "#<restart " + r.Name + ">" }
@@ -1,40 +1,413 @@
-package object
-
-import "strings"
-
-// all the keys in the environment are case-insensitive
-type envKey string
-
-func toEnvKey(key string) envKey {
-	// env keys are uppercase
-	return envKey(strings.ToUpper(key))
-}
-
-func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
-}
-
-type Environment struct {
-	store map[envKey]Object
-	outer *Environment
-}
-
-func NewEnvironment() *Environment {
-	return &Environment{store: make(map[envKey]Object)}
-}
-
-func (e *Environment) Get(key string) (Object, bool) {
-	obj, ok := e.store[toEnvKey(key)]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(key)
-	}
-
-	return obj, ok
-}
-
-func (e *Environment) Set(key string, value Object) Object {
-	e.store[toEnvKey(key)] = value
-	return value
-}
+package object
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// all the keys in the environment are case-insensitive
+type envKey string
+
+func toEnvKey(key string) envKey {
+	// env keys are uppercase
+	return envKey(strings.ToUpper(key))
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{
+		store: make(map[envKey]Object),
+		outer: outer,
+	}
+}
+
+type Environment struct {
+	store map[envKey]Object
+	outer *Environment
+
+	// functions holds the local functions defined by flet and labels.
+	// it is nil unless this environment is created by them.
+	functions map[envKey]*Function
+
+	// macroDefinitions holds the macros defined by defmacro in the macro environment,
+	// or the local macros defined by macrolet in the environment enclosed by it.
+	macroDefinitions map[envKey]*Macro
+
+	// blocks and tags are the exit points of block and tagbody which are lexically visible
+	blocks map[envKey]*ExitPoint
+	tags   map[envKey]*ExitPoint
+
+	// catchTags is the stack of the active catch tags.
+	// it is dynamic, so it is only set on the outermost environment.
+	catchTags []Object
+
+	// handlers, restarts and conditionTypes are the state of the condition system.
+	// they are also only set on the outermost environment.
+	handlers       [][]*Handler
+	restarts       []*Restart
+	conditionTypes map[envKey]*ConditionType
+
+	// symbols is the global symbol table shared by all the environments enclosed by this one.
+	// it is only set on the outermost environment.
+	symbols *symbolTable
+
+	// macros is the environment which holds the macros for the programs run in this environment.
+	// modules and loading are the names of the provided modules and the stack of the files being loaded.
+	// they are also only set on the outermost environment.
+	macros  *Environment
+	modules map[string]bool
+	loading []string
+
+	// depth is the number of the nested evaluations, which is limited so that the deep recursion is reported.
+	// expansionDepth is the number of the nested macro expansions in progress.
+	// output is where the programs print their output, or os.Stdout if it is nil.
+	// they are also only set on the outermost environment.
+	depth          int
+	expansionDepth int
+	output         io.Writer
+}
+
+// symbolTable holds the interned symbols and keywords, whose names may be the same.
+// unlike the other keys, the names are case-sensitive, because the reader has already folded the case
+// of the symbols written without escapes, and |Foo| and (intern "Foo") name the symbol exactly.
+// gensymCounter is the number appended to the name of the next symbol made by gensym.
+type symbolTable struct {
+	symbols       map[string]*Symbol
+	keywords      map[string]*Symbol
+	gensymCounter int
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{
+		store: make(map[envKey]Object),
+		symbols: &symbolTable{
+			symbols:  make(map[string]*Symbol),
+			keywords: make(map[string]*Symbol),
+		},
+	}
+}
+
+func (e *Environment) Get(key string) (Object, bool) {
+	envKey := toEnvKey(key)
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[envKey]; ok {
+			return obj, true
+		}
+	}
+
+	return nil, false
+}
+
+func (e *Environment) Set(key string, value Object) Object {
+	e.store[toEnvKey(key)] = value
+	return value
+}
+
+// Assign updates the innermost binding of key.
+// if key is not bound anywhere, it is set in the outermost environment.
+func (e *Environment) Assign(key string, value Object) Object {
+	envKey := toEnvKey(key)
+	env := e
+	for ; env.outer != nil; env = env.outer {
+		if _, ok := env.store[envKey]; ok {
+			break
+		}
+	}
+
+	env.store[envKey] = value
+	return value
+}
+
+// GetFunction returns the local function named key
+func (e *Environment) GetFunction(key string) (*Function, bool) {
+	var envKey envKey
+	for env := e; env != nil; env = env.outer {
+		if env.functions == nil {
+			continue
+		}
+		// the key is converted only when there are local functions to look up
+		if envKey == "" {
+			envKey = toEnvKey(key)
+		}
+		if fn, ok := env.functions[envKey]; ok {
+			return fn, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetFunction defines the local function named key in this environment
+func (e *Environment) SetFunction(key string, fn *Function) *Function {
+	if e.functions == nil {
+		e.functions = make(map[envKey]*Function)
+	}
+	e.functions[toEnvKey(key)] = fn
+	return fn
+}
+
+// GetMacro returns the innermost macro named key.
+// the macro defined as nil is shadowed by the local function of the same name.
+func (e *Environment) GetMacro(key string) (*Macro, bool) {
+	envKey := toEnvKey(key)
+	for env := e; env != nil; env = env.outer {
+		if macro, ok := env.macroDefinitions[envKey]; ok {
+			return macro, macro != nil
+		}
+	}
+
+	return nil, false
+}
+
+// SetMacro defines the macro named key in this environment, replacing the previous definition
+func (e *Environment) SetMacro(key string, macro *Macro) *Macro {
+	if e.macroDefinitions == nil {
+		e.macroDefinitions = make(map[envKey]*Macro)
+	}
+	e.macroDefinitions[toEnvKey(key)] = macro
+	return macro
+}
+
+// RemoveMacro removes the macro named key from this environment
+func (e *Environment) RemoveMacro(key string) {
+	delete(e.macroDefinitions, toEnvKey(key))
+}
+
+// Intern returns the symbol named name from the global symbol table,
+// creating it if it does not exist yet
+func (e *Environment) Intern(name string) *Symbol {
+	if e.outer != nil {
+		return e.outer.Intern(name)
+	}
+
+	if symbol, ok := e.symbols.symbols[name]; ok {
+		return symbol
+	}
+
+	symbol := &Symbol{Name: name}
+	e.symbols.symbols[name] = symbol
+	return symbol
+}
+
+// InternKeyword returns the keyword named name, which is written as :name, from the global symbol table,
+// creating it if it does not exist yet. the value of a keyword is the keyword itself.
+func (e *Environment) InternKeyword(name string) *Symbol {
+	keywords := e.root().symbols.keywords
+
+	if keyword, ok := keywords[name]; ok {
+		return keyword
+	}
+
+	keyword := &Symbol{Name: name, Keyword: true}
+	keyword.Value = keyword
+	keywords[name] = keyword
+	return keyword
+}
+
+// NextGensymCounter returns the counter for the name of the symbol made by gensym and increments it
+func (e *Environment) NextGensymCounter() int {
+	symbols := e.root().symbols
+	counter := symbols.gensymCounter
+	symbols.gensymCounter++
+	return counter
+}
+
+// GetBlock returns the exit point of the innermost block named key
+func (e *Environment) GetBlock(key string) (*ExitPoint, bool) {
+	envKey := toEnvKey(key)
+	for env := e; env != nil; env = env.outer {
+		if point, ok := env.blocks[envKey]; ok {
+			return point, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetBlock establishes the exit point of the block named key in this environment
+func (e *Environment) SetBlock(key string, point *ExitPoint) *ExitPoint {
+	if e.blocks == nil {
+		e.blocks = make(map[envKey]*ExitPoint)
+	}
+	e.blocks[toEnvKey(key)] = point
+	return point
+}
+
+// GetTag returns the exit point of the innermost tagbody which has the go tag key
+func (e *Environment) GetTag(key string) (*ExitPoint, bool) {
+	envKey := toEnvKey(key)
+	for env := e; env != nil; env = env.outer {
+		if point, ok := env.tags[envKey]; ok {
+			return point, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetTag makes the go tag key jump to the tagbody of the exit point
+func (e *Environment) SetTag(key string, point *ExitPoint) *ExitPoint {
+	if e.tags == nil {
+		e.tags = make(map[envKey]*ExitPoint)
+	}
+	e.tags[toEnvKey(key)] = point
+	return point
+}
+
+// PushCatchTag makes the catch tag active until PopCatchTag is called
+func (e *Environment) PushCatchTag(tag Object) {
+	root := e.root()
+	root.catchTags = append(root.catchTags, tag)
+}
+
+func (e *Environment) PopCatchTag() {
+	root := e.root()
+	root.catchTags = root.catchTags[:len(root.catchTags)-1]
+}
+
+// CatchTags returns the active catch tags from the outermost to the innermost
+func (e *Environment) CatchTags() []Object {
+	return e.root().catchTags
+}
+
+// Global returns the outermost environment, in which the global variables are bound
+func (e *Environment) Global() *Environment {
+	return e.root()
+}
+
+func (e *Environment) root() *Environment {
+	for e.outer != nil {
+		e = e.outer
+	}
+	return e
+}
+
+// PushHandlers establishes the cluster of the handlers until PopHandlers is called
+func (e *Environment) PushHandlers(cluster []*Handler) {
+	root := e.root()
+	root.handlers = append(root.handlers, cluster)
+}
+
+func (e *Environment) PopHandlers() {
+	root := e.root()
+	root.handlers = root.handlers[:len(root.handlers)-1]
+}
+
+// Handlers returns the active handler clusters from the outermost to the innermost
+func (e *Environment) Handlers() [][]*Handler {
+	return e.root().handlers
+}
+
+// SetHandlers replaces the active handler clusters.
+// it is used to run a handler with only the handlers which were active when it was established.
+func (e *Environment) SetHandlers(handlers [][]*Handler) {
+	e.root().handlers = handlers
+}
+
+// PushRestart makes the restart active until PopRestart is called
+func (e *Environment) PushRestart(restart *Restart) {
+	root := e.root()
+	root.restarts = append(root.restarts, restart)
+}
+
+func (e *Environment) PopRestart() {
+	root := e.root()
+	root.restarts = root.restarts[:len(root.restarts)-1]
+}
+
+// Restarts returns the active restarts from the outermost to the innermost
+func (e *Environment) Restarts() []*Restart {
+	return e.root().restarts
+}
+
+// GetConditionType returns the condition type defined by define-condition
+func (e *Environment) GetConditionType(name string) (*ConditionType, bool) {
+	conditionType, ok := e.root().conditionTypes[toEnvKey(name)]
+	return conditionType, ok
+}
+
+func (e *Environment) SetConditionType(name string, conditionType *ConditionType) *ConditionType {
+	root := e.root()
+	if root.conditionTypes == nil {
+		root.conditionTypes = make(map[envKey]*ConditionType)
+	}
+	root.conditionTypes[toEnvKey(name)] = conditionType
+	return conditionType
+}
+
+// MacroEnvironment returns the environment in which the macros are defined and expanded,
+// so that the files loaded into this environment share the macros.
+// it is enclosed by the global environment, so the macros can call the functions defined before them.
+func (e *Environment) MacroEnvironment() *Environment {
+	root := e.root()
+	if root.macros == nil {
+		root.macros = NewEnclosedEnvironment(root)
+	}
+	return root.macros
+}
+
+// EnterEvaluation increments the depth of the nested evaluations and returns it
+func (e *Environment) EnterEvaluation() int {
+	root := e.root()
+	root.depth++
+	return root.depth
+}
+
+// LeaveEvaluation decrements the depth of the nested evaluations
+func (e *Environment) LeaveEvaluation() {
+	e.root().depth--
+}
+
+// EnterExpansion increments the depth of the nested macro expansions and returns it
+func (e *Environment) EnterExpansion() int {
+	root := e.root()
+	root.expansionDepth++
+	return root.expansionDepth
+}
+
+// LeaveExpansion decrements the depth of the nested macro expansions
+func (e *Environment) LeaveExpansion() {
+	e.root().expansionDepth--
+}
+
+// Output returns where the programs run in this environment print their output
+func (e *Environment) Output() io.Writer {
+	if output := e.root().output; output != nil {
+		return output
+	}
+	return os.Stdout
+}
+
+// SetOutput changes where the programs run in this environment print their output
+func (e *Environment) SetOutput(w io.Writer) {
+	e.root().output = w
+}
+
+// Provide records that the module has been loaded
+func (e *Environment) Provide(module string) {
+	root := e.root()
+	if root.modules == nil {
+		root.modules = make(map[string]bool)
+	}
+	root.modules[module] = true
+}
+
+func (e *Environment) IsProvided(module string) bool {
+	return e.root().modules[module]
+}
+
+// PushLoading records the file being loaded until PopLoading is called
+func (e *Environment) PushLoading(path string) {
+	root := e.root()
+	root.loading = append(root.loading, path)
+}
+
+func (e *Environment) PopLoading() {
+	root := e.root()
+	root.loading = root.loading[:len(root.loading)-1]
+}
+
+// Loading returns the files being loaded from the outermost to the innermost
+func (e *Environment) Loading() []string {
+	return e.root().loading
+}
@@ -0,0 +1,114 @@
+package object
+
+import (
+	"bytes"
+
+	"github.com/JunNishimura/go-lisp/ast"
+)
+
+// Parameter is a variable in a lambda list
+type Parameter struct {
+	// Symbol is the name of the variable. it is nil when Pattern is used instead.
+	Symbol *ast.Symbol
+	// Pattern destructures the argument. only macro lambda lists allow it.
+	Pattern *LambdaList
+	// Default is evaluated when the argument is not supplied (&optional, &key and &aux)
+	Default ast.SExpression
+	// SuppliedP is bound to T or NIL depending on whether the argument is supplied (&optional and &key)
+	SuppliedP *ast.Symbol
+	// Keyword is the name of the keyword argument without the leading colon (&key)
+	Keyword string
+}
+
+func (p *Parameter) String() string {
+	if p.Pattern != nil {
+		return p.Pattern.String()
+	}
+	return p.Symbol.String()
+}
+
+// LambdaList is the parsed parameter list of a function or a macro
+type LambdaList struct {
+	// Parameters are the required parameters
+	Parameters []*Parameter
+	Optional   []*Parameter
+	// Rest is the parameter after &rest or &body
+	Rest        *Parameter
+	RestKeyword string
+	// HasKeys is true if &key appears even if no keyword parameter follows
+	HasKeys        bool
+	Keys           []*Parameter
+	AllowOtherKeys bool
+	Aux            []*Parameter
+}
+
+func (ll *LambdaList) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, p := range ll.Parameters {
+		elements = append(elements, p.String())
+	}
+
+	if len(ll.Optional) > 0 {
+		elements = append(elements, "&optional")
+		for _, p := range ll.Optional {
+			elements = append(elements, formatParameter(p.String(), p))
+		}
+	}
+
+	if ll.Rest != nil {
+		elements = append(elements, ll.RestKeyword, ll.Rest.String())
+	}
+
+	if ll.HasKeys {
+		elements = append(elements, "&key")
+		for _, p := range ll.Keys {
+			name := p.String()
+			if p.Keyword != p.Symbol.Value {
+				name = "(:" + p.Keyword + " " + name + ")"
+			}
+			elements = append(elements, formatParameter(name, p))
+		}
+	}
+
+	if ll.AllowOtherKeys {
+		elements = append(elements, "&allow-other-keys")
+	}
+
+	if len(ll.Aux) > 0 {
+		elements = append(elements, "&aux")
+		for _, p := range ll.Aux {
+			elements = append(elements, formatParameter(p.String(), p))
+		}
+	}
+
+	out.WriteString("(")
+	for i, e := range elements {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(e)
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// formatParameter prints the parameter with its default value and supplied-p variable if any
+func formatParameter(name string, p *Parameter) string {
+	if p.Default == nil && p.SuppliedP == nil {
+		return name
+	}
+
+	out := "(" + name
+	if p.Default != nil {
+		out += " " + p.Default.String()
+	} else {
+		out += " nil"
+	}
+	if p.SuppliedP != nil {
+		out += " " + p.SuppliedP.String()
+	}
+	return out + ")"
+}
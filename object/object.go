@@ -1,157 +1,348 @@
-package object
-
-import (
-	"bytes"
-	"fmt"
-
-	"github.com/JunNishimura/go-lisp/ast"
-)
-
-const (
-	ERROR_OBJ    = "ERROR"
-	NIL_OBJ      = "NIL"
-	TRUE_OBJ     = "TRUE"
-	INTEGER_OBJ  = "INTEGER"
-	FUNCTION_OBJ = "FUNCTION"
-	SYMBOL_OBJ   = "SYMBOL"
-	BUILTIN_OBJ  = "BUILTIN"
-	QOUTE_OBJ    = "QUOTE"
-	MACRO_OBJ    = "MACRO"
-	CONSCELL_OBJ = "CONSCELL"
-	LIST_OBJ     = "LIST"
-)
-
-type BuiltInFunction func(env *Environment, args ...Object) Object
-
-type ObjectType string
-
-type Object interface {
-	Type() ObjectType
-	Inspect() string
-}
-
-type Error struct {
-	Message string
-}
-
-func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
-
-type True struct{}
-
-func (t *True) Type() ObjectType { return TRUE_OBJ }
-func (t *True) Inspect() string  { return "T" }
-
-type Nil struct{}
-
-func (n *Nil) Type() ObjectType { return NIL_OBJ }
-func (n *Nil) Inspect() string  { return "nil" }
-
-type Integer struct {
-	Value int64
-}
-
-func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
-func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
-
-type Symbol struct {
-	Name         string
-	Value        Object
-	PropertyList []Object
-	Function     *Function
-}
-
-func (s *Symbol) Type() ObjectType { return SYMBOL_OBJ }
-func (s *Symbol) Inspect() string  { return s.Value.Inspect() }
-
-type Function struct {
-	Parameters []*ast.Symbol
-	Body       ast.SExpression
-	Env        *Environment
-}
-
-func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
-func (f *Function) Inspect() string {
-	var out bytes.Buffer
-
-	out.WriteString("(lambda (")
-	for i, p := range f.Parameters {
-		if i > 0 {
-			out.WriteString(" ")
-		}
-		out.WriteString(p.String())
-	}
-	out.WriteString(") ")
-	out.WriteString(f.Body.String())
-	out.WriteString(")")
-
-	return out.String()
-}
-
-type Builtin struct {
-	Fn BuiltInFunction
-}
-
-func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
-func (b *Builtin) Inspect() string  { return "builtin function" }
-
-type Quote struct {
-	SExpression ast.SExpression
-}
-
-func (q *Quote) Type() ObjectType { return QOUTE_OBJ }
-func (q *Quote) Inspect() string  { return q.SExpression.String() }
-
-type Macro struct {
-	Parameters []*ast.Symbol
-	Body       ast.SExpression
-	Env        *Environment
-}
-
-func (m *Macro) Type() ObjectType { return MACRO_OBJ }
-func (m *Macro) Inspect() string {
-	var out bytes.Buffer
-
-	out.WriteString("(macro (")
-	for i, p := range m.Parameters {
-		if i > 0 {
-			out.WriteString(" ")
-		}
-		out.WriteString(p.String())
-	}
-	out.WriteString(") ")
-	out.WriteString(m.Body.String())
-	out.WriteString(")")
-
-	return out.String()
-}
-
-type ConsCell struct {
-	Car Object
-	Cdr Object
-}
-
-func (cc *ConsCell) Type() ObjectType { return CONSCELL_OBJ }
-func (cc *ConsCell) Inspect() string {
-	return fmt.Sprintf("(%s . %s)", cc.Car.Inspect(), cc.Cdr.Inspect())
-}
-
-type List struct {
-	SExpressions []Object
-}
-
-func (l *List) Type() ObjectType { return LIST_OBJ }
-func (l *List) Inspect() string {
-	var out bytes.Buffer
-
-	out.WriteString("(")
-	for i, s := range l.SExpressions {
-		if i > 0 {
-			out.WriteString(" ")
-		}
-		out.WriteString(s.Inspect())
-	}
-	out.WriteString(")")
-
-	return out.String()
-}
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+const (
+	ERROR_OBJ     = "ERROR"
+	EXIT_OBJ      = "NON_LOCAL_EXIT"
+	NIL_OBJ       = "NIL"
+	TRUE_OBJ      = "TRUE"
+	INTEGER_OBJ   = "INTEGER"
+	BIGNUM_OBJ    = "BIGNUM"
+	FLOAT_OBJ     = "FLOAT"
+	RATIO_OBJ     = "RATIO"
+	STRING_OBJ    = "STRING"
+	CHARACTER_OBJ = "CHARACTER"
+	FUNCTION_OBJ  = "FUNCTION"
+	COMPILED_OBJ  = "COMPILED_FUNCTION"
+	CELL_OBJ      = "CELL"
+	SYMBOL_OBJ    = "SYMBOL"
+	BUILTIN_OBJ   = "BUILTIN"
+	MACRO_OBJ     = "MACRO"
+	CONDITION_OBJ = "CONDITION"
+	RESTART_OBJ   = "RESTART"
+	CONSCELL_OBJ  = "CONSCELL"
+	LIST_OBJ      = "LIST"
+)
+
+type BuiltInFunction func(env *Environment, args ...Object) Object
+
+type ObjectType string
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Error struct {
+	Message string
+	// Condition is the condition object which describes the error.
+	// it is created when the error is signaled if it is nil.
+	Condition *Condition
+	// Signaled is true once the handlers have been given a chance to handle the error
+	Signaled bool
+	// Pos is the position of the innermost form whose evaluation failed.
+	// it is not set if the error is not raised by evaluating a form read from the source.
+	Pos token.Position
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// Report describes the error with its position and the source line pointing to it
+func (e *Error) Report() string { return e.Pos.Annotate(e.Inspect()) }
+
+// ExitPoint is the destination of the non-local exit established by block or tagbody.
+// it is compared by identity, so every evaluation of the form creates a new one.
+type ExitPoint struct {
+	Name string
+	// Active is false once the form which established the exit point has returned
+	Active bool
+}
+
+// NonLocalExit is returned instead of a value while the control is transferred
+// by return-from, go or throw. it is propagated like Error until the destination is reached,
+// but it is not an error and cannot be handled as such.
+type NonLocalExit struct {
+	// Point is the destination of return-from and go. it is nil for throw.
+	Point *ExitPoint
+	// Label is the go tag to jump to
+	Label string
+	// CatchTag is the tag of the catch which throw transfers the control to
+	CatchTag Object
+	Value    Object
+}
+
+func (n *NonLocalExit) Type() ObjectType { return EXIT_OBJ }
+func (n *NonLocalExit) Inspect() string  { return "non-local exit" }
+
+type True struct{}
+
+func (t *True) Type() ObjectType { return TRUE_OBJ }
+func (t *True) Inspect() string  { return "T" }
+
+type Nil struct{}
+
+func (n *Nil) Type() ObjectType { return NIL_OBJ }
+func (n *Nil) Inspect() string  { return "nil" }
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+// Bignum is an integer which does not fit in int64.
+// arithmetic results which fit in int64 are always represented as Integer instead.
+type Bignum struct {
+	Value *big.Int
+}
+
+func (b *Bignum) Type() ObjectType { return BIGNUM_OBJ }
+func (b *Bignum) Inspect() string  { return b.Value.String() }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return formatFloat(f.Value) }
+
+// formatFloat prints a float the way Lisp does, always with a decimal point,
+// e.g. 1.0, 3.14 and 1.0e10
+func formatFloat(value float64) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	abs := math.Abs(value)
+	if abs != 0 && (abs >= 1e7 || abs < 1e-3) {
+		mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(value, 'e', -1, 64), "e")
+		if !strings.Contains(mantissa, ".") {
+			mantissa += ".0"
+		}
+		sign, digits := "", strings.TrimPrefix(exponent, "+")
+		if strings.HasPrefix(digits, "-") {
+			sign, digits = "-", digits[1:]
+		}
+		return mantissa + "e" + sign + strings.TrimLeft(digits, "0")
+	}
+
+	str := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.Contains(str, ".") {
+		str += ".0"
+	}
+	return str
+}
+
+// Ratio is an exact fraction whose denominator is never 1
+type Ratio struct {
+	Value *big.Rat
+}
+
+func (r *Ratio) Type() ObjectType { return RATIO_OBJ }
+func (r *Ratio) Inspect() string  { return r.Value.String() }
+
+// stringEscaper escapes the characters which cannot appear as-is inside a string literal
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// symbolEscaper escapes the characters which end the name of the symbol written in |...|
+var symbolEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return `"` + stringEscaper.Replace(s.Value) + `"` }
+
+// characterNames holds the printed names of the characters which have no visible glyph
+var characterNames = map[rune]string{
+	' ':  "Space",
+	'\n': "Newline",
+	'\t': "Tab",
+	'\r': "Return",
+}
+
+type Character struct {
+	Value rune
+}
+
+func (c *Character) Type() ObjectType { return CHARACTER_OBJ }
+func (c *Character) Inspect() string {
+	if name, ok := characterNames[c.Value]; ok {
+		return `#\` + name
+	}
+	return `#\` + string(c.Value)
+}
+
+type Symbol struct {
+	Name         string
+	Value        Object
+	PropertyList []Object
+	// Function is the global function definition, which is a Function or a Builtin
+	Function Object
+	// Uninterned is true if the symbol is made by make-symbol or gensym, which is not in the symbol table
+	Uninterned bool
+	// Keyword is true if the symbol is in the keyword package.
+	// its name does not include the leading colon, which is only written when it is printed.
+	Keyword bool
+}
+
+func (s *Symbol) Type() ObjectType { return SYMBOL_OBJ }
+func (s *Symbol) Inspect() string {
+	switch {
+	case s.Uninterned:
+		return "#:" + escapeSymbolName(s.Name, false)
+	case s.Keyword:
+		return ":" + escapeSymbolName(s.Name, true)
+	default:
+		return escapeSymbolName(s.Name, false)
+	}
+}
+
+// escapeSymbolName encloses the name in |...| unless the reader reads it back as the same symbol,
+// e.g. the name which has a space or an uppercase letter, or is written like a number.
+func escapeSymbolName(name string, keyword bool) string {
+	text := name
+	if keyword {
+		text = ":" + name
+	}
+
+	l := lexer.New(text)
+	tok := l.NextToken()
+	readable := tok.Literal == text && l.NextToken().Type == token.EOF &&
+		tok.Type == token.LookupKeyword(text) && tok.Type != token.NIL && tok.Type != token.TRUE &&
+		(tok.Type == token.KEYWORD) == keyword
+	if readable {
+		return name
+	}
+
+	return "|" + symbolEscaper.Replace(name) + "|"
+}
+
+// IsKeyword reports whether the symbol is in the keyword package, whose names are written with a leading colon
+func (s *Symbol) IsKeyword() bool { return s.Keyword }
+
+type Function struct {
+	*LambdaList
+	// Body is the list of the forms which are evaluated in order like progn
+	Body []ast.SExpression
+	Env  *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("(lambda ")
+	out.WriteString(f.LambdaList.String())
+	for _, form := range f.Body {
+		out.WriteString(" ")
+		out.WriteString(form.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Applicable is implemented by the functions of the other backends, such as the closures of the bytecode vm,
+// so that the evaluator can call them
+type Applicable interface {
+	Object
+	Apply(env *Environment, args []Object) Object
+}
+
+type Builtin struct {
+	Fn BuiltInFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function" }
+
+type Macro struct {
+	*LambdaList
+	// Body is the list of the forms which are evaluated in order like progn
+	Body []ast.SExpression
+	Env  *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("(macro ")
+	out.WriteString(m.LambdaList.String())
+	for _, form := range m.Body {
+		out.WriteString(" ")
+		out.WriteString(form.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ConsCell struct {
+	Car Object
+	Cdr Object
+}
+
+func (cc *ConsCell) Type() ObjectType { return CONSCELL_OBJ }
+func (cc *ConsCell) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+
+	consCell := cc
+	for {
+		out.WriteString(consCell.Car.Inspect())
+
+		if _, ok := consCell.Cdr.(*Nil); ok {
+			break
+		}
+
+		if cdr, ok := consCell.Cdr.(*ConsCell); ok {
+			out.WriteString(" ")
+			consCell = cdr
+		} else {
+			out.WriteString(" . ")
+			out.WriteString(consCell.Cdr.Inspect())
+			break
+		}
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type List struct {
+	SExpressions []Object
+}
+
+func (l *List) Type() ObjectType { return LIST_OBJ }
+func (l *List) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	for i, s := range l.SExpressions {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(s.Inspect())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
@@ -1,243 +1,496 @@
-package parser
-
-import (
-	"fmt"
-	"strconv"
-
-	"github.com/JunNishimura/go-lisp/ast"
-	"github.com/JunNishimura/go-lisp/lexer"
-	"github.com/JunNishimura/go-lisp/token"
-)
-
-type Parser struct {
-	l *lexer.Lexer
-
-	curToken  token.Token
-	peekToken token.Token
-
-	errors []string
-}
-
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{
-		l:      l,
-		errors: []string{},
-	}
-
-	p.nextToken()
-	p.nextToken()
-
-	return p
-}
-
-func (p *Parser) Errors() []string {
-	return p.errors
-}
-
-func (p *Parser) curError(types ...token.TokenType) {
-	expected := ""
-	for i, t := range types {
-		if i > 0 {
-			expected += " or "
-		}
-		expected += string(t)
-	}
-	msg := fmt.Sprintf("expected token to be %s, got %s instead", expected, p.curToken.Type)
-	p.errors = append(p.errors, msg)
-}
-
-func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
-}
-
-func (p *Parser) expectCur(t token.TokenType) bool {
-	if p.curTokenIs(t) {
-		p.nextToken()
-		return true
-	}
-	p.curError(t)
-	return false
-}
-
-func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{
-		Expressions: []ast.SExpression{},
-	}
-
-	for p.curToken.Type != token.EOF {
-		sexpression := p.parseSExpression()
-		if sexpression != nil {
-			program.Expressions = append(program.Expressions, sexpression)
-		}
-	}
-
-	return program
-}
-
-func (p *Parser) parseSExpression() ast.SExpression {
-	if p.isDataMode() {
-		return p.parseDataMode()
-	}
-	return p.parseCodeMode()
-}
-
-func (p *Parser) isDataMode() bool {
-	return p.curToken.Type == token.QUOTE && p.curToken.Literal == "'" ||
-		p.curToken.Type == token.BACKQUOTE ||
-		p.curToken.Type == token.COMMA
-}
-
-func (p *Parser) parseList() ast.List {
-	p.nextToken()
-
-	// treat empty list as nil
-	if p.curTokenIs(token.RPAREN) {
-		p.nextToken()
-		return &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}}
-	}
-
-	// parse car
-	car := p.parseSExpression()
-
-	// if list is composed of only one element
-	// treat it as a ConsCell with cdr being nil
-	if p.curTokenIs(token.RPAREN) {
-		p.nextToken()
-		return &ast.ConsCell{
-			CarField: car,
-			CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-		}
-	}
-
-	var consCell *ast.ConsCell
-	if p.curTokenIs(token.DOT) {
-		// parse list defined below
-		// "(" <s-expression> "." <s-expression> ")"
-		p.nextToken()
-		consCell = &ast.ConsCell{
-			CarField: car,
-			CdrField: p.parseSExpression(),
-		}
-	} else {
-		// parse list defined below
-		// "(" <s-expression> <s-expression> ... ")"
-		consCell = &ast.ConsCell{
-			CarField: car,
-			CdrField: p.parseContinuousSExpression(),
-		}
-	}
-
-	if !p.expectCur(token.RPAREN) {
-		return nil
-	}
-
-	return consCell
-}
-
-func (p *Parser) parseCodeMode() ast.SExpression {
-	switch p.curToken.Type {
-	case token.LPAREN:
-		return p.parseList()
-	default:
-		return p.parseAtom()
-	}
-}
-
-func (p *Parser) parseDataMode() ast.SExpression {
-	var car ast.SExpression
-	switch p.curToken.Type {
-	case token.QUOTE:
-		car = &ast.SpecialForm{Token: p.curToken, Value: "quote"}
-	case token.BACKQUOTE:
-		car = &ast.SpecialForm{Token: p.curToken, Value: "backquote"}
-	case token.COMMA:
-		car = &ast.SpecialForm{Token: p.curToken, Value: "unquote"}
-	}
-
-	p.nextToken()
-
-	sexpression := p.parseSExpression()
-
-	return &ast.ConsCell{
-		CarField: car,
-		CdrField: &ast.ConsCell{
-			CarField: sexpression,
-			CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-		},
-	}
-}
-
-func (p *Parser) parseAtom() ast.Atom {
-	atom := p.parseAtomByType()
-
-	p.nextToken()
-
-	return atom
-}
-
-func (p *Parser) parseAtomByType() ast.Atom {
-	switch p.curToken.Type {
-	case token.PLUS, token.MINUS:
-		return p.parsePrefixAtom()
-	case token.INT:
-		return p.parseIntegerLiteral()
-	case token.TRUE:
-		return &ast.True{Token: p.curToken}
-	case token.SYMBOL:
-		return &ast.Symbol{Token: p.curToken, Value: p.curToken.Literal}
-	case token.LAMBDA,
-		token.QUOTE, // this quote is string, not '
-		token.IF,
-		token.SETQ:
-		return &ast.SpecialForm{Token: p.curToken, Value: p.curToken.Literal}
-	case token.NIL:
-		return &ast.Nil{Token: p.curToken}
-	}
-
-	msg := fmt.Sprintf("could not parse %q as atom", p.curToken.Literal)
-	p.errors = append(p.errors, msg)
-	return nil
-}
-
-func (p *Parser) parsePrefixAtom() *ast.PrefixAtom {
-	prefixAtom := &ast.PrefixAtom{
-		Token:    p.curToken,
-		Operator: p.curToken.Literal,
-	}
-
-	p.nextToken()
-
-	prefixAtom.Right = p.parseAtomByType()
-
-	return prefixAtom
-}
-
-func (p *Parser) parseIntegerLiteral() *ast.IntegerLiteral {
-	intValue, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
-	}
-
-	return &ast.IntegerLiteral{
-		Token: p.curToken,
-		Value: intValue,
-	}
-}
-
-func (p *Parser) parseContinuousSExpression() ast.SExpression {
-	if p.curTokenIs(token.RPAREN) {
-		return &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}}
-	}
-
-	return &ast.ConsCell{
-		CarField: p.parseSExpression(),
-		CdrField: p.parseContinuousSExpression(),
-	}
-}
-
-func (p *Parser) curTokenIs(t token.TokenType) bool {
-	return p.curToken.Type == t
-}
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+type Parser struct {
+	l *lexer.Lexer
+
+	curToken  token.Token
+	peekToken token.Token
+
+	errors []*ParseError
+
+	// depth is the number of the lists being parsed.
+	// the list which is not closed before EOF is only reported by the outermost one.
+	depth int
+}
+
+// ParseError is the error found while parsing.
+// Expected is the set of the token types which were acceptable at Pos and Actual is the token found there.
+// Expected is empty if the token is acceptable but malformed, such as the ratio 1/0.
+// for the list which is not closed before EOF, Pos is its opening paren and Actual is EOF.
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.TokenType
+	Actual   token.Token
+	Message  string
+}
+
+// Error returns the message with the position and the source line pointing to it
+func (e *ParseError) Error() string {
+	return e.Pos.Annotate(e.Message)
+}
+
+// sexpressionStart is the set of the tokens which can start an S-expression
+var sexpressionStart = []token.TokenType{
+	token.LPAREN,
+	token.SYMBOL,
+	token.KEYWORD,
+	token.INT,
+	token.FLOAT,
+	token.RATIO,
+	token.STRING,
+	token.QUOTE,
+	token.BACKQUOTE,
+	token.COMMA,
+	token.COMMA_AT,
+	token.FUNCTION,
+}
+
+func New(l *lexer.Lexer) *Parser {
+	p := &Parser{
+		l:      l,
+		errors: []*ParseError{},
+	}
+
+	p.nextToken()
+	p.nextToken()
+
+	return p
+}
+
+// Errors returns the errors found by ParseProgram in the order of their positions
+func (p *Parser) Errors() []*ParseError {
+	return p.errors
+}
+
+func (p *Parser) curError(types ...token.TokenType) {
+	expected := ""
+	for i, t := range types {
+		if i > 0 {
+			expected += " or "
+		}
+		expected += string(t)
+	}
+	msg := fmt.Sprintf("expected token to be %s, got %s instead", expected, p.curToken.Type)
+	p.addError(msg, types...)
+}
+
+// addError records msg at the current token with the token types which were expected there
+func (p *Parser) addError(msg string, expected ...token.TokenType) {
+	p.addErrorAt(p.curToken.Pos, msg, expected...)
+}
+
+// addErrorAt records msg at pos, which is inside the current token
+func (p *Parser) addErrorAt(pos token.Position, msg string, expected ...token.TokenType) {
+	p.errors = append(p.errors, &ParseError{
+		Pos:      pos,
+		Expected: expected,
+		Actual:   p.curToken,
+		Message:  msg,
+	})
+}
+
+// unclosedError records the error for the list opened by lparen, which is not closed before EOF.
+// it points to the opening paren, so it is inserted before the errors found inside the list.
+func (p *Parser) unclosedError(lparen token.Token) {
+	err := &ParseError{
+		Pos:      lparen.Pos,
+		Expected: []token.TokenType{token.RPAREN},
+		Actual:   p.curToken,
+		Message:  fmt.Sprintf("expected token to be %s to close this list, got %s instead", token.RPAREN, p.curToken.Type),
+	}
+
+	i := len(p.errors)
+	for i > 0 && p.errors[i-1].Pos.Offset > lparen.Pos.Offset {
+		i--
+	}
+	p.errors = append(p.errors[:i], append([]*ParseError{err}, p.errors[i:]...)...)
+}
+
+func (p *Parser) nextToken() {
+	p.curToken = p.peekToken
+	p.peekToken = p.l.NextToken()
+}
+
+// ParseProgram parses the whole input.
+// the parser goes on after an error, so the program is returned with the parts which could not be parsed
+// replaced by ast.BadSExpression.
+func (p *Parser) ParseProgram() *ast.Program {
+	program := &ast.Program{
+		Expressions: []ast.SExpression{},
+	}
+
+	for p.curToken.Type != token.EOF {
+		// the closing paren which matches nothing is skipped
+		if p.curTokenIs(token.RPAREN) {
+			p.addError(fmt.Sprintf("could not parse %q as atom", p.curToken.Literal), sexpressionStart...)
+			p.nextToken()
+			continue
+		}
+		program.Expressions = append(program.Expressions, p.parseSExpression())
+	}
+
+	return program
+}
+
+func (p *Parser) parseSExpression() ast.SExpression {
+	if p.isDataMode() {
+		return p.parseDataMode()
+	}
+	return p.parseCodeMode()
+}
+
+func (p *Parser) isDataMode() bool {
+	return p.curToken.Type == token.QUOTE && p.curToken.Literal == "'" ||
+		p.curToken.Type == token.BACKQUOTE && p.curToken.Literal == "`" ||
+		p.curToken.Type == token.COMMA && p.curToken.Literal == "," ||
+		p.curToken.Type == token.COMMA_AT && p.curToken.Literal == ",@" ||
+		p.curToken.Type == token.FUNCTION && p.curToken.Literal == "#'"
+}
+
+func (p *Parser) parseList() ast.List {
+	lparen := p.curToken
+	p.nextToken()
+
+	p.depth++
+	defer func() { p.depth-- }()
+
+	// treat empty list as nil
+	if p.curTokenIs(token.RPAREN) || p.curTokenIs(token.EOF) {
+		nilSExp := p.newNil()
+		nilSExp.Token.Pos = lparen.Pos
+		if p.curTokenIs(token.EOF) {
+			if p.depth == 1 {
+				p.unclosedError(lparen)
+			}
+			return nilSExp
+		}
+		p.nextToken()
+		return nilSExp
+	}
+
+	// parse car
+	car := p.parseSExpression()
+
+	// if list is composed of only one element
+	// treat it as a ConsCell with cdr being nil
+	if p.curTokenIs(token.RPAREN) {
+		consCell := &ast.ConsCell{
+			CarField: car,
+			CdrField: p.newNil(),
+		}
+		setListSpan(consCell, lparen.Pos, p.curToken.End)
+		p.nextToken()
+		return consCell
+	}
+
+	var consCell *ast.ConsCell
+	if p.curTokenIs(token.DOT) {
+		// parse list defined below
+		// "(" <s-expression> "." <s-expression> ")"
+		p.nextToken()
+		consCell = &ast.ConsCell{
+			CarField: car,
+			CdrField: p.parseSExpression(),
+		}
+	} else {
+		// parse list defined below
+		// "(" <s-expression> <s-expression> ... ")"
+		consCell = &ast.ConsCell{
+			CarField: car,
+			CdrField: p.parseContinuousSExpression(),
+		}
+	}
+
+	// the rest of the list is skipped when it is not closed here,
+	// so that the error does not cascade to the forms following the list.
+	// at EOF, the enclosing lists are not closed either, so only the outermost one is reported.
+	switch {
+	case p.curTokenIs(token.EOF):
+		if p.depth == 1 {
+			p.unclosedError(lparen)
+		}
+	case !p.curTokenIs(token.RPAREN):
+		p.curError(token.RPAREN)
+		p.skipToMatchingParen()
+	}
+
+	setListSpan(consCell, lparen.Pos, p.curToken.End)
+	if p.curTokenIs(token.RPAREN) {
+		p.nextToken()
+	}
+
+	return consCell
+}
+
+// skipToMatchingParen skips the tokens until the paren which closes the current list, or until EOF
+func (p *Parser) skipToMatchingParen() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		p.nextToken()
+	}
+}
+
+// setListSpan sets the spans of the cells in the list.
+// the first cell starts at the opening paren and the others start at their car.
+// the list after the dot already has its own spans, which are left as they are.
+func setListSpan(list *ast.ConsCell, start, end token.Position) {
+	for consCell, ok := list, true; ok && !consCell.SpanField.IsValid(); consCell, ok = consCell.CdrField.(*ast.ConsCell) {
+		if consCell != list && consCell.CarField != nil {
+			start = consCell.CarField.Span().Start
+		}
+		consCell.SpanField = ast.Span{Start: start, End: end}
+	}
+}
+
+// newNil returns the nil which terminates the list at the current token
+func (p *Parser) newNil() *ast.Nil {
+	return &ast.Nil{Token: token.Token{
+		Type:    token.NIL,
+		Literal: "nil",
+		Pos:     p.curToken.Pos,
+		End:     p.curToken.End,
+	}}
+}
+
+func (p *Parser) parseCodeMode() ast.SExpression {
+	switch p.curToken.Type {
+	case token.LPAREN:
+		return p.parseList()
+	default:
+		return p.parseAtom()
+	}
+}
+
+func (p *Parser) parseDataMode() ast.SExpression {
+	var car ast.SExpression
+	switch p.curToken.Type {
+	case token.QUOTE:
+		car = &ast.SpecialForm{Token: p.curToken, Value: "quote"}
+	case token.BACKQUOTE:
+		car = &ast.SpecialForm{Token: p.curToken, Value: "backquote"}
+	case token.COMMA:
+		car = &ast.SpecialForm{Token: p.curToken, Value: "unquote"}
+	case token.COMMA_AT:
+		car = &ast.SpecialForm{Token: p.curToken, Value: "unquote-splicing"}
+	case token.FUNCTION:
+		car = &ast.SpecialForm{Token: p.curToken, Value: "function"}
+	}
+
+	p.nextToken()
+
+	sexpression := p.parseSExpression()
+
+	// the synthesized cells span from the shorthand to the end of the quoted expression
+	span := sexpression.Span()
+	nilToken := token.Token{Type: token.NIL, Literal: "nil", Pos: span.End, End: span.End}
+
+	return &ast.ConsCell{
+		CarField: car,
+		CdrField: &ast.ConsCell{
+			CarField:  sexpression,
+			CdrField:  &ast.Nil{Token: nilToken},
+			SpanField: span,
+		},
+		SpanField: ast.Span{Start: car.Span().Start, End: span.End},
+	}
+}
+
+func (p *Parser) parseAtom() ast.Atom {
+	atom := p.parseAtomByType()
+
+	// the closing paren and EOF are never a part of the atom,
+	// so they are left for the list or the program to which they belong
+	if p.curTokenIs(token.RPAREN) || p.curTokenIs(token.EOF) {
+		return atom
+	}
+	p.nextToken()
+
+	return atom
+}
+
+func (p *Parser) parseAtomByType() ast.Atom {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.RATIO:
+		return p.parseRatioLiteral()
+	case token.STRING:
+		return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	case token.TRUE:
+		return &ast.True{Token: p.curToken}
+	case token.SYMBOL, token.KEYWORD:
+		return &ast.Symbol{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LAMBDA,
+		token.QUOTE,     // this quote is string, not '
+		token.BACKQUOTE, // this backquote is string, not `
+		token.COMMA,     // this unquote is string, not ,
+		token.COMMA_AT,  // this unquote-splicing is string, not ,@
+		token.IF,
+		token.SETQ,
+		token.DEFUN,
+		token.PROGN,
+		token.PROG1,
+		token.PROG2,
+		token.LET,
+		token.LETSTAR,
+		token.FLET,
+		token.LABELS,
+		token.COND,
+		token.WHEN,
+		token.UNLESS,
+		token.AND,
+		token.OR,
+		token.CASE,
+		token.ECASE,
+		token.TYPECASE,
+		token.ETYPECASE,
+		token.BLOCK,
+		token.RETURN_FROM,
+		token.RETURN,
+		token.TAGBODY,
+		token.GO,
+		token.CATCH,
+		token.THROW,
+		token.UNWIND_PROTECT,
+		token.HANDLER_CASE,
+		token.HANDLER_BIND,
+		token.IGNORE_ERRORS,
+		token.RESTART_CASE,
+		token.DEFINE_CONDITION,
+		token.FUNCTION: // this function is string, not #'
+		return &ast.SpecialForm{Token: p.curToken, Value: p.curToken.Literal}
+	case token.NIL:
+		return &ast.Nil{Token: p.curToken}
+	}
+
+	// the lexer knows why the token such as an unterminated string literal is illegal
+	if p.curToken.Err != nil {
+		p.addErrorAt(p.curToken.Err.Pos, p.curToken.Err.Message)
+		return &ast.BadSExpression{Token: p.curToken}
+	}
+
+	msg := fmt.Sprintf("could not parse %q as atom", p.curToken.Literal)
+	p.addError(msg, sexpressionStart...)
+	return &ast.BadSExpression{Token: p.curToken}
+}
+
+// integerDigits returns the digits of the integer literal without its trailing decimal point.
+// the literal is always decimal, so the leading zeros are not the prefix of octal.
+func (p *Parser) integerDigits() string {
+	return strings.TrimSuffix(p.curToken.Literal, ".")
+}
+
+func (p *Parser) parseIntegerLiteral() ast.Atom {
+	intValue, err := strconv.ParseInt(p.integerDigits(), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return p.parseBignumLiteral()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		p.addError(msg)
+		return &ast.BadSExpression{Token: p.curToken}
+	}
+
+	return &ast.IntegerLiteral{
+		Token: p.curToken,
+		Value: intValue,
+	}
+}
+
+func (p *Parser) parseBignumLiteral() ast.Atom {
+	bigValue, ok := new(big.Int).SetString(p.integerDigits(), 10)
+	if !ok {
+		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		p.addError(msg)
+		return &ast.BadSExpression{Token: p.curToken}
+	}
+
+	return &ast.BignumLiteral{
+		Token: p.curToken,
+		Value: bigValue,
+	}
+}
+
+func (p *Parser) parseFloatLiteral() ast.Atom {
+	// strconv only knows the exponent marker e
+	literal := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("dDfFsSlL", r) {
+			return 'e'
+		}
+		return r
+	}, p.curToken.Literal)
+
+	floatValue, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(msg)
+		return &ast.BadSExpression{Token: p.curToken}
+	}
+
+	return &ast.FloatLiteral{
+		Token: p.curToken,
+		Value: floatValue,
+	}
+}
+
+func (p *Parser) parseRatioLiteral() ast.Atom {
+	// the numerator and the denominator are decimal like the integers
+	numerator, denominator, _ := strings.Cut(p.curToken.Literal, "/")
+	num, okNum := new(big.Int).SetString(numerator, 10)
+	denom, okDenom := new(big.Int).SetString(denominator, 10)
+	if !okNum || !okDenom || denom.Sign() == 0 {
+		msg := fmt.Sprintf("could not parse %q as ratio", p.curToken.Literal)
+		p.addError(msg)
+		return &ast.BadSExpression{Token: p.curToken}
+	}
+
+	return &ast.RatioLiteral{
+		Token: p.curToken,
+		Value: new(big.Rat).SetFrac(num, denom),
+	}
+}
+
+func (p *Parser) parseContinuousSExpression() ast.SExpression {
+	// the missing closing parenthesis is reported by parseList
+	if p.curTokenIs(token.RPAREN) || p.curTokenIs(token.EOF) {
+		return p.newNil()
+	}
+
+	// "(" <s-expression> <s-expression> ... "." <s-expression> ")"
+	if p.curTokenIs(token.DOT) {
+		p.nextToken()
+		return p.parseSExpression()
+	}
+
+	return &ast.ConsCell{
+		CarField: p.parseSExpression(),
+		CdrField: p.parseContinuousSExpression(),
+	}
+}
+
+func (p *Parser) curTokenIs(t token.TokenType) bool {
+	return p.curToken.Type == t
+}
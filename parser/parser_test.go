@@ -1,1525 +1,2078 @@
-package parser
-
-import (
-	"testing"
-
-	"github.com/JunNishimura/go-lisp/ast"
-	"github.com/JunNishimura/go-lisp/lexer"
-	"github.com/JunNishimura/go-lisp/token"
-)
-
-func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.Errors()
-	if len(errors) == 0 {
-		return
-	}
-
-	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
-	}
-	t.FailNow()
-}
-
-func TestIntegerAtom(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected int64
-	}{
-		{
-			name:     "parse positive integer",
-			input:    "1",
-			expected: 1,
-		},
-		{
-			name:     "parse multiple digit integer",
-			input:    "1234567890",
-			expected: 1234567890,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			atom, ok := program.Expressions[0].(*ast.IntegerLiteral)
-			if !ok {
-				t.Fatalf("exp not *ast.IntegerLiteral. got=%T", program.Expressions[0])
-			}
-			if atom.Value != tt.expected {
-				t.Fatalf("literal.Value not %d. got=%d", tt.expected, atom.Value)
-			}
-		})
-	}
-}
-
-func TestPrefixAtom(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.PrefixAtom
-	}{
-		{
-			name:  "parse positive integer",
-			input: "+1",
-			expected: &ast.PrefixAtom{
-				Token:    token.Token{Type: token.PLUS, Literal: "+"},
-				Operator: "+",
-				Right: &ast.IntegerLiteral{
-					Token: token.Token{Type: token.INT, Literal: "1"},
-					Value: 1,
-				},
-			},
-		},
-		{
-			name:  "parse negative integer",
-			input: "-1",
-			expected: &ast.PrefixAtom{
-				Token:    token.Token{Type: token.MINUS, Literal: "-"},
-				Operator: "-",
-				Right: &ast.IntegerLiteral{
-					Token: token.Token{Type: token.INT, Literal: "1"},
-					Value: 1,
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			atom, ok := program.Expressions[0].(*ast.PrefixAtom)
-			if !ok {
-				t.Fatalf("exp not *ast.PrefixExpression. got=%T", program.Expressions[0])
-			}
-			if atom.String() != tt.expected.String() {
-				t.Fatalf("literal.Value not %s. got=%s", tt.expected, atom.String())
-			}
-		})
-	}
-}
-
-func TestNil(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.Nil
-	}{
-		{
-			name:     "literal nil",
-			input:    "nil",
-			expected: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-		},
-		{
-			name:     "empty list",
-			input:    "()",
-			expected: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			n, ok := program.Expressions[0].(*ast.Nil)
-			if !ok {
-				t.Fatalf("exp not *ast.Nil. got=%T", program.Expressions[0])
-			}
-			if n.String() != tt.expected.String() {
-				t.Fatalf("literal.Value not %s. got=%s", tt.expected.String(), n.String())
-			}
-		})
-	}
-}
-
-func TestArithmeticOperations(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "single element list",
-			input: "(1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-				CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-			},
-		},
-		{
-			name:  "simple addition",
-			input: "(+ . (1 . (2 . nil)))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "simple subtraction",
-			input: "(- . (1 . (2 . nil)))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "-"}, Value: "-"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "simple multiplication",
-			input: "(* 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "*"}, Value: "*"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "simple division",
-			input: "(/ 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "/"}, Value: "/"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "nested cons cell",
-			input: "(* (+ 1 2) (- 4 3))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "*"}, Value: "*"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "-"}, Value: "-"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "mix of dotted pair and list",
-			input: "(+ . (1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "include prefix atom",
-			input: "(+ -5 5)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.PrefixAtom{
-						Token:    token.Token{Type: token.MINUS, Literal: "-"},
-						Operator: "-",
-						Right: &ast.IntegerLiteral{
-							Token: token.Token{Type: token.INT, Literal: "5"},
-							Value: 5,
-						},
-					},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestComparisonOperations(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "equal",
-			input: "(= 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "="}, Value: "="},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "not equal",
-			input: "(/= 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "/="}, Value: "/="},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "less than",
-			input: "(< 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "<"}, Value: "<"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "less than or equal",
-			input: "(<= 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "<="}, Value: "<="},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "greater than",
-			input: "(> 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: ">"}, Value: ">"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "greater than or equal",
-			input: "(>= 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: ">="}, Value: ">="},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestLambdaExpression(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "lambda expression with no parameter",
-			input: "(lambda () 1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "lambda expression with one parameter",
-			input: "(lambda (x) (+ x 1))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "lambda expression with multiple parameters",
-			input: "(lambda (x y) (+ x y))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "y"}, Value: "y"},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "y"}, Value: "y"},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestQuoteExpression(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected ast.SExpression
-	}{
-		{
-			name:  "atom with quote",
-			input: "'1",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "prefix atom with quote",
-			input: "'-1",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.PrefixAtom{
-						Token:    token.Token{Type: token.MINUS, Literal: "-"},
-						Operator: "-",
-						Right: &ast.IntegerLiteral{
-							Token: token.Token{Type: token.INT, Literal: "1"},
-							Value: 1,
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "list with quote",
-			input: "'(1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "atom with quote symbol",
-			input: "(quote 1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "prefix atom with quote symbol",
-			input: "(quote -1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.PrefixAtom{
-						Token:    token.Token{Type: token.MINUS, Literal: "-"},
-						Operator: "-",
-						Right: &ast.IntegerLiteral{
-							Token: token.Token{Type: token.INT, Literal: "1"},
-							Value: 1,
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "list with quote symbol",
-			input: "(quote (1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestBackQuoteExpression(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected ast.SExpression
-	}{
-		{
-			name:  "atom with backquote",
-			input: "`1",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "symbol with backquote",
-			input: "`hoge",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "hoge"}, Value: "hoge"},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "prefix atom with backquote",
-			input: "`-1",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.PrefixAtom{
-						Token:    token.Token{Type: token.MINUS, Literal: "-"},
-						Operator: "-",
-						Right: &ast.IntegerLiteral{
-							Token: token.Token{Type: token.INT, Literal: "1"},
-							Value: 1,
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "list with backquote",
-			input: "`(1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "dotted pair with backquote",
-			input: "`(+ . (1 . (2 . nil)))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "comma with atom",
-			input: "`,1",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-		{
-			name:  "comma in backquotted list",
-			input: "`(1 ,(+ 1 2) 3)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestDefmacro(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "define macro with no parameter which returns atom",
-			input: "(defmacro foo () 1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "define macro with one parameter which returns atom",
-			input: "(defmacro foo (x) 'x)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "define macro with no parameter which returns cons cell",
-			input: "(defmacro foo () '(+ 1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "define macro with one parameter which returns cons cell",
-			input: "(defmacro foo (x) '(+ x 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "define macro with multiple parameters which returns cons cell",
-			input: "(defmacro unless (condition body) `(if (not ,condition) ,body nil))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "unless"}, Value: "unless"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "condition"}, Value: "condition"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "body"}, Value: "body"},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.ConsCell{
-												CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "not"}, Value: "not"},
-												CdrField: &ast.ConsCell{
-													CarField: &ast.ConsCell{
-														CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
-														CdrField: &ast.ConsCell{
-															CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "condition"}, Value: "condition"},
-															CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-														},
-													},
-													CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-												},
-											},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.ConsCell{
-													CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
-													CdrField: &ast.ConsCell{
-														CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "body"}, Value: "body"},
-														CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-													},
-												},
-												CdrField: &ast.ConsCell{
-													CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-													CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-												},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestIf(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "if expression with then clause which returns atom",
-			input: "(if t 1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.True{Token: token.Token{Type: token.TRUE, Literal: "t"}},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "if expression with then clause which returns cons cell",
-			input: "(if t '(+ 1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.True{Token: token.Token{Type: token.TRUE, Literal: "t"}},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-									CdrField: &ast.ConsCell{
-										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-										},
-									},
-								},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "if expression with then and else clause which returns atom",
-			input: "(if nil 1 2)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "if expression with then and else clause which returns cons cell",
-			input: "(if nil '(+ 1 2) '(+ 3 4))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-									CdrField: &ast.ConsCell{
-										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-										},
-									},
-								},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name:  "if expression with then and else clause which returns cons cell, and condition is a list",
-			input: "(if (eq 1 1) '(+ 1 2) '(+ 3 4))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "eq"}, Value: "eq"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-									CdrField: &ast.ConsCell{
-										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-										},
-									},
-								},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.ConsCell{
-								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestSetq(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "set atom to symbol",
-			input: "(setq x 1)",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "set list to symbol",
-			input: "(setq x (+ 1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "set lambda function to symbol",
-			input: "(setq f (lambda (x) (+ x 1)))",
-			expected: &ast.ConsCell{
-				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-	}
-}
-
-func TestApplyFunction(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.ConsCell
-	}{
-		{
-			name:  "apply function with no parameter",
-			input: "(apply f ())",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "apply function with no parameter defined by nil",
-			input: "(apply f nil)",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "apply function with one parameter",
-			input: "(apply f '(1))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-		{
-			name:  "apply function with multiple parameters",
-			input: "(apply f '(1 2))",
-			expected: &ast.ConsCell{
-				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
-				CdrField: &ast.ConsCell{
-					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
-					CdrField: &ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-									CdrField: &ast.ConsCell{
-										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-										CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-									},
-								},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != 1 {
-				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
-			}
-
-			cc, ok := program.Expressions[0].(*ast.ConsCell)
-			if !ok {
-				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
-			}
-
-			if cc.String() != tt.expected.String() {
-				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
-			}
-		})
-
-	}
-}
-
-func TestProgram(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected *ast.Program
-	}{
-		{
-			name: "program with multiple atoms",
-			input: `
-				1
-				-10
-				hoge
-			`,
-			expected: &ast.Program{
-				Expressions: []ast.SExpression{
-					&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					&ast.PrefixAtom{
-						Token:    token.Token{Type: token.MINUS, Literal: "-"},
-						Operator: "-",
-						Right:    &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
-					},
-					&ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "hoge"}, Value: "hoge"},
-				},
-			},
-		},
-		{
-			name: "program with multiple lists",
-			input: `
-				(+ 1 2)
-				((lambda (x) (+ x 1)) 3)
-			`,
-			expected: &ast.Program{
-				Expressions: []ast.SExpression{
-					&ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-					&ast.ConsCell{
-						CarField: &ast.ConsCell{
-							CarField: &ast.SpecialForm{Token: token.Token{Type: token.SYMBOL, Literal: "lambda"}, Value: "lambda"},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.ConsCell{
-									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-								CdrField: &ast.ConsCell{
-									CarField: &ast.ConsCell{
-										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-										CdrField: &ast.ConsCell{
-											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
-											CdrField: &ast.ConsCell{
-												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-											},
-										},
-									},
-									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-								},
-							},
-						},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
-							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-						},
-					},
-				},
-			},
-		},
-		{
-			name: "program with atom and list",
-			input: `
-				1
-				(+ 1 2)
-			`,
-			expected: &ast.Program{
-				Expressions: []ast.SExpression{
-					&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-					&ast.ConsCell{
-						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
-						CdrField: &ast.ConsCell{
-							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
-							CdrField: &ast.ConsCell{
-								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
-								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			l := lexer.New(tt.input)
-			p := New(l)
-			program := p.ParseProgram()
-			checkParserErrors(t, p)
-
-			if len(program.Expressions) != len(tt.expected.Expressions) {
-				t.Fatalf("program.Expressions does not contain %d expressions. got=%d", len(tt.expected.Expressions), len(program.Expressions))
-			}
-			for i, exp := range tt.expected.Expressions {
-				if program.Expressions[i].String() != exp.String() {
-					t.Fatalf("program.Expressions[%d] not %s. got=%s", i, exp.String(), program.Expressions[i].String())
-				}
-			}
-		})
-	}
-}
+package parser
+
+import (
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/token"
+)
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg.Error())
+	}
+	t.FailNow()
+}
+
+func TestIntegerAtom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{
+			name:     "parse positive integer",
+			input:    "1",
+			expected: 1,
+		},
+		{
+			name:     "parse multiple digit integer",
+			input:    "1234567890",
+			expected: 1234567890,
+		},
+		{
+			name:     "parse integer with leading zeros in decimal",
+			input:    "010",
+			expected: 10,
+		},
+		{
+			name:     "parse integer with leading zero and digit 8",
+			input:    "08",
+			expected: 8,
+		},
+		{
+			name:     "parse integer with trailing decimal point",
+			input:    "1.",
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			atom, ok := program.Expressions[0].(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.IntegerLiteral. got=%T", program.Expressions[0])
+			}
+			if atom.Value != tt.expected {
+				t.Fatalf("literal.Value not %d. got=%d", tt.expected, atom.Value)
+			}
+		})
+	}
+}
+
+func TestBignumAtom(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"0123456789012345678901234567890.", "123456789012345678901234567890"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Expressions) != 1 {
+			t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+		}
+		atom, ok := program.Expressions[0].(*ast.BignumLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BignumLiteral. got=%T", program.Expressions[0])
+		}
+		if atom.Value.String() != tt.expected {
+			t.Fatalf("literal.Value not %s. got=%s", tt.expected, atom.Value.String())
+		}
+	}
+}
+
+func TestFloatAtom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected float64
+	}{
+		{
+			name:     "parse float",
+			input:    "3.14",
+			expected: 3.14,
+		},
+		{
+			name:     "parse float with exponent",
+			input:    "1e10",
+			expected: 1e10,
+		},
+		{
+			name:     "parse float with negative exponent",
+			input:    "2.5e-3",
+			expected: 2.5e-3,
+		},
+		{
+			name:     "parse float with exponent marker d",
+			input:    "1.5d0",
+			expected: 1.5,
+		},
+		{
+			name:     "parse float with exponent marker F",
+			input:    "2.5F-1",
+			expected: 0.25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			atom, ok := program.Expressions[0].(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.FloatLiteral. got=%T", program.Expressions[0])
+			}
+			if atom.Value != tt.expected {
+				t.Fatalf("literal.Value not %g. got=%g", tt.expected, atom.Value)
+			}
+		})
+	}
+}
+
+func TestRatioAtom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "parse ratio",
+			input:    "2/3",
+			expected: "2/3",
+		},
+		{
+			name:     "parse ratio which is not in lowest terms",
+			input:    "4/6",
+			expected: "2/3",
+		},
+		{
+			name:     "parse ratio with leading zeros in decimal",
+			input:    "010/08",
+			expected: "5/4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			atom, ok := program.Expressions[0].(*ast.RatioLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.RatioLiteral. got=%T", program.Expressions[0])
+			}
+			if atom.Value.String() != tt.expected {
+				t.Fatalf("literal.Value not %s. got=%s", tt.expected, atom.Value.String())
+			}
+		})
+	}
+}
+
+func TestRatioAtomError(t *testing.T) {
+	l := lexer.New("1/0")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("parser has %d errors, want 1", len(errors))
+	}
+	if errors[0].Error() != "1:1: could not parse \"1/0\" as ratio\n1/0\n^" {
+		t.Fatalf("wrong error message. got=%q", errors[0].Error())
+	}
+}
+
+func TestUnclosedList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "(+ 1 2",
+			expected: "1:1: expected token to be ) to close this list, got EOF instead\n(+ 1 2\n^",
+		},
+		{
+			input:    "(defun f (x) (+ x 1)",
+			expected: "1:1: expected token to be ) to close this list, got EOF instead\n(defun f (x) (+ x 1)\n^",
+		},
+		{
+			input:    "(a)\n(b (c\n  (d))",
+			expected: "2:1: expected token to be ) to close this list, got EOF instead\n(b (c\n^",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("parser has no errors for %q", tt.input)
+		}
+		if errors[len(errors)-1].Error() != tt.expected {
+			t.Errorf("wrong error message. want=%q, got=%q", tt.expected, errors[len(errors)-1].Error())
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	input := "(defun f (x)\n\t(+ x 1/0))"
+	l := lexer.NewFile("test.lisp", input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("parser has %d errors, want 1", len(errors))
+	}
+	expected := "test.lisp:2:7: could not parse \"1/0\" as ratio\n\t(+ x 1/0))\n\t     ^"
+	if errors[0].Error() != expected {
+		t.Fatalf("wrong error message. want=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestSpans(t *testing.T) {
+	input := "(a\n  'b . (c))"
+	l := lexer.NewFile("test.lisp", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	list := program.Expressions[0].(*ast.ConsCell)
+	quote := list.Cdr().(*ast.ConsCell)
+	tail := list.Cdr().(*ast.ConsCell).Cdr().(*ast.ConsCell)
+
+	tests := []struct {
+		name     string
+		sexp     ast.SExpression
+		expected string
+	}{
+		{"program", program, "test.lisp:1:1-test.lisp:2:12"},
+		{"list", list, "test.lisp:1:1-test.lisp:2:12"},
+		{"car", list.Car(), "test.lisp:1:2-test.lisp:1:3"},
+		{"quote", quote, "test.lisp:2:3-test.lisp:2:12"},
+		{"synthesized quote", quote.Car(), "test.lisp:2:3-test.lisp:2:5"},
+		{"quote symbol", quote.Car().(*ast.ConsCell).Car(), "test.lisp:2:3-test.lisp:2:4"},
+		{"quoted", quote.Car().(*ast.ConsCell).Cdr(), "test.lisp:2:4-test.lisp:2:5"},
+		{"dotted cdr", tail, "test.lisp:2:8-test.lisp:2:11"},
+		{"terminating nil", tail.Cdr(), "test.lisp:2:10-test.lisp:2:11"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := tt.sexp.Span()
+			got := span.Start.String() + "-" + span.End.String()
+			if got != tt.expected {
+				t.Errorf("wrong span. want=%s, got=%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStringAtom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "parse string",
+			input:    `"hello"`,
+			expected: "hello",
+		},
+		{
+			name:     "parse empty string",
+			input:    `""`,
+			expected: "",
+		},
+		{
+			name:     "parse string with escaped quote",
+			input:    `"a\"b"`,
+			expected: `a"b`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			atom, ok := program.Expressions[0].(*ast.StringLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.StringLiteral. got=%T", program.Expressions[0])
+			}
+			if atom.Value != tt.expected {
+				t.Fatalf("literal.Value not %q. got=%q", tt.expected, atom.Value)
+			}
+			if atom.String() != tt.input {
+				t.Fatalf("literal.String() not %s. got=%s", tt.input, atom.String())
+			}
+		})
+	}
+}
+
+func TestStringAtomError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    `(f "abc`,
+			expected: []string{"1:1: expected token to be ) to close this list, got EOF instead", "1:4: unterminated string literal"},
+		},
+		{
+			input:    `(f "a\u12") (g)`,
+			expected: []string{`1:6: invalid \u escape, expected 4 hex digits`},
+		},
+		{
+			input:    `"\uzzzz\u00e9\u1"`,
+			expected: []string{`1:2: invalid \u escape, expected 4 hex digits`},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Fatalf("parser has %d errors for %q, want %d", len(errors), tt.input, len(tt.expected))
+		}
+		for i, err := range errors {
+			if got := err.Pos.String() + ": " + err.Message; got != tt.expected[i] {
+				t.Errorf("errors[%d] wrong for %q. want=%q, got=%q", i, tt.input, tt.expected[i], got)
+			}
+		}
+	}
+}
+
+func TestSignedNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.SExpression
+	}{
+		{
+			name:     "parse positive integer",
+			input:    "+1",
+			expected: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "+1"}, Value: 1},
+		},
+		{
+			name:     "parse negative integer",
+			input:    "-1",
+			expected: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-1"}, Value: -1},
+		},
+		{
+			name:     "parse negative float",
+			input:    "-0.5",
+			expected: &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: "-0.5"}, Value: -0.5},
+		},
+		{
+			name:     "parse negative ratio",
+			input:    "-2/3",
+			expected: &ast.RatioLiteral{Token: token.Token{Type: token.RATIO, Literal: "-2/3"}, Value: big.NewRat(-2, 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			atom := program.Expressions[0]
+			if reflect.TypeOf(atom) != reflect.TypeOf(tt.expected) {
+				t.Fatalf("exp not %T. got=%T", tt.expected, atom)
+			}
+			if atom.String() != tt.expected.String() {
+				t.Fatalf("literal.Value not %s. got=%s", tt.expected, atom.String())
+			}
+		})
+	}
+}
+
+func TestNil(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.Nil
+	}{
+		{
+			name:     "literal nil",
+			input:    "nil",
+			expected: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+		},
+		{
+			name:     "empty list",
+			input:    "()",
+			expected: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			n, ok := program.Expressions[0].(*ast.Nil)
+			if !ok {
+				t.Fatalf("exp not *ast.Nil. got=%T", program.Expressions[0])
+			}
+			if n.String() != tt.expected.String() {
+				t.Fatalf("literal.Value not %s. got=%s", tt.expected.String(), n.String())
+			}
+		})
+	}
+}
+
+func TestArithmeticOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "single element list",
+			input: "(1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+				CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+			},
+		},
+		{
+			name:  "simple addition",
+			input: "(+ . (1 . (2 . nil)))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "simple subtraction",
+			input: "(- . (1 . (2 . nil)))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "-"}, Value: "-"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "simple multiplication",
+			input: "(* 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "*"}, Value: "*"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "simple division",
+			input: "(/ 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "/"}, Value: "/"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "nested cons cell",
+			input: "(* (+ 1 2) (- 4 3))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "*"}, Value: "*"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "-"}, Value: "-"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "mix of dotted pair and list",
+			input: "(+ . (1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "include prefix atom",
+			input: "(+ -5 5)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-5"}, Value: -5},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestComparisonOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "equal",
+			input: "(= 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "="}, Value: "="},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "not equal",
+			input: "(/= 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "/="}, Value: "/="},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "less than",
+			input: "(< 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "<"}, Value: "<"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "less than or equal",
+			input: "(<= 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "<="}, Value: "<="},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "greater than",
+			input: "(> 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: ">"}, Value: ">"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "greater than or equal",
+			input: "(>= 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: ">="}, Value: ">="},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestLambdaExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "lambda expression with no parameter",
+			input: "(lambda () 1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "lambda expression with one parameter",
+			input: "(lambda (x) (+ x 1))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "lambda expression with multiple parameters",
+			input: "(lambda (x y) (+ x y))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "y"}, Value: "y"},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "y"}, Value: "y"},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestQuoteExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.SExpression
+	}{
+		{
+			name:  "atom with quote",
+			input: "'1",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "prefix atom with quote",
+			input: "'-1",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-1"}, Value: -1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "list with quote",
+			input: "'(1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "atom with quote symbol",
+			input: "(quote 1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "prefix atom with quote symbol",
+			input: "(quote -1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-1"}, Value: -1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "list with quote symbol",
+			input: "(quote (1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "quote"}, Value: "quote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestBackQuoteExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ast.SExpression
+	}{
+		{
+			name:  "atom with backquote",
+			input: "`1",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "symbol with backquote",
+			input: "`hoge",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "hoge"}, Value: "hoge"},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "prefix atom with backquote",
+			input: "`-1",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-1"}, Value: -1},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "list with backquote",
+			input: "`(1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "dotted pair with backquote",
+			input: "`(+ . (1 . (2 . nil)))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "comma with atom",
+			input: "`,1",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "comma in backquotted list",
+			input: "`(1 ,(+ 1 2) 3)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+		{
+			name:  "comma at in backquotted list",
+			input: "`(1 ,@x)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA_AT, Literal: ",@"}, Value: "unquote-splicing"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+					CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestDefmacro(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "define macro with no parameter which returns atom",
+			input: "(defmacro foo () 1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "define macro with one parameter which returns atom",
+			input: "(defmacro foo (x) 'x)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "define macro with no parameter which returns cons cell",
+			input: "(defmacro foo () '(+ 1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "define macro with one parameter which returns cons cell",
+			input: "(defmacro foo (x) '(+ x 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "foo"}, Value: "foo"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "define macro with multiple parameters which returns cons cell",
+			input: "(defmacro unless (condition body) `(if (not ,condition) ,body nil))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "defmacro"}, Value: "defmacro"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "unless"}, Value: "unless"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "condition"}, Value: "condition"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "body"}, Value: "body"},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "`"}, Value: "backquote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.ConsCell{
+												CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "not"}, Value: "not"},
+												CdrField: &ast.ConsCell{
+													CarField: &ast.ConsCell{
+														CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
+														CdrField: &ast.ConsCell{
+															CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "condition"}, Value: "condition"},
+															CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+														},
+													},
+													CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+												},
+											},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.ConsCell{
+													CarField: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: ","}, Value: "unquote"},
+													CdrField: &ast.ConsCell{
+														CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "body"}, Value: "body"},
+														CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+													},
+												},
+												CdrField: &ast.ConsCell{
+													CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+													CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+												},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestIf(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "if expression with then clause which returns atom",
+			input: "(if t 1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.True{Token: token.Token{Type: token.TRUE, Literal: "t"}},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "if expression with then clause which returns cons cell",
+			input: "(if t '(+ 1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.True{Token: token.Token{Type: token.TRUE, Literal: "t"}},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+									CdrField: &ast.ConsCell{
+										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+										},
+									},
+								},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "if expression with then and else clause which returns atom",
+			input: "(if nil 1 2)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "if expression with then and else clause which returns cons cell",
+			input: "(if nil '(+ 1 2) '(+ 3 4))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+									CdrField: &ast.ConsCell{
+										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+										},
+									},
+								},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "if expression with then and else clause which returns cons cell, and condition is a list",
+			input: "(if (eq 1 1) '(+ 1 2) '(+ 3 4))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.IF, Literal: "if"}, Value: "if"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "eq"}, Value: "eq"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+									CdrField: &ast.ConsCell{
+										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+											CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+										},
+									},
+								},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.ConsCell{
+								CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "4"}, Value: 4},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestDottedList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "dotted pair",
+			input:    "(1 . 2)",
+			expected: "(1 . 2)",
+		},
+		{
+			name:     "dotted list",
+			input:    "(1 2 . 3)",
+			expected: "(1 2 . 3)",
+		},
+		{
+			name:     "dotted list ending with list",
+			input:    "(1 2 . (3))",
+			expected: "(1 2 3)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+			if program.Expressions[0].String() != tt.expected {
+				t.Fatalf("exp.String() not %s. got=%s", tt.expected, program.Expressions[0].String())
+			}
+		})
+	}
+}
+
+func TestQuoteSymbolNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.SpecialForm
+	}{
+		{
+			name:     "backquote symbol",
+			input:    "(backquote x)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.BACKQUOTE, Literal: "backquote"}, Value: "backquote"},
+		},
+		{
+			name:     "function symbol",
+			input:    "(function x)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.FUNCTION, Literal: "function"}, Value: "function"},
+		},
+		{
+			name:     "function shorthand",
+			input:    "#'x",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.FUNCTION, Literal: "#'"}, Value: "function"},
+		},
+		{
+			name:     "unquote symbol",
+			input:    "(unquote x)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.COMMA, Literal: "unquote"}, Value: "unquote"},
+		},
+		{
+			name:     "unquote-splicing symbol",
+			input:    "(unquote-splicing x)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.COMMA_AT, Literal: "unquote-splicing"}, Value: "unquote-splicing"},
+		},
+		{
+			name:     "let* symbol",
+			input:    "(let* ((x 1)) x)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.LETSTAR, Literal: "let*"}, Value: "let*"},
+		},
+		{
+			name:     "prog1 symbol",
+			input:    "(prog1 1 2)",
+			expected: &ast.SpecialForm{Token: token.Token{Type: token.PROG1, Literal: "prog1"}, Value: "prog1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+			spForm, ok := cc.Car().(*ast.SpecialForm)
+			if !ok {
+				t.Fatalf("car not *ast.SpecialForm. got=%T", cc.Car())
+			}
+			if spForm.Token.Type != tt.expected.Token.Type ||
+				spForm.Token.Literal != tt.expected.Token.Literal ||
+				spForm.Value != tt.expected.Value {
+				t.Fatalf("special form not %+v. got=%+v", tt.expected, spForm)
+			}
+		})
+	}
+}
+
+func TestSetq(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "set atom to symbol",
+			input: "(setq x 1)",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "set list to symbol",
+			input: "(setq x (+ 1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "set lambda function to symbol",
+			input: "(setq f (lambda (x) (+ x 1)))",
+			expected: &ast.ConsCell{
+				CarField: &ast.SpecialForm{Token: token.Token{Type: token.SETQ, Literal: "setq"}, Value: "setq"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.LAMBDA, Literal: "lambda"}, Value: "lambda"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+	}
+}
+
+func TestApplyFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.ConsCell
+	}{
+		{
+			name:  "apply function with no parameter",
+			input: "(apply f ())",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "apply function with no parameter defined by nil",
+			input: "(apply f nil)",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "apply function with one parameter",
+			input: "(apply f '(1))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "apply function with multiple parameters",
+			input: "(apply f '(1 2))",
+			expected: &ast.ConsCell{
+				CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "apply"}, Value: "apply"},
+				CdrField: &ast.ConsCell{
+					CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "f"}, Value: "f"},
+					CdrField: &ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.QUOTE, Literal: "'"}, Value: "quote"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+									CdrField: &ast.ConsCell{
+										CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+										CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+									},
+								},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+						CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != 1 {
+				t.Fatalf("program.Expressions does not contain 1 expressions. got=%d", len(program.Expressions))
+			}
+
+			cc, ok := program.Expressions[0].(*ast.ConsCell)
+			if !ok {
+				t.Fatalf("exp not *ast.ConsCell. got=%T", program.Expressions[0])
+			}
+
+			if cc.String() != tt.expected.String() {
+				t.Fatalf("cc.String() not %s. got=%s", tt.expected.String(), cc.String())
+			}
+		})
+
+	}
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *ast.Program
+	}{
+		{
+			name: "program with multiple atoms",
+			input: `
+				1
+				-10
+				hoge
+			`,
+			expected: &ast.Program{
+				Expressions: []ast.SExpression{
+					&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "-10"}, Value: -10},
+					&ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "hoge"}, Value: "hoge"},
+				},
+			},
+		},
+		{
+			name: "program with multiple lists",
+			input: `
+				(+ 1 2)
+				((lambda (x) (+ x 1)) 3)
+			`,
+			expected: &ast.Program{
+				Expressions: []ast.SExpression{
+					&ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+					&ast.ConsCell{
+						CarField: &ast.ConsCell{
+							CarField: &ast.SpecialForm{Token: token.Token{Type: token.SYMBOL, Literal: "lambda"}, Value: "lambda"},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.ConsCell{
+									CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+								CdrField: &ast.ConsCell{
+									CarField: &ast.ConsCell{
+										CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+										CdrField: &ast.ConsCell{
+											CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "x"}, Value: "x"},
+											CdrField: &ast.ConsCell{
+												CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+												CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+											},
+										},
+									},
+									CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+								},
+							},
+						},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "3"}, Value: 3},
+							CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "program with atom and list",
+			input: `
+				1
+				(+ 1 2)
+			`,
+			expected: &ast.Program{
+				Expressions: []ast.SExpression{
+					&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					&ast.ConsCell{
+						CarField: &ast.Symbol{Token: token.Token{Type: token.SYMBOL, Literal: "+"}, Value: "+"},
+						CdrField: &ast.ConsCell{
+							CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							CdrField: &ast.ConsCell{
+								CarField: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+								CdrField: &ast.Nil{Token: token.Token{Type: token.NIL, Literal: "nil"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Expressions) != len(tt.expected.Expressions) {
+				t.Fatalf("program.Expressions does not contain %d expressions. got=%d", len(tt.expected.Expressions), len(program.Expressions))
+			}
+			for i, exp := range tt.expected.Expressions {
+				if program.Expressions[i].String() != exp.String() {
+					t.Fatalf("program.Expressions[%d] not %s. got=%s", i, exp.String(), program.Expressions[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectedErrors   []string
+		expectedProgram  string
+		expectedExpected []token.TokenType
+	}{
+		{
+			name:             "extra element after dotted cdr",
+			input:            "(a . b c d) (e)",
+			expectedErrors:   []string{"1:8: expected token to be ), got SYMBOL instead"},
+			expectedProgram:  "(a . b)(e)",
+			expectedExpected: []token.TokenType{token.RPAREN},
+		},
+		{
+			name:  "several independent errors",
+			input: "(f 1/0)\n(g (h . i j) 2)\n(k 1/0 ())",
+			expectedErrors: []string{
+				"1:4: could not parse \"1/0\" as ratio",
+				"2:11: expected token to be ), got SYMBOL instead",
+				"3:4: could not parse \"1/0\" as ratio",
+			},
+			expectedProgram: "(f 1/0)(g (h . i) 2)(k 1/0 NIL)",
+		},
+		{
+			name:             "unmatched closing paren",
+			input:            "(a)) (b)",
+			expectedErrors:   []string{"1:4: could not parse \")\" as atom"},
+			expectedProgram:  "(a)(b)",
+			expectedExpected: sexpressionStart,
+		},
+		{
+			name:             "missing cdr",
+			input:            "(a . ) (b)",
+			expectedErrors:   []string{"1:6: could not parse \")\" as atom"},
+			expectedProgram:  "(a . ))(b)",
+			expectedExpected: sexpressionStart,
+		},
+		{
+			name:             "unclosed list",
+			input:            "(a (b",
+			expectedErrors:   []string{"1:1: expected token to be ) to close this list, got EOF instead"},
+			expectedProgram:  "(a (b))",
+			expectedExpected: []token.TokenType{token.RPAREN},
+		},
+		{
+			name:             "unclosed nested lists",
+			input:            "(a (b (c",
+			expectedErrors:   []string{"1:1: expected token to be ) to close this list, got EOF instead"},
+			expectedProgram:  "(a (b (c)))",
+			expectedExpected: []token.TokenType{token.RPAREN},
+		},
+		{
+			name:             "unclosed empty list",
+			input:            "(",
+			expectedErrors:   []string{"1:1: expected token to be ) to close this list, got EOF instead"},
+			expectedProgram:  "NIL",
+			expectedExpected: []token.TokenType{token.RPAREN},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+
+			errors := p.Errors()
+			if len(errors) != len(tt.expectedErrors) {
+				t.Fatalf("parser has %d errors, want %d", len(errors), len(tt.expectedErrors))
+			}
+			for i, err := range errors {
+				if got := err.Pos.String() + ": " + err.Message; got != tt.expectedErrors[i] {
+					t.Errorf("errors[%d] wrong. want=%q, got=%q", i, tt.expectedErrors[i], got)
+				}
+				if err.Actual.Type != token.EOF && err.Actual.Pos != err.Pos {
+					t.Errorf("errors[%d] actual token is not at the position of the error", i)
+				}
+			}
+			if tt.expectedExpected != nil && !slices.Equal(errors[0].Expected, tt.expectedExpected) {
+				t.Errorf("expected tokens wrong. want=%v, got=%v", tt.expectedExpected, errors[0].Expected)
+			}
+
+			if program.String() != tt.expectedProgram {
+				t.Errorf("partial program wrong. want=%q, got=%q", tt.expectedProgram, program.String())
+			}
+			for _, sexp := range program.Expressions {
+				checkNoNilNodes(t, sexp)
+			}
+		})
+	}
+}
+
+// checkNoNilNodes checks that every car and cdr in sexp is a node
+func checkNoNilNodes(t *testing.T, sexp ast.SExpression) {
+	consCell, ok := sexp.(*ast.ConsCell)
+	if !ok {
+		return
+	}
+	if consCell.CarField == nil || consCell.CdrField == nil {
+		t.Fatalf("nil node is found in %s", consCell)
+	}
+	checkNoNilNodes(t, consCell.CarField)
+	checkNoNilNodes(t, consCell.CdrField)
+}
+
+func TestComments(t *testing.T) {
+	input := `;;; double the number
+(defun double (x) ; x is a number
+  #| the body
+     #| is nested |# |#
+  (* x #;(bad 1/0) 2))`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "(defun double (x) (* x 2))"
+	if program.String() != expected {
+		t.Fatalf("program wrong. want=%q, got=%q", expected, program.String())
+	}
+
+	defun := program.Expressions[0].(*ast.ConsCell).Car().(*ast.SpecialForm)
+	if len(defun.Token.Comments) != 0 {
+		t.Fatalf("comments are attached to the wrong token: %v", defun.Token.Comments)
+	}
+}
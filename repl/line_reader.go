@@ -0,0 +1,107 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/peterh/liner"
+)
+
+// HistoryFile is the name of the file in the home directory which keeps the input of the terminal
+const HistoryFile = ".go_lisp_history"
+
+// errInterrupted is returned by ReadLine when Ctrl-C is typed
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads a line of the input after showing the prompt
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	Close() error
+}
+
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if f, ok := in.(*os.File); ok && IsTerminal(f) {
+		return newTerminalReader()
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// IsTerminal reports whether the file is a terminal rather than a pipe or a regular file
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	_, _ = io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+func (r *scannerReader) Close() error {
+	return nil
+}
+
+// terminalReader edits the line like readline and keeps the history in the history file
+type terminalReader struct {
+	state       *liner.State
+	historyPath string
+}
+
+func newTerminalReader() *terminalReader {
+	state := liner.NewLiner()
+	state.SetCtrlCAborts(true)
+
+	r := &terminalReader{state: state}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.historyPath = filepath.Join(home, HistoryFile)
+		if f, err := os.Open(r.historyPath); err == nil {
+			_, _ = state.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	return r
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	line, err := r.state.Prompt(prompt)
+	if errors.Is(err, liner.ErrPromptAborted) {
+		return "", errInterrupted
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if line != "" {
+		r.state.AppendHistory(line)
+	}
+	return line, nil
+}
+
+// Close saves the history and restores the terminal
+func (r *terminalReader) Close() error {
+	if r.historyPath != "" {
+		if f, err := os.Create(r.historyPath); err == nil {
+			_, _ = r.state.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return r.state.Close()
+}
@@ -1,55 +1,234 @@
-package repl
-
-import (
-	"bufio"
-	"fmt"
-	"io"
-
-	"github.com/JunNishimura/go-lisp/evaluator"
-	"github.com/JunNishimura/go-lisp/lexer"
-	"github.com/JunNishimura/go-lisp/object"
-	"github.com/JunNishimura/go-lisp/parser"
-)
-
-const PROMPT = ">> "
-
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
-
-	for {
-		fmt.Printf("%s", PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
-		}
-
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
-		}
-
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
-
-		evaluated := evaluator.Eval(expanded, env)
-		if evaluated != nil {
-			_, _ = io.WriteString(out, evaluated.Inspect())
-			_, _ = io.WriteString(out, "\n")
-		}
-	}
-}
-
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		if _, err := io.WriteString(out, "\t"+msg+"\n"); err != nil {
-			return
-		}
-	}
-}
+package repl
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/compiler"
+	"github.com/JunNishimura/go-lisp/evaluator"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+	"github.com/JunNishimura/go-lisp/vm"
+)
+
+const (
+	PROMPT = ">> "
+	// CONTINUATION_PROMPT is shown while the parentheses or the string literal of the form are not closed
+	CONTINUATION_PROMPT = ".. "
+)
+
+// Backend is the way the expanded program is executed
+type Backend string
+
+const (
+	// BackendEval evaluates the program by walking the tree
+	BackendEval Backend = "eval"
+	// BackendVM compiles the program to bytecode and runs it on the vm
+	BackendVM Backend = "vm"
+)
+
+func Start(in io.Reader, out io.Writer, backend Backend) {
+	reader := newLineReader(in, out)
+	defer reader.Close()
+
+	env := object.NewEnvironment()
+	env.SetOutput(out)
+
+	for {
+		input, err := readForm(reader)
+		if errors.Is(err, errInterrupted) {
+			// Ctrl-C discards the form being typed
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		l := lexer.New(input)
+		p := parser.New(l)
+
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParserErrors(out, p.Errors())
+			continue
+		}
+		if len(program.Expressions) == 0 {
+			continue
+		}
+
+		evaluated := runInterruptibly(program, env, backend)
+		if err, ok := evaluated.(*object.Error); ok {
+			_, _ = io.WriteString(out, err.Report())
+			_, _ = io.WriteString(out, "\n")
+		} else if evaluated != nil {
+			_, _ = io.WriteString(out, evaluated.Inspect())
+			_, _ = io.WriteString(out, "\n")
+		}
+	}
+}
+
+// readForm reads the lines until the parentheses and the string literals are balanced
+func readForm(reader lineReader) (string, error) {
+	lines := []string{}
+	prompt := PROMPT
+
+	for {
+		line, err := reader.ReadLine(prompt)
+		if err != nil {
+			// the incomplete form at the end of the input is left to the parser to report
+			if errors.Is(err, io.EOF) && len(lines) > 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if isBalanced(input) {
+			return input, nil
+		}
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// isBalanced reports whether all the parentheses and the string literals in the input are closed.
+// the extra closing parentheses are left to the parser to report.
+func isBalanced(input string) bool {
+	depth := 0
+	inString := false
+	// inSymbol is true inside the multiple escape of a symbol, such as |a(b|
+	inSymbol := false
+	inComment := false
+	// blockDepth is the depth of the nested block comments #| ... |#
+	blockDepth := 0
+
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+		switch {
+		case inComment:
+			inComment = ch != '\n'
+		case blockDepth > 0:
+			if ch == '|' && i+1 < len(input) && input[i+1] == '#' {
+				blockDepth--
+				i++
+			} else if ch == '#' && i+1 < len(input) && input[i+1] == '|' {
+				blockDepth++
+				i++
+			}
+		case inString:
+			switch ch {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+		case inSymbol:
+			switch ch {
+			case '\\':
+				i++
+			case '|':
+				inSymbol = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == '\\':
+			// the escaped character, such as the paren in a\(b, is a part of the symbol
+			i++
+		case ch == ';':
+			inComment = true
+		case ch == '#' && i+1 < len(input) && input[i+1] == '|':
+			blockDepth++
+			i++
+		case ch == '|':
+			inSymbol = true
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		}
+	}
+
+	return depth <= 0 && !inString && !inSymbol && blockDepth == 0
+}
+
+// runInterruptibly expands and runs the program, which Ctrl-C stops with an error instead of killing the process
+func runInterruptibly(program *ast.Program, env *object.Environment, backend Backend) object.Object {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				evaluator.Interrupt()
+			case <-done:
+				return
+			}
+		}
+	}()
+	defer func() {
+		signal.Stop(sigs)
+		close(done)
+		evaluator.ClearInterrupt()
+	}()
+
+	evaluator.ClearInterrupt()
+	return runForms(program, env, backend)
+}
+
+func run(program ast.SExpression, env *object.Environment, backend Backend) object.Object {
+	if backend != BackendVM {
+		return evaluator.Eval(program, env)
+	}
+
+	expanded, ok := program.(*ast.Program)
+	if !ok {
+		return evaluator.Eval(program, env)
+	}
+
+	c := compiler.New()
+	c.Compile(expanded)
+	return vm.Run(c.Bytecode(), env)
+}
+
+// runForms expands and runs the top-level forms one at a time,
+// so that each form can use the macros and the functions defined by the forms before it.
+// it returns the value of the last form, or the first error.
+func runForms(program *ast.Program, env *object.Environment, backend Backend) object.Object {
+	macroEnv := env.MacroEnvironment()
+
+	var result object.Object
+	for _, form := range program.Expressions {
+		expanded, err := evaluator.ExpandMacros(&ast.Program{Expressions: []ast.SExpression{form}}, macroEnv)
+		if err != nil {
+			return err
+		}
+		// the top-level defmacro is removed by the expansion and has no value
+		if len(expanded.(*ast.Program).Expressions) == 0 {
+			continue
+		}
+
+		result = run(expanded, env, backend)
+		if _, ok := result.(*object.Error); ok {
+			return result
+		}
+	}
+
+	return result
+}
+
+func printParserErrors(out io.Writer, errors []*parser.ParseError) {
+	for _, parseErr := range errors {
+		// every line is indented so that the caret stays under the source line
+		for _, line := range strings.Split(parseErr.Error(), "\n") {
+			if _, err := io.WriteString(out, "\t"+line+"\n"); err != nil {
+				return
+			}
+		}
+	}
+}
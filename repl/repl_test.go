@@ -0,0 +1,150 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIsBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", true},
+		{"(+ 1 2)", true},
+		{"(defun f (x)", false},
+		{"(defun f (x)\n  (+ x 1))", true},
+		{"\"(\"", true},
+		{"(print \"a)", false},
+		{"(print \"a\\\")\")", true},
+		{"(f ; )\n", false},
+		{"(+ 1 2))", true},
+		{"#| (f |#", true},
+		{"(f #| ) |#", false},
+		{"#| #| |# (", false},
+		{"#| #| |# |#", true},
+		{"(list '|a(b|)", true},
+		{"(list '|a)b|", false},
+		{"'|a)b|", true},
+		{"(f '|a\\|)|", false},
+		{"(f '|a\\|)|)", true},
+		{"'|abc", false},
+		{"(list 'a\\()", true},
+		{"(list 'a\\))", true},
+	}
+
+	for _, tt := range tests {
+		if got := isBalanced(tt.input); got != tt.expected {
+			t.Errorf("isBalanced(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	input := "(defun f (x)\n  (+ x\n     1))\n(f 2) (f 3)\n\n\"a\n(b\"\n(f 'a)\n(+ 1\n"
+	expected := ">> .. .. f\n>> 4\n>> >> .. \"a\n(b\"\n" +
+		">> 2:3: ERROR: argument to `+` must be NUMBER, got SYMBOL\n  (+ x\n  ^\n" +
+		">> .. \t1:1: expected token to be ) to close this list, got EOF instead\n\t(+ 1\n\t^\n>> "
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, BackendEval)
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\ngot=%q\nwant=%q", out.String(), expected)
+	}
+}
+
+func TestScriptOutput(t *testing.T) {
+	for _, backend := range []Backend{BackendEval, BackendVM} {
+		script := &Script{Name: "test.lisp", Source: `(format t "hello ~a~%" 1) (format t "bye")`, Backend: backend}
+
+		var out, errOut bytes.Buffer
+		if status := script.Run(&out, &errOut); status != ExitOK {
+			t.Fatalf("backend=%s: exit status is %d. errors=%q", backend, status, errOut.String())
+		}
+		if out.String() != "hello 1\nbye" {
+			t.Errorf("backend=%s: wrong output. got=%q", backend, out.String())
+		}
+	}
+}
+
+func TestScriptsInParallel(t *testing.T) {
+	// the interpreters share no state, so each script prints only to its own output
+	source := `(defmacro twice (x) (list 'progn x x)) (defun f (i) (when (< i 100) (twice (format t "~a" i)) (f (+ i 1)))) (f 0)`
+	expected := ""
+	for i := 0; i < 100; i++ {
+		expected += fmt.Sprintf("%d%d", i, i)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		backend := []Backend{BackendEval, BackendVM}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			script := &Script{Name: "test.lisp", Source: source, Backend: backend}
+
+			var out, errOut bytes.Buffer
+			if status := script.Run(&out, &errOut); status != ExitOK {
+				t.Errorf("backend=%s: exit status is %d. errors=%q", backend, status, errOut.String())
+				return
+			}
+			if out.String() != expected {
+				t.Errorf("backend=%s: wrong output. got=%q", backend, out.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestScriptMacros(t *testing.T) {
+	dir := t.TempDir()
+	utils := filepath.Join(dir, "utils.lisp")
+	if err := os.WriteFile(utils, []byte("(defmacro twice (x) (list '+ x x)) (provide 'utils)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOLISP_PATH", dir)
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"(require 'utils) (twice 5)", "10\n"},
+		{fmt.Sprintf("(load %q) (twice 6)", utils), "12\n"},
+		{"(defun square-form (x) (list '* x x)) (defmacro square-of (x) (square-form x)) (square-of 4)", "16\n"},
+		{"(+ 1 2) (defmacro m () 1)", "3\n"},
+	}
+
+	for _, backend := range []Backend{BackendEval, BackendVM} {
+		for _, tt := range tests {
+			script := &Script{Name: "test.lisp", Source: tt.source, Backend: backend, PrintResult: true}
+
+			var out, errOut bytes.Buffer
+			if status := script.Run(&out, &errOut); status != ExitOK {
+				t.Fatalf("backend=%s, source=%s: exit status is %d. errors=%q", backend, tt.source, status, errOut.String())
+			}
+			if out.String() != tt.expected {
+				t.Errorf("backend=%s, source=%s: wrong output. got=%q, want=%q", backend, tt.source, out.String(), tt.expected)
+			}
+		}
+	}
+}
+
+func TestStartMacroError(t *testing.T) {
+	input := "(defmacro m (x) x)\n(m)\n(defmacro m () (car 1))\n(m)\n(defmacro m (x) x)\n(m 1)\n"
+	expected := ">> >> 1:1: ERROR: macro m: function expects 1 arguments, but got 0\n(m)\n^\n" +
+		">> >> 1:16: ERROR: argument to `car` must be LIST, got INTEGER\n(defmacro m () (car 1))\n               ^\n" +
+		">> >> 1\n>> "
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, BackendEval)
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\ngot=%q\nwant=%q", out.String(), expected)
+	}
+}
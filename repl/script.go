@@ -0,0 +1,76 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/JunNishimura/go-lisp/evaluator"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+// the exit statuses of the script
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitParseError = 2
+)
+
+// CommandLineArgs is the variable bound to the list of the arguments to the script
+const CommandLineArgs = "*command-line-args*"
+
+// Script is the program which is run non-interactively, such as a file, the -e expression or the piped input
+type Script struct {
+	// Name is the file name shown in the error messages
+	Name   string
+	Source string
+	// Args are bound to *command-line-args* as the list of strings
+	Args    []string
+	Backend Backend
+	// PrintResult writes the value of the last form to the output
+	PrintResult bool
+}
+
+// Run parses and runs the whole script, and returns the exit status.
+// the parse errors and the uncaught error are written to errOut.
+func (s *Script) Run(out, errOut io.Writer) int {
+	l := lexer.NewFile(s.Name, s.Source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		// the parser errors already begin with the name of the script
+		for _, err := range p.Errors() {
+			fmt.Fprintln(errOut, err.Error())
+		}
+		return ExitParseError
+	}
+
+	env := object.NewEnvironment()
+	env.SetOutput(out)
+	env.Set(CommandLineArgs, stringList(s.Args))
+
+	evaluated := runForms(program, env, s.Backend)
+	if err, ok := evaluated.(*object.Error); ok {
+		if err.Pos.IsValid() {
+			fmt.Fprintln(errOut, err.Report())
+		} else {
+			fmt.Fprintf(errOut, "%s: %s\n", s.Name, err.Inspect())
+		}
+		return ExitError
+	}
+
+	if s.PrintResult && evaluated != nil {
+		fmt.Fprintln(out, evaluated.Inspect())
+	}
+	return ExitOK
+}
+
+func stringList(strs []string) object.Object {
+	var list object.Object = evaluator.Nil
+	for i := len(strs) - 1; i >= 0; i-- {
+		list = &object.ConsCell{Car: &object.String{Value: strs[i]}, Cdr: list}
+	}
+	return list
+}
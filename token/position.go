@@ -0,0 +1,100 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Source is the text which the tokens are read from.
+// Name is the file name, or empty if the text is not read from a file.
+type Source struct {
+	Name string
+	Text string
+}
+
+// Line returns the n-th line of the source without the line terminator.
+// it returns an empty string if the source does not have the line.
+func (s *Source) Line(n int) string {
+	text := s.Text
+	for i := 1; i < n; i++ {
+		newline := strings.IndexByte(text, '\n')
+		if newline < 0 {
+			return ""
+		}
+		text = text[newline+1:]
+	}
+
+	if newline := strings.IndexByte(text, '\n'); newline >= 0 {
+		text = text[:newline]
+	}
+	return strings.TrimSuffix(text, "\r")
+}
+
+// Position is a location in the source.
+// Line and Column start at 1, and Column counts characters rather than bytes.
+type Position struct {
+	Source *Source
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is set.
+// the nodes made by macros have no position unless it is filled in from the macro call.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Filename returns the name of the source, or an empty string if it is unknown
+func (p Position) Filename() string {
+	if p.Source == nil {
+		return ""
+	}
+	return p.Source.Name
+}
+
+// String returns the position as file:line:col, or line:col if the file name is unknown
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if name := p.Filename(); name != "" {
+		return fmt.Sprintf("%s:%d:%d", name, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Annotate prefixes msg with the position and appends the source line with a caret under the column.
+// msg is returned as it is if the position is not set.
+func (p Position) Annotate(msg string) string {
+	if !p.IsValid() {
+		return msg
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s: %s", p, msg)
+
+	if p.Source == nil {
+		return out.String()
+	}
+	line := p.Source.Line(p.Line)
+	out.WriteString("\n")
+	out.WriteString(line)
+	out.WriteString("\n")
+	// tabs are kept so that the caret is aligned with the line however wide a tab is shown
+	column := 1
+	for _, ch := range line {
+		if column >= p.Column {
+			break
+		}
+		if ch == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+		column++
+	}
+	out.WriteString("^")
+
+	return out.String()
+}
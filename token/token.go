@@ -1,54 +1,154 @@
-package token
-
-type TokenType string
-
-type Token struct {
-	Type    TokenType
-	Literal string
-}
-
-const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
-
-	// Symbols  + literals
-	SYMBOL = "SYMBOL"
-	INT    = "INT"
-
-	// Special Form
-	LAMBDA = "LAMBDA"
-	QUOTE  = "'"
-	IF     = "IF"
-	SETQ   = "SETQ"
-
-	PLUS  = "+"
-	MINUS = "-"
-
-	DOT       = "."
-	BACKQUOTE = "`"
-	COMMA     = ","
-
-	// Delimiters
-	LPAREN = "("
-	RPAREN = ")"
-
-	// Keywords
-	NIL  = "nil"
-	TRUE = "t"
-)
-
-var keywords = map[string]TokenType{
-	"nil":    NIL,
-	"t":      TRUE,
-	"lambda": LAMBDA,
-	"quote":  QUOTE,
-	"if":     IF,
-	"setq":   SETQ,
-}
-
-func LookupKeyword(symbol string) TokenType {
-	if tok, ok := keywords[symbol]; ok {
-		return tok
-	}
-	return SYMBOL
-}
+package token
+
+type TokenType string
+
+// Token is the unit read by the lexer.
+// Pos is the position of the first character of the token and End is the position just after the last one.
+// Comments are the comments between the previous token and this one, which the parser ignores.
+// Err is the reason why the ILLEGAL token could not be read, if the lexer knows it.
+type Token struct {
+	Type     TokenType
+	Literal  string
+	Pos      Position
+	End      Position
+	Comments []Comment
+	Err      *TokenError
+}
+
+// TokenError is the reason why the token is illegal.
+// Pos is the position of the offending part of the token, such as the invalid escape in a string literal.
+type TokenError struct {
+	Message string
+	Pos     Position
+}
+
+// Comment is a line comment (; ...), a block comment (#| ... |#) or a datum comment (#; followed by an S-expression).
+// Text is the comment as it is written in the source, including the delimiters.
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
+}
+
+const (
+	ILLEGAL = "ILLEGAL"
+	EOF     = "EOF"
+
+	// Symbols  + literals
+	SYMBOL = "SYMBOL"
+	// KEYWORD is the symbol in the keyword package, such as :test
+	KEYWORD = "KEYWORD"
+	INT     = "INT"
+	FLOAT   = "FLOAT"
+	RATIO   = "RATIO"
+	STRING  = "STRING"
+
+	// Special Form
+	LAMBDA  = "LAMBDA"
+	QUOTE   = "'"
+	IF      = "IF"
+	SETQ    = "SETQ"
+	DEFUN   = "DEFUN"
+	PROGN   = "PROGN"
+	PROG1   = "PROG1"
+	PROG2   = "PROG2"
+	LET     = "LET"
+	LETSTAR = "LET*"
+	FLET    = "FLET"
+	LABELS  = "LABELS"
+
+	COND      = "COND"
+	WHEN      = "WHEN"
+	UNLESS    = "UNLESS"
+	AND       = "AND"
+	OR        = "OR"
+	CASE      = "CASE"
+	ECASE     = "ECASE"
+	TYPECASE  = "TYPECASE"
+	ETYPECASE = "ETYPECASE"
+
+	BLOCK          = "BLOCK"
+	RETURN_FROM    = "RETURN-FROM"
+	RETURN         = "RETURN"
+	TAGBODY        = "TAGBODY"
+	GO             = "GO"
+	CATCH          = "CATCH"
+	THROW          = "THROW"
+	UNWIND_PROTECT = "UNWIND-PROTECT"
+
+	HANDLER_CASE     = "HANDLER-CASE"
+	HANDLER_BIND     = "HANDLER-BIND"
+	IGNORE_ERRORS    = "IGNORE-ERRORS"
+	RESTART_CASE     = "RESTART-CASE"
+	DEFINE_CONDITION = "DEFINE-CONDITION"
+
+	// FUNCTION is both the special form and its reader macro #'
+	FUNCTION = "FUNCTION"
+
+	DOT       = "."
+	BACKQUOTE = "`"
+	COMMA     = ","
+	COMMA_AT  = ",@"
+
+	// Delimiters
+	LPAREN = "("
+	RPAREN = ")"
+
+	// Keywords
+	NIL  = "nil"
+	TRUE = "t"
+)
+
+var keywords = map[string]TokenType{
+	"nil":    NIL,
+	"t":      TRUE,
+	"lambda": LAMBDA,
+	"quote":  QUOTE,
+	// the names which the reader gives to ` and ,
+	"backquote":        BACKQUOTE,
+	"unquote":          COMMA,
+	"unquote-splicing": COMMA_AT,
+	"if":               IF,
+	"setq":             SETQ,
+	"defun":            DEFUN,
+	"function":         FUNCTION,
+	"progn":            PROGN,
+	"prog1":            PROG1,
+	"prog2":            PROG2,
+	"let":              LET,
+	"let*":             LETSTAR,
+	"flet":             FLET,
+	"labels":           LABELS,
+	"cond":             COND,
+	"when":             WHEN,
+	"unless":           UNLESS,
+	"and":              AND,
+	"or":               OR,
+	"case":             CASE,
+	"ecase":            ECASE,
+	"typecase":         TYPECASE,
+	"etypecase":        ETYPECASE,
+	"block":            BLOCK,
+	"return-from":      RETURN_FROM,
+	"return":           RETURN,
+	"tagbody":          TAGBODY,
+	"go":               GO,
+	"catch":            CATCH,
+	"throw":            THROW,
+	"unwind-protect":   UNWIND_PROTECT,
+	"handler-case":     HANDLER_CASE,
+	"handler-bind":     HANDLER_BIND,
+	"ignore-errors":    IGNORE_ERRORS,
+	"restart-case":     RESTART_CASE,
+	"define-condition": DEFINE_CONDITION,
+}
+
+func LookupKeyword(symbol string) TokenType {
+	if tok, ok := keywords[symbol]; ok {
+		return tok
+	}
+	if len(symbol) > 1 && symbol[0] == ':' {
+		return KEYWORD
+	}
+	return SYMBOL
+}
@@ -0,0 +1,414 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/JunNishimura/go-lisp/ast"
+	"github.com/JunNishimura/go-lisp/compiler"
+	"github.com/JunNishimura/go-lisp/evaluator"
+	"github.com/JunNishimura/go-lisp/object"
+)
+
+const (
+	initialStackSize = 256
+	// MaxFrames limits the depth of the calls which are not tail calls
+	MaxFrames = 1 << 20
+)
+
+// Cell holds the local variable captured by closures, which share it
+type Cell struct {
+	Value object.Object
+}
+
+func (c *Cell) Type() object.ObjectType { return object.CELL_OBJ }
+func (c *Cell) Inspect() string         { return "#<cell " + c.Value.Inspect() + ">" }
+
+// Closure is the compiled function with the cells of the variables it captures.
+// it can be called by the evaluator as well.
+type Closure struct {
+	Fn   *compiler.CompiledFunction
+	Free []*Cell
+	// Env is the global environment in which the closure was made
+	Env *object.Environment
+}
+
+func (c *Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (c *Closure) Inspect() string         { return c.Fn.Inspect() }
+
+func (c *Closure) Apply(env *object.Environment, args []object.Object) object.Object {
+	return newVM(c.Env).call(c, args)
+}
+
+type Frame struct {
+	cl          *Closure
+	ip          int
+	basePointer int
+	constants   []object.Object
+}
+
+type VM struct {
+	env *object.Environment
+
+	stack []object.Object
+	// sp points to the next free slot. the top of the stack is stack[sp-1].
+	sp int
+
+	frames []Frame
+}
+
+func newVM(env *object.Environment) *VM {
+	return &VM{
+		env:   env,
+		stack: make([]object.Object, initialStackSize),
+	}
+}
+
+// Run executes the bytecode in the global environment and returns the value of the last top-level form
+func Run(bytecode *compiler.Bytecode, env *object.Environment) object.Object {
+	main := &Closure{Fn: bytecode.Main, Env: env}
+	return evaluator.ProgramResult(newVM(env).call(main, nil))
+}
+
+// call runs the closure until it returns, or an error or a non-local exit aborts it
+func (vm *VM) call(cl *Closure, args []object.Object) object.Object {
+	vm.push(cl)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	if err := vm.pushFrame(cl, len(args)); err != nil {
+		return evaluator.SignalError(err, vm.env)
+	}
+
+	return vm.run()
+}
+
+func (vm *VM) run() object.Object {
+	for {
+		frame := &vm.frames[len(vm.frames)-1]
+		ins := frame.cl.Fn.Instructions
+		frame.ip++
+		ip := frame.ip
+		op := compiler.Opcode(ins[ip])
+
+		switch op {
+		case compiler.OpConstant:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(frame.constants[index])
+
+		case compiler.OpQuote:
+			datum := frame.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Datum)
+			frame.ip += 2
+			vm.push(vm.quote(datum))
+
+		case compiler.OpNil:
+			vm.push(evaluator.Nil)
+
+		case compiler.OpTrue:
+			vm.push(evaluator.True)
+
+		case compiler.OpPop:
+			vm.pop()
+
+		case compiler.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case compiler.OpJump:
+			frame.ip = int(compiler.ReadUint16(ins[ip+1:])) - 1
+
+		case compiler.OpJumpIfNil:
+			frame.ip += 2
+			if _, ok := vm.pop().(*object.Nil); ok {
+				frame.ip = int(compiler.ReadUint16(ins[ip+1:])) - 1
+			}
+
+		case compiler.OpGetGlobal:
+			name := frame.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Name)
+			frame.ip += 2
+			value, ok := vm.env.Get(name.Value)
+			if !ok {
+				// the evaluator reports the unbound variable
+				return vm.positioned(evaluator.Eval(&ast.Symbol{Value: name.Value}, vm.env), frame, ip)
+			}
+			vm.push(value)
+
+		case compiler.OpSetGlobal:
+			name := frame.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Name)
+			frame.ip += 2
+			vm.env.Assign(name.Value, vm.pop())
+
+		case compiler.OpGetFunction:
+			name := frame.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Name)
+			frame.ip += 2
+			fn := vm.lookupFunction(name)
+			if evaluator.IsUnwinding(fn) {
+				return vm.positioned(evaluator.SignalError(fn, vm.env), frame, ip)
+			}
+			vm.push(fn)
+
+		case compiler.OpGetLocal:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(vm.stack[frame.basePointer+int(index)])
+
+		case compiler.OpSetLocal:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.stack[frame.basePointer+int(index)] = vm.pop()
+
+		case compiler.OpMakeCell:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.stack[frame.basePointer+int(index)] = &Cell{Value: vm.pop()}
+
+		case compiler.OpGetCell:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.push(vm.stack[frame.basePointer+int(index)].(*Cell).Value)
+
+		case compiler.OpSetCell:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.stack[frame.basePointer+int(index)].(*Cell).Value = vm.pop()
+
+		case compiler.OpGetFree:
+			index := compiler.ReadUint8(ins[ip+1:])
+			frame.ip++
+			vm.push(frame.cl.Free[index].Value)
+
+		case compiler.OpSetFree:
+			index := compiler.ReadUint8(ins[ip+1:])
+			frame.ip++
+			frame.cl.Free[index].Value = vm.pop()
+
+		case compiler.OpGetFreeCell:
+			index := compiler.ReadUint8(ins[ip+1:])
+			frame.ip++
+			vm.push(frame.cl.Free[index])
+
+		case compiler.OpClosure:
+			index := compiler.ReadUint16(ins[ip+1:])
+			numFree := int(compiler.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+
+			free := make([]*Cell, numFree)
+			for i := 0; i < numFree; i++ {
+				free[i] = vm.stack[vm.sp-numFree+i].(*Cell)
+			}
+			vm.sp -= numFree
+
+			fn := frame.constants[index].(*compiler.CompiledFunction)
+			vm.push(&Closure{Fn: fn, Free: free, Env: vm.env})
+
+		case compiler.OpCall, compiler.OpTailCall:
+			numArgs := int(compiler.ReadUint8(ins[ip+1:]))
+			frame.ip++
+
+			callee := vm.stack[vm.sp-1-numArgs]
+			cl, ok := callee.(*Closure)
+			if !ok {
+				result := vm.callForeign(callee, numArgs)
+				if evaluator.IsUnwinding(result) {
+					return vm.positioned(result, frame, ip)
+				}
+				if op == compiler.OpCall {
+					vm.push(result)
+					continue
+				}
+				if result, done := vm.returnFrom(result); done {
+					return result
+				}
+				continue
+			}
+
+			if op == compiler.OpTailCall {
+				// the callee and the arguments replace the frame of the caller
+				start := vm.sp - 1 - numArgs
+				base := frame.basePointer - 1
+				copy(vm.stack[base:], vm.stack[start:vm.sp])
+				vm.sp = base + 1 + numArgs
+				vm.frames = vm.frames[:len(vm.frames)-1]
+			}
+			// the frame of the caller is still readable after the tail call removed it
+			pos := frame.cl.Fn.PositionAt(ip)
+			if err := vm.pushFrame(cl, numArgs); err != nil {
+				if !err.Pos.IsValid() {
+					err.Pos = pos
+				}
+				return evaluator.SignalError(err, vm.env)
+			}
+
+		case compiler.OpReturn:
+			if result, done := vm.returnFrom(vm.pop()); done {
+				return result
+			}
+
+		case compiler.OpDefun:
+			name := frame.constants[compiler.ReadUint16(ins[ip+1:])].(*compiler.Name)
+			frame.ip += 2
+			symbol := vm.intern(name)
+			symbol.Function = vm.pop()
+			vm.push(symbol)
+
+		case compiler.OpEval:
+			index := compiler.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			form := frame.cl.Fn.Bytecode.Forms[index]
+			result := evaluator.Eval(&ast.Program{Expressions: []ast.SExpression{form}}, vm.env)
+			if evaluator.IsUnwinding(result) {
+				return result
+			}
+			vm.push(result)
+
+		default:
+			return &object.Error{Message: fmt.Sprintf("unknown opcode: %d", op)}
+		}
+	}
+}
+
+// positioned sets the position of the error which has none to that of the form
+// which the instruction at ip of the frame is compiled from, as the evaluator points to the innermost form
+func (vm *VM) positioned(result object.Object, frame *Frame, ip int) object.Object {
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = frame.cl.Fn.PositionAt(ip)
+	}
+	return result
+}
+
+// pushFrame enters the closure whose arguments are on the stack
+func (vm *VM) pushFrame(cl *Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+
+	if numArgs < fn.NumParameters || (!fn.Rest && numArgs > fn.NumParameters) {
+		if fn.Rest {
+			return &object.Error{Message: fmt.Sprintf("function expects at least %d arguments, but got %d", fn.NumParameters, numArgs)}
+		}
+		return &object.Error{Message: fmt.Sprintf("function expects %d arguments, but got %d", fn.NumParameters, numArgs)}
+	}
+	if err := evaluator.CheckInterrupt(); err != nil {
+		return err
+	}
+	if len(vm.frames) >= MaxFrames {
+		return &object.Error{Message: "stack overflow"}
+	}
+
+	basePointer := vm.sp - numArgs
+	if fn.Rest {
+		var rest object.Object = evaluator.Nil
+		for i := vm.sp - 1; i >= basePointer+fn.NumParameters; i-- {
+			rest = &object.ConsCell{Car: vm.stack[i], Cdr: rest}
+		}
+		vm.sp = basePointer + fn.NumParameters
+		vm.push(rest)
+	}
+
+	// the slots of the local variables are cleared
+	vm.ensureStack(basePointer + fn.NumLocals)
+	for i := vm.sp; i < basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = evaluator.Nil
+	}
+	vm.sp = basePointer + fn.NumLocals
+
+	vm.frames = append(vm.frames, Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+		constants:   fn.Bytecode.Constants,
+	})
+
+	return nil
+}
+
+// returnFrom pops the frame and pushes the result for the caller.
+// done is true if the frame was entered by call.
+func (vm *VM) returnFrom(result object.Object) (object.Object, bool) {
+	frame := vm.frames[len(vm.frames)-1]
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	// the callee is removed as well as the locals
+	vm.sp = frame.basePointer - 1
+	if len(vm.frames) == 0 {
+		return result, true
+	}
+
+	vm.push(result)
+	return nil, false
+}
+
+// callForeign calls the function which is not compiled, such as a builtin function or a closure of the evaluator
+func (vm *VM) callForeign(callee object.Object, numArgs int) object.Object {
+	var result object.Object
+	if builtin, ok := callee.(*object.Builtin); ok {
+		// the arguments are passed on the stack, whose capacity is limited so that append does not overwrite it
+		result = builtin.Fn(vm.env, vm.stack[vm.sp-numArgs:vm.sp:vm.sp]...)
+	} else {
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		result = evaluator.Apply(callee, args, vm.env)
+	}
+	vm.sp = vm.sp - 1 - numArgs
+
+	return evaluator.SignalError(result, vm.env)
+}
+
+// lookupFunction returns the function in the function cell of the symbol, or the builtin function
+func (vm *VM) lookupFunction(name *compiler.Name) object.Object {
+	symbol := vm.intern(name)
+	if symbol.Function != nil {
+		return symbol.Function
+	}
+	if name.Builtin != nil {
+		return name.Builtin
+	}
+
+	fn := evaluator.LookupFunction(name.Value, vm.env)
+	if builtin, ok := fn.(*object.Builtin); ok {
+		name.Builtin = builtin
+	}
+	return fn
+}
+
+// intern returns the symbol of the name, which is cached while the environment is the same
+func (vm *VM) intern(name *compiler.Name) *object.Symbol {
+	if name.Env != vm.env {
+		name.Env = vm.env
+		name.Symbol = vm.env.Intern(name.Value)
+		name.Builtin = nil
+	}
+	return name.Symbol
+}
+
+// quote returns the data of the datum, which is cached while the environment is the same
+func (vm *VM) quote(datum *compiler.Datum) object.Object {
+	if datum.Env != vm.env {
+		datum.Env = vm.env
+		datum.Object = evaluator.Quote(datum.Value, vm.env)
+	}
+	return datum.Object
+}
+
+func (vm *VM) push(obj object.Object) {
+	vm.ensureStack(vm.sp + 1)
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) ensureStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
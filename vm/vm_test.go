@@ -0,0 +1,215 @@
+package vm
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JunNishimura/go-lisp/compiler"
+	"github.com/JunNishimura/go-lisp/evaluator"
+	"github.com/JunNishimura/go-lisp/lexer"
+	"github.com/JunNishimura/go-lisp/object"
+	"github.com/JunNishimura/go-lisp/parser"
+)
+
+func testRun(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	c := compiler.New()
+	c.Compile(program)
+	return Run(c.Bytecode(), env)
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return evaluator.Eval(program, env)
+}
+
+// evaluatorTestInputs collects the inputs of the test suite of the evaluator,
+// which are the first fields of the test cases and the arguments to testEval
+func evaluatorTestInputs(t *testing.T) []string {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "../evaluator/evaluator_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse the evaluator tests: %v", err)
+	}
+
+	inputs := []string{}
+	addInput := func(expr goast.Expr) {
+		lit, ok := expr.(*goast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return
+		}
+		input, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatalf("failed to unquote %s: %v", lit.Value, err)
+		}
+		inputs = append(inputs, input)
+	}
+
+	goast.Inspect(file, func(n goast.Node) bool {
+		switch n := n.(type) {
+		case *goast.FuncDecl:
+			// the inputs of TestInterrupt run forever until they are interrupted
+			return n.Name.Name != "TestInterrupt"
+		case *goast.CompositeLit:
+			if len(n.Elts) > 1 {
+				addInput(n.Elts[0])
+			}
+		case *goast.CallExpr:
+			if ident, ok := n.Fun.(*goast.Ident); ok && ident.Name == "testEval" && len(n.Args) == 1 {
+				addInput(n.Args[0])
+			}
+		}
+		return true
+	})
+
+	return inputs
+}
+
+func TestEvaluatorTestSuite(t *testing.T) {
+	inputs := evaluatorTestInputs(t)
+	if len(inputs) < 100 {
+		t.Fatalf("too few inputs are collected. got=%d", len(inputs))
+	}
+
+	for _, input := range inputs {
+		expected := testEval(input)
+		got := testRun(input)
+		if got.Inspect() != expected.Inspect() {
+			t.Errorf("result differs from the evaluator for %q. got=%s, want=%s", input, got.Inspect(), expected.Inspect())
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(let ((n 0)) (defun counter () (setq n (+ n 1)))) (counter) (counter)", "2"},
+		{"(defun make-adder (x) (lambda (y) (+ x y))) (funcall (make-adder 3) 4)", "7"},
+		{"(defun make-counter () (let ((n 0)) (lambda () (setq n (+ n 1))))) (let ((c (make-counter))) (funcall c) (funcall c))", "2"},
+		{"(let ((x 1)) (let ((f (lambda () x))) (setq x 2) (funcall f)))", "2"},
+		{"(defun outer (x) (lambda () (lambda () x))) (funcall (funcall (outer 5)))", "5"},
+		{"(defun f (a &rest rest) (cons a rest)) (f 1 2 3)", "(1 2 3)"},
+		{"(let* ((x 1) (y (+ x 1))) (list x y))", "(1 2)"},
+		{"(lambda (x) x (+ x 1))", "(lambda (x) x (+ x 1))"},
+		{"(defun f (x) x) (f 1 2)", "ERROR: function expects 1 arguments, but got 2"},
+		{"(defun :f () 1)", "ERROR: keyword :f cannot be a function name"},
+		{"(handler-case (funcall (lambda () (car 1))) (error () 'caught))", "caught"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testRun(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	var literals strings.Builder
+	for i := 0; i < 70000; i++ {
+		fmt.Fprintf(&literals, "\"s%d\" ", i)
+	}
+	var body strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&body, "%d ", i)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{literals.String() + "(list (car '(a)) \"last\")", "(a \"last\")"},
+		{"(defun big (x) (if x (progn " + body.String() + ") 7)) (list (big nil) (big t))", "(7 19999)"},
+		{strings.Repeat("(block b) ", 65537) + "(car '(a))", "a"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testRun(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("tests[%d]: wrong result. got=%s, want=%s", i, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(car 1)", "1:1"},
+		{"(list 1\n  (+ 1 'a))", "2:3"},
+		{"(defun f (x) (car x)) (f 1)", "1:14"},
+		{"(defun f (x) (g x)) (defun g (x) (car x)) (f 1)", "1:34"},
+		{"(defun h (x) x) (defun k () (h)) (k)", "1:29"},
+		{"(list undefined)", "1:7"},
+		{"(undefined 1)", "1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testRun(tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input=%q: object is not Error. got=%s", tt.input, evaluated.Inspect())
+			continue
+		}
+		if err.Pos.String() != tt.expected {
+			t.Errorf("input=%q: wrong position. got=%s, want=%s", tt.input, err.Pos.String(), tt.expected)
+		}
+		// the position is the same as that of the evaluator
+		if expected := testEval(tt.input).(*object.Error); err.Pos.String() != expected.Pos.String() {
+			t.Errorf("input=%q: position differs from the evaluator. got=%s, want=%s", tt.input, err.Pos.String(), expected.Pos.String())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	inputs := []string{
+		"(defun count-down (n) (if (= n 0) 'done (count-down (- n 1)))) (count-down 1000000)",
+		// the function which returns from its block is run by the evaluator
+		"(defun count-down (n) (if (= n 0) (return-from count-down 'done)) (count-down (- n 1))) (count-down 1000000)",
+	}
+
+	for _, input := range inputs {
+		evaluated := testRun(input)
+		if evaluated.Inspect() != "done" {
+			t.Fatalf("input=%q: unexpected result: %s", input, evaluated.Inspect())
+		}
+	}
+}
+
+func BenchmarkTailRecursion(b *testing.B) {
+	input := "(defun count-down (n) (if (= n 0) 'done (count-down (- n 1)))) (count-down 1000000)"
+
+	for i := 0; i < b.N; i++ {
+		evaluated := testRun(input)
+		if evaluated.Inspect() != "done" {
+			b.Fatalf("unexpected result: %s", evaluated.Inspect())
+		}
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	input := "(defun fib (n) (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))) (fib 20)"
+
+	for i := 0; i < b.N; i++ {
+		evaluated := testRun(input)
+		if evaluated.Inspect() != "6765" {
+			b.Fatalf("unexpected result: %s", evaluated.Inspect())
+		}
+	}
+}